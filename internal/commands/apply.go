@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/client"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/config"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
+	"github.com/spf13/cobra"
+)
+
+// planApplier defines the interface for executing a membership plan needed by apply.
+type planApplier interface {
+	ApplyPlan(plan *membership.Plan, opts membership.SyncOptions, logger *zerolog.Logger) error
+}
+
+func ApplyPlan(logger *zerolog.Logger) *cobra.Command {
+	applyCmd := cobra.Command{
+		Use:   "apply [planFile]",
+		Short: "Applies a membership plan written by sync --out, refusing to run if memberships have drifted",
+		Args: func(_ *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				msg := fmt.Sprintf("expected planFile argument, got %d", len(args))
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+			if _, err := os.Stat(args[0]); os.IsNotExist(err) {
+				msg := fmt.Sprintf("planFile does not exist: %s", args[0])
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+			return nil
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			c := client.New(config.New(), logger)
+			mc := membership.New(c)
+			return runApplyPlan(args, logger, mc)
+		},
+	}
+	return &applyCmd
+}
+
+func runApplyPlan(args []string, logger *zerolog.Logger, pa planApplier) error {
+	plan, err := readPlanFile(args[0], logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info().Msgf("Applying plan of %d Users on groupID: %s", len(plan.Users), plan.GroupID)
+	opts := membership.SyncOptions{SnapshotPath: snapshotFilePath, Concurrency: concurrency}
+	if err := pa.ApplyPlan(plan, opts, logger); err != nil {
+		logger.Error().Err(err).Msg("Failed to apply plan")
+		return err
+	}
+	return nil
+}
+
+// readPlanFile reads a machine-readable plan written by sync --out.
+func readPlanFile(filePath string, logger *zerolog.Logger) (*membership.Plan, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to open plan file: %s", filePath)
+		return nil, err
+	}
+	defer file.Close()
+
+	plan, err := membership.ReadPlan(file)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to read plan file: %s", filePath)
+		return nil, err
+	}
+	return plan, nil
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockPlanApplier is a mock for the planApplier interface
+type mockPlanApplier struct {
+	mock.Mock
+}
+
+func (m *mockPlanApplier) ApplyPlan(plan *membership.Plan, opts membership.SyncOptions, logger *zerolog.Logger) error {
+	args := m.Called(plan, opts, logger)
+	return args.Error(0)
+}
+
+// writeTempPlanFile is a helper to write a plan file for tests
+func writeTempPlanFile(t *testing.T, content string) string {
+	tmpFile, err := os.CreateTemp("", "plan*.json")
+	assert.NoError(t, err)
+	_, err = tmpFile.WriteString(content)
+	assert.NoError(t, err)
+	tmpFile.Close()
+	return tmpFile.Name()
+}
+
+func TestApplyPlanCommand_Args(t *testing.T) {
+	logger := zerolog.Nop()
+	cmd := ApplyPlan(&logger)
+
+	t.Run("invalid number of arguments", func(t *testing.T) {
+		err := cmd.Args(cmd, []string{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "expected planFile argument")
+	})
+
+	t.Run("missing plan file", func(t *testing.T) {
+		err := cmd.Args(cmd, []string{"/path/to/nonexistent.json"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "planFile does not exist")
+	})
+}
+
+func TestRunApplyPlan(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("applies the plan file", func(t *testing.T) {
+		planFile := writeTempPlanFile(t, `{"groupId":"group-id","users":[{"sourceIdentifier":"user1@source.com","destinationUserId":"dst-1"}]}`)
+		defer os.Remove(planFile)
+
+		pa := new(mockPlanApplier)
+		pa.On("ApplyPlan", mock.MatchedBy(func(plan *membership.Plan) bool {
+			return plan.GroupID == "group-id" && len(plan.Users) == 1 && plan.Users[0].DestinationUserID == "dst-1"
+		}), mock.Anything, mock.Anything).Return(nil)
+
+		err := runApplyPlan([]string{planFile}, &logger, pa)
+		assert.NoError(t, err)
+		pa.AssertExpectations(t)
+	})
+
+	t.Run("returns drift error", func(t *testing.T) {
+		planFile := writeTempPlanFile(t, `{"groupId":"group-id","users":[]}`)
+		defer os.Remove(planFile)
+
+		pa := new(mockPlanApplier)
+		pa.On("ApplyPlan", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("plan is stale"))
+
+		err := runApplyPlan([]string{planFile}, &logger, pa)
+		assert.EqualError(t, err, "plan is stale")
+		pa.AssertExpectations(t)
+	})
+
+	t.Run("invalid plan file", func(t *testing.T) {
+		planFile := writeTempPlanFile(t, "invalid json")
+		defer os.Remove(planFile)
+
+		pa := new(mockPlanApplier)
+		err := runApplyPlan([]string{planFile}, &logger, pa)
+		assert.Error(t, err)
+		pa.AssertNotCalled(t, "ApplyPlan", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
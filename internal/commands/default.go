@@ -5,18 +5,55 @@ import (
 	"time"
 
 	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	cliVersion       string
-	domain           string
-	ssoDomain        string
-	email            string
-	csvFilePath      string
-	matchByUserName  bool
-	matchToLocalPart bool
+	cliVersion            string
+	domains               []string
+	domainMap             map[string]string
+	ssoDomain             string
+	email                 string
+	csvFilePath           string
+	matchByUserName       bool
+	matchToLocalPart      bool
+	dryRun                bool
+	syncMode              string
+	rolePrecedence        []string
+	planFilePath          string
+	snapshotFilePath      string
+	journalFilePath       string
+	resumeFilePath        string
+	concurrency           int
+	reportFilePath        string
+	reportFormat          string
+	mappingFilePath       string
+	transformFilePath     string
+	explain               bool
+	outputFormat          string
+	userOutputFormat      string
+	userColumns           []string
+	sortBy                string
+	notDomains            []string
+	activeUsers           string
+	nameRegex             string
+	emailRegex            string
+	userNameRegex         string
+	overridesFilePath     string
+	conflictsFilePath     string
+	destinationGroupID    string
+	orgMappingFilePath    string
+	roleMappingFilePath   string
+	unmappedRoles         string
+	includeOrgs           []string
+	excludeOrgs           []string
+	includeOrgsFilePath   string
+	excludeOrgsFilePath   string
+	retireSource          string
+	connection            string
+	destinationConnection string
 )
 
 func DefaultCommand() *cobra.Command {
@@ -24,6 +61,10 @@ func DefaultCommand() *cobra.Command {
 	layout := currentTime.Format("20060102150405")
 	// Set the log file name with the current timestamp
 	fileName := "snyk-sso-membership_run_" + layout + ".log"
+	// Set the default snapshot file name of the memberships modified by this run
+	snapshotFileName := "snyk-sso-membership_snapshot_" + layout + ".json"
+	// Set the default journal file name of the synchronization progress of this run
+	journalFileName := "snyk-sso-membership_journal_" + layout + ".jsonl"
 	logFile, ferr := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0664)
 	if ferr != nil {
 		panic(ferr)
@@ -58,36 +99,160 @@ func DefaultCommand() *cobra.Command {
 	viper.BindPFlag("debug", cmd.PersistentFlags().Lookup("debug")) //nolint:errcheck
 
 	syncCmd := SyncMemberships(&logger)
-	syncCmd.Flags().StringVar(&domain, "domain", "", "Domain")
+	syncCmd.Flags().StringSliceVar(&domains, "domain", nil, "Domains, comma separated or repeated")
 	syncCmd.Flags().StringVar(&ssoDomain, "ssoDomain", "", "Sync Domain")
+	syncCmd.Flags().StringToStringVar(&domainMap, "domainMap", nil, "Sync Domain of every Domain, as domain=ssoDomain pairs overriding ssoDomain (optional)")
 	syncCmd.Flags().StringVar(&csvFilePath, "csvFilePath", "", "Path to CSV file containing email addresses (optional)")
 	syncCmd.Flags().BoolVar(&matchByUserName, "matchByUserName", false, "Match by UserName Identifier (default: false)")
 	syncCmd.Flags().BoolVar(&matchToLocalPart, "matchToLocalPart", false, "Match to Local Part Identifier on SSO domain (default: false)")
-	_ = syncCmd.MarkFlagRequired("domain")
+	syncCmd.Flags().StringVar(&mappingFilePath, "mappingFile", "", "Path to CSV file pairing source and destination user identifiers, instead of matching by domain (optional)")
+	syncCmd.Flags().BoolVar(&dryRun, "dryRun", false, "Print the planned membership changes without modifying any membership (default: false)")
+	syncCmd.Flags().StringVar(&syncMode, "mode", membership.ModeMirror, "Synchronization mode: mirror or merge, merge never removes nor downgrades memberships")
+	syncCmd.Flags().StringSliceVar(&rolePrecedence, "rolePrecedence", membership.DefaultRolePrecedence, "Role names or IDs ordered from highest to lowest privilege, used by merge mode to only upgrade roles")
+	syncCmd.Flags().StringVar(&planFilePath, "out", "", "Path to write the machine-readable plan to, without modifying any membership (optional)")
+	syncCmd.Flags().StringVar(&snapshotFilePath, "snapshotFile", snapshotFileName, "Path to write the snapshot of the memberships to before modifying them, used by rollback")
+	syncCmd.Flags().StringVar(&journalFilePath, "journalFile", journalFileName, "Path to write the synchronization progress of every user to, used by --resume")
+	syncCmd.Flags().StringVar(&resumeFilePath, "resume", "", "Path to the journal of an interrupted sync to resume, skipping the completed users and retrying the failed ones (optional)")
+	syncCmd.Flags().IntVar(&concurrency, "concurrency", 1, "Number of users processed in parallel, sharing the API rate limit")
+	syncCmd.Flags().StringVar(&reportFilePath, "reportFile", "", "Path to write the outcome of the synchronization of every user to (optional)")
+	syncCmd.Flags().StringVar(&reportFormat, "reportFormat", reportFormatJSON, "Format of the report: json or csv")
+	syncCmd.Flags().StringVar(&transformFilePath, "transformFile", "", "Path to JSON file of rules rewriting the source local part before looking up the destination user (optional)")
+	syncCmd.Flags().StringVar(&overridesFilePath, "overridesFile", "", "Path to CSV file pairing source and destination user identifiers, resolving their conflicts (optional)")
+	syncCmd.Flags().StringVar(&conflictsFilePath, "conflictsFile", "", "Path to write the conflicting source and destination users to as CSV (optional)")
+	syncCmd.Flags().StringVar(&destinationGroupID, "destinationGroup", "", "Group ID to migrate the memberships to, looking up the destination users in this group (optional)")
+	syncCmd.Flags().StringVar(&orgMappingFilePath, "orgMappingFile", "", "Path to CSV file pairing source and destination orgs by ID, slug or name, required by --destinationGroup")
+	syncCmd.Flags().StringVar(&roleMappingFilePath, "roleMappingFile", "", "Path to JSON file of rules translating the source roles, by role ID or name and optionally per org (optional)")
+	syncCmd.Flags().StringVar(&unmappedRoles, "unmappedRoles", membership.UnmappedRolesPassthrough, "Handling of the roles without a rule of roleMappingFile: passthrough or reject, reject skips their memberships")
+	syncCmd.Flags().StringSliceVar(&includeOrgs, "includeOrgs", nil, "Only sync the orgs matching these ID, slug or name globs, comma separated or repeated (optional)")
+	syncCmd.Flags().StringSliceVar(&excludeOrgs, "excludeOrgs", nil, "Never sync the orgs matching these ID, slug or name globs, comma separated or repeated (optional)")
+	syncCmd.Flags().StringVar(&includeOrgsFilePath, "includeOrgsFile", "", "Path to file of includeOrgs globs, one per line (optional)")
+	syncCmd.Flags().StringVar(&excludeOrgsFilePath, "excludeOrgsFile", "", "Path to file of excludeOrgs globs, one per line (optional)")
+	syncCmd.Flags().StringVar(&retireSource, "retireSource", "", "Retire the source user of every fully synchronized user pair: remove-memberships or delete-user (optional)")
+	syncCmd.Flags().StringVar(&connection, "connection", "", "SSO connection of the source users by ID or name, required by groups with several SSO connections")
+	syncCmd.Flags().StringVar(&destinationConnection, "destinationConnection", "", "SSO connection to look up the destination users in by ID or name, in the group or in --destinationGroup (optional)")
 	_ = syncCmd.MarkFlagFilename("csvFilePath", "csv")
-	syncCmd.MarkFlagsMutuallyExclusive("ssoDomain", "matchToLocalPart")
-	syncCmd.MarkFlagsOneRequired("ssoDomain", "matchToLocalPart")
+	_ = syncCmd.MarkFlagFilename("mappingFile", "csv")
+	_ = syncCmd.MarkFlagFilename("transformFile", "json")
+	_ = syncCmd.MarkFlagFilename("overridesFile", "csv")
+	_ = syncCmd.MarkFlagFilename("orgMappingFile", "csv")
+	_ = syncCmd.MarkFlagFilename("roleMappingFile", "json")
+	syncCmd.MarkFlagsRequiredTogether("destinationGroup", "orgMappingFile")
+	syncCmd.MarkFlagsMutuallyExclusive("ssoDomain", "matchToLocalPart", "mappingFile")
+	syncCmd.MarkFlagsOneRequired("ssoDomain", "matchToLocalPart", "mappingFile", "domainMap")
+	syncCmd.MarkFlagsMutuallyExclusive("domainMap", "matchToLocalPart")
+	syncCmd.MarkFlagsMutuallyExclusive("domainMap", "mappingFile")
+	syncCmd.MarkFlagsMutuallyExclusive("csvFilePath", "mappingFile")
+	syncCmd.MarkFlagsMutuallyExclusive("transformFile", "mappingFile")
+	syncCmd.MarkFlagsMutuallyExclusive("overridesFile", "mappingFile")
+	syncCmd.MarkFlagsMutuallyExclusive("journalFile", "resume")
 	cmd.AddCommand(syncCmd)
 
 	deleteUsersCmd := DeleteUsers(&logger)
-	deleteUsersCmd.Flags().StringVar(&domain, "domain", "", "Domain")
+	deleteUsersCmd.Flags().StringSliceVar(&domains, "domain", nil, "Only users on one of these domains, comma separated or repeated (optional)")
+	deleteUsersCmd.Flags().StringSliceVar(&notDomains, "notDomain", nil, "Only users on none of these domains, comma separated or repeated (optional)")
+	deleteUsersCmd.Flags().StringVar(&activeUsers, "active", "", "Only active users if true, inactive users if false (optional)")
+	deleteUsersCmd.Flags().StringVar(&nameRegex, "nameRegex", "", "Only users whose name matches this regular expression (optional)")
+	deleteUsersCmd.Flags().StringVar(&emailRegex, "emailRegex", "", "Only users whose email matches this regular expression (optional)")
+	deleteUsersCmd.Flags().StringVar(&userNameRegex, "userNameRegex", "", "Only users whose username matches this regular expression (optional)")
 	deleteUsersCmd.Flags().StringVar(&email, "email", "", "Email")
 	deleteUsersCmd.Flags().StringVar(&csvFilePath, "csvFilePath", "", "Path to CSV file containing email addresses (optional)")
 	deleteUsersCmd.Flags().BoolVar(&matchByUserName, "matchByUserName", false, "Match by UserName Identifier (default: false)")
-	deleteUsersCmd.MarkFlagsMutuallyExclusive("domain", "email", "csvFilePath")
-	deleteUsersCmd.MarkFlagsOneRequired("domain", "email", "csvFilePath")
+	deleteUsersCmd.Flags().StringVar(&connection, "connection", "", "SSO connection of the users by ID or name, required by groups with several SSO connections")
+	deleteUsersCmd.MarkFlagsMutuallyExclusive("email", "csvFilePath")
+	deleteUsersCmd.MarkFlagsOneRequired("domain", "email", "csvFilePath", "notDomain", "active", "nameRegex", "emailRegex", "userNameRegex")
 	_ = deleteUsersCmd.MarkFlagFilename("csvFilePath", "csv")
 	cmd.AddCommand(deleteUsersCmd)
 
 	getUsersCmd := GetUsers(&logger)
-	getUsersCmd.Flags().StringVar(&domain, "domain", "", "Domain")
+	getUsersCmd.Flags().StringSliceVar(&domains, "domain", nil, "Only users on one of these domains, comma separated or repeated (optional)")
+	getUsersCmd.Flags().StringSliceVar(&notDomains, "notDomain", nil, "Only users on none of these domains, comma separated or repeated (optional)")
+	getUsersCmd.Flags().StringVar(&activeUsers, "active", "", "Only active users if true, inactive users if false (optional)")
+	getUsersCmd.Flags().StringVar(&nameRegex, "nameRegex", "", "Only users whose name matches this regular expression (optional)")
+	getUsersCmd.Flags().StringVar(&emailRegex, "emailRegex", "", "Only users whose email matches this regular expression (optional)")
+	getUsersCmd.Flags().StringVar(&userNameRegex, "userNameRegex", "", "Only users whose username matches this regular expression (optional)")
 	getUsersCmd.Flags().StringVar(&email, "email", "", "Email")
 	getUsersCmd.Flags().StringVar(&csvFilePath, "csvFilePath", "", "Path to CSV file containing email addresses (optional)")
 	getUsersCmd.Flags().BoolVar(&matchByUserName, "matchByUserName", false, "Match by UserName Identifier (default: false)")
-	getUsersCmd.MarkFlagsMutuallyExclusive("domain", "email", "csvFilePath")
+	getUsersCmd.Flags().StringVar(&connection, "connection", "", "SSO connection of the users by ID or name, required by groups with several SSO connections")
+	getUsersCmd.Flags().StringVar(&userOutputFormat, "output", outputFormatCSV, "Output format: csv, json, ndjson, yaml or table")
+	getUsersCmd.Flags().StringSliceVar(&userColumns, "columns", defaultUserColumns, "Columns to write, among id, email, username, name, active, domain and localPart, comma separated or repeated")
+	getUsersCmd.Flags().StringVar(&sortBy, "sortBy", "", "Column to sort the users by, in the API order by default (optional)")
+	getUsersCmd.MarkFlagsMutuallyExclusive("email", "csvFilePath")
 	_ = getUsersCmd.MarkFlagFilename("csvFilePath", "csv")
 	cmd.AddCommand(getUsersCmd)
 
+	matchCmd := MatchUsers(&logger)
+	matchCmd.Flags().StringSliceVar(&domains, "domain", nil, "Domains, comma separated or repeated")
+	matchCmd.Flags().StringVar(&ssoDomain, "ssoDomain", "", "Sync Domain")
+	matchCmd.Flags().StringToStringVar(&domainMap, "domainMap", nil, "Sync Domain of every Domain, as domain=ssoDomain pairs overriding ssoDomain (optional)")
+	matchCmd.Flags().BoolVar(&matchByUserName, "matchByUserName", false, "Match by UserName Identifier (default: false)")
+	matchCmd.Flags().BoolVar(&matchToLocalPart, "matchToLocalPart", false, "Match to Local Part Identifier on SSO domain (default: false)")
+	matchCmd.Flags().StringVar(&transformFilePath, "transformFile", "", "Path to JSON file of rules rewriting the source local part before looking up the destination user (optional)")
+	matchCmd.Flags().BoolVar(&explain, "explain", false, "Print every rule evaluated, transform rule step and candidate destination user of every source user (default: false)")
+	matchCmd.Flags().StringVar(&overridesFilePath, "overridesFile", "", "Path to CSV file pairing source and destination user identifiers, resolving their conflicts (optional)")
+	matchCmd.Flags().StringVar(&outputFormat, "output", outputFormatTable, "Output format: table or json")
+	matchCmd.Flags().StringVar(&connection, "connection", "", "SSO connection of the users by ID or name, required by groups with several SSO connections")
+	_ = matchCmd.MarkFlagRequired("domain")
+	_ = matchCmd.MarkFlagFilename("transformFile", "json")
+	_ = matchCmd.MarkFlagFilename("overridesFile", "csv")
+	matchCmd.MarkFlagsMutuallyExclusive("ssoDomain", "matchToLocalPart")
+	matchCmd.MarkFlagsOneRequired("ssoDomain", "matchToLocalPart", "domainMap")
+	matchCmd.MarkFlagsMutuallyExclusive("domainMap", "matchToLocalPart")
+	cmd.AddCommand(matchCmd)
+
+	verifyCmd := VerifyMemberships(&logger)
+	verifyCmd.Flags().StringSliceVar(&domains, "domain", nil, "Domains, comma separated or repeated")
+	verifyCmd.Flags().StringVar(&ssoDomain, "ssoDomain", "", "Sync Domain")
+	verifyCmd.Flags().StringToStringVar(&domainMap, "domainMap", nil, "Sync Domain of every Domain, as domain=ssoDomain pairs overriding ssoDomain (optional)")
+	verifyCmd.Flags().StringVar(&csvFilePath, "csvFilePath", "", "Path to CSV file containing email addresses (optional)")
+	verifyCmd.Flags().BoolVar(&matchByUserName, "matchByUserName", false, "Match by UserName Identifier (default: false)")
+	verifyCmd.Flags().BoolVar(&matchToLocalPart, "matchToLocalPart", false, "Match to Local Part Identifier on SSO domain (default: false)")
+	verifyCmd.Flags().StringVar(&mappingFilePath, "mappingFile", "", "Path to CSV file pairing source and destination user identifiers, instead of matching by domain (optional)")
+	verifyCmd.Flags().StringVar(&syncMode, "mode", membership.ModeMirror, "Synchronization mode verified: mirror or merge, merge tolerates extra memberships and higher roles")
+	verifyCmd.Flags().StringSliceVar(&rolePrecedence, "rolePrecedence", membership.DefaultRolePrecedence, "Role names or IDs ordered from highest to lowest privilege, used by merge mode to only upgrade roles")
+	verifyCmd.Flags().StringVar(&transformFilePath, "transformFile", "", "Path to JSON file of rules rewriting the source local part before looking up the destination user (optional)")
+	verifyCmd.Flags().StringVar(&overridesFilePath, "overridesFile", "", "Path to CSV file pairing source and destination user identifiers, resolving their conflicts (optional)")
+	verifyCmd.Flags().StringVar(&destinationGroupID, "destinationGroup", "", "Group ID the memberships were migrated to, looking up the destination users in this group (optional)")
+	verifyCmd.Flags().StringVar(&orgMappingFilePath, "orgMappingFile", "", "Path to CSV file pairing source and destination orgs by ID, slug or name, required by --destinationGroup")
+	verifyCmd.Flags().StringVar(&roleMappingFilePath, "roleMappingFile", "", "Path to JSON file of rules translating the source roles, by role ID or name and optionally per org (optional)")
+	verifyCmd.Flags().StringVar(&unmappedRoles, "unmappedRoles", membership.UnmappedRolesPassthrough, "Handling of the roles without a rule of roleMappingFile: passthrough or reject, reject skips their memberships")
+	verifyCmd.Flags().StringSliceVar(&includeOrgs, "includeOrgs", nil, "Only verify the orgs matching these ID, slug or name globs, comma separated or repeated (optional)")
+	verifyCmd.Flags().StringSliceVar(&excludeOrgs, "excludeOrgs", nil, "Never verify the orgs matching these ID, slug or name globs, comma separated or repeated (optional)")
+	verifyCmd.Flags().StringVar(&includeOrgsFilePath, "includeOrgsFile", "", "Path to file of includeOrgs globs, one per line (optional)")
+	verifyCmd.Flags().StringVar(&excludeOrgsFilePath, "excludeOrgsFile", "", "Path to file of excludeOrgs globs, one per line (optional)")
+	verifyCmd.Flags().IntVar(&concurrency, "concurrency", 1, "Number of users processed in parallel, sharing the API rate limit")
+	verifyCmd.Flags().StringVar(&outputFormat, "output", outputFormatTable, "Output format: table or json")
+	verifyCmd.Flags().StringVar(&connection, "connection", "", "SSO connection of the source users by ID or name, required by groups with several SSO connections")
+	verifyCmd.Flags().StringVar(&destinationConnection, "destinationConnection", "", "SSO connection to look up the destination users in by ID or name, in the group or in --destinationGroup (optional)")
+	_ = verifyCmd.MarkFlagFilename("csvFilePath", "csv")
+	_ = verifyCmd.MarkFlagFilename("mappingFile", "csv")
+	_ = verifyCmd.MarkFlagFilename("transformFile", "json")
+	_ = verifyCmd.MarkFlagFilename("overridesFile", "csv")
+	_ = verifyCmd.MarkFlagFilename("orgMappingFile", "csv")
+	_ = verifyCmd.MarkFlagFilename("roleMappingFile", "json")
+	verifyCmd.MarkFlagsRequiredTogether("destinationGroup", "orgMappingFile")
+	verifyCmd.MarkFlagsMutuallyExclusive("ssoDomain", "matchToLocalPart", "mappingFile")
+	verifyCmd.MarkFlagsOneRequired("ssoDomain", "matchToLocalPart", "mappingFile", "domainMap")
+	verifyCmd.MarkFlagsMutuallyExclusive("domainMap", "matchToLocalPart")
+	verifyCmd.MarkFlagsMutuallyExclusive("domainMap", "mappingFile")
+	verifyCmd.MarkFlagsMutuallyExclusive("csvFilePath", "mappingFile")
+	verifyCmd.MarkFlagsMutuallyExclusive("transformFile", "mappingFile")
+	verifyCmd.MarkFlagsMutuallyExclusive("overridesFile", "mappingFile")
+	cmd.AddCommand(verifyCmd)
+
+	listConnectionsCmd := ListConnections(&logger)
+	listConnectionsCmd.Flags().StringVar(&outputFormat, "output", outputFormatTable, "Output format: table, csv or json")
+	cmd.AddCommand(listConnectionsCmd)
+
+	applyCmd := ApplyPlan(&logger)
+	applyCmd.Flags().IntVar(&concurrency, "concurrency", 1, "Number of users processed in parallel, sharing the API rate limit")
+	applyCmd.Flags().StringVar(&snapshotFilePath, "snapshotFile", snapshotFileName, "Path to write the snapshot of the memberships to before modifying them, used by rollback")
+	cmd.AddCommand(applyCmd)
+
+	rollbackCmd := Rollback(&logger)
+	rollbackCmd.Flags().BoolVar(&dryRun, "dryRun", false, "Print the planned membership changes without modifying any membership (default: false)")
+	cmd.AddCommand(rollbackCmd)
+
 	// set ldflags input version flag
 	cmd.SetVersionTemplate(cliVersion)
 	return &cmd
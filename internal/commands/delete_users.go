@@ -25,7 +25,7 @@ func DeleteUsers(logger *zerolog.Logger) *cobra.Command {
 		},
 		RunE: func(_ *cobra.Command, args []string) error {
 			c := client.New(config.New(), logger)
-			sc := sso.New(c)
+			sc := sso.New(c).WithConnection(connection)
 			return runDeleteUsers(args, logger, sc)
 		},
 	}
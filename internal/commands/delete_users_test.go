@@ -30,14 +30,6 @@ func (m *MockSsoDeleter) DeleteUsers(groupID string, users sso.Users, logger *ze
 	return args.Error(0)
 }
 
-func (m *MockSsoDeleter) FilterUsersByDomain(domain string, users sso.Users, matchByUserName bool, logger *zerolog.Logger) ([]sso.User, error) {
-	args := m.Called(domain, users, matchByUserName, logger)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).([]sso.User), args.Error(1)
-}
-
 func (m *MockSsoDeleter) FilterUsersByProfileIDs(identifiers []string, users sso.Users, matchByUserName bool, logger *zerolog.Logger) ([]sso.User, error) {
 	args := m.Called(identifiers, users, matchByUserName, logger)
 	if args.Get(0) == nil {
@@ -65,18 +57,20 @@ func TestDeleteUsersCommand_Args(t *testing.T) {
 	cmd := DeleteUsers(&logger)
 
 	// Backup and restore package-level flag variables
-	oldDomain, oldEmail, oldCsvFilePath := domain, email, csvFilePath
+	oldDomains, oldEmail, oldCsvFilePath := domains, email, csvFilePath
 	defer func() {
-		domain, email, csvFilePath = oldDomain, oldEmail, oldCsvFilePath
+		domains, email, csvFilePath = oldDomains, oldEmail, oldCsvFilePath
+		notDomains, activeUsers, nameRegex = nil, "", ""
 	}()
 
 	resetFlags := func() {
-		domain, email, csvFilePath = "", "", ""
+		domains, email, csvFilePath = nil, "", ""
+		notDomains, activeUsers, nameRegex = nil, "", ""
 	}
 
 	t.Run("invalid number of arguments", func(t *testing.T) {
 		resetFlags()
-		domain = "example.com"
+		domains = []string{"example.com"}
 		err := cmd.Args(cmd, []string{})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "expected groupID")
@@ -84,11 +78,29 @@ func TestDeleteUsersCommand_Args(t *testing.T) {
 
 	t.Run("invalid groupID", func(t *testing.T) {
 		resetFlags()
-		domain = "example.com"
+		domains = []string{"example.com"}
 		err := cmd.Args(cmd, []string{"invalid-uuid"})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "groupID must be a valid UUID")
 	})
+
+	t.Run("invalid notDomain", func(t *testing.T) {
+		resetFlags()
+		notDomains = []string{"old.com", "invalid_domain_@@"}
+		assert.EqualError(t, cmd.Args(cmd, []string{uuid.New().String()}), "notDomain must be a valid domain name: invalid_domain_@@")
+	})
+
+	t.Run("invalid active", func(t *testing.T) {
+		resetFlags()
+		activeUsers = "maybe"
+		assert.EqualError(t, cmd.Args(cmd, []string{uuid.New().String()}), "active must be true or false: maybe")
+	})
+
+	t.Run("invalid regular expression", func(t *testing.T) {
+		resetFlags()
+		nameRegex = "(Doe"
+		assert.EqualError(t, cmd.Args(cmd, []string{uuid.New().String()}), "nameRegex must be a valid regular expression: (Doe")
+	})
 }
 
 func TestRunDeleteUsers(t *testing.T) {
@@ -105,13 +117,16 @@ func TestRunDeleteUsers(t *testing.T) {
 	}
 
 	// Backup and restore package-level flag variables
-	oldDomain, oldEmail, oldCsvFilePath, oldMatchByUserName := domain, email, csvFilePath, matchByUserName
+	oldDomains, oldNotDomains, oldEmail, oldCsvFilePath, oldMatchByUserName := domains, notDomains, email, csvFilePath, matchByUserName
+	oldActiveUsers, oldUserNameRegex := activeUsers, userNameRegex
 	defer func() {
-		domain, email, csvFilePath, matchByUserName = oldDomain, oldEmail, oldCsvFilePath, oldMatchByUserName
+		domains, notDomains, email, csvFilePath, matchByUserName = oldDomains, oldNotDomains, oldEmail, oldCsvFilePath, oldMatchByUserName
+		activeUsers, userNameRegex = oldActiveUsers, oldUserNameRegex
 	}()
 
 	resetFlags := func() {
-		domain, email, csvFilePath, matchByUserName = "", "", "", false
+		domains, notDomains, email, csvFilePath, matchByUserName = nil, nil, "", "", false
+		activeUsers, userNameRegex = "", ""
 	}
 
 	t.Run("delete users from csv with matchByUserName", func(t *testing.T) {
@@ -133,10 +148,11 @@ func TestRunDeleteUsers(t *testing.T) {
 		// Set package-level variables
 		csvFilePath = tmpFile.Name()
 		matchByUserName = true
-		domain = "example.com" // Needed for FilterUsersByDomain
+		// the users of the CSV file are also filtered by the username domain
+		domains = []string{"example.com"}
 		mockSso.On("GetUsers", groupID, &logger).Return(allSsoUsers, nil).Once()
 
-		mockSso.On("FilterUsersByDomain", "example.com", *allSsoUsers, true, &logger).Return([]sso.User{
+		mockSso.On("FilterUsersByProfileIDs", []string{"user4@example.com"}, *allSsoUsers, true, &logger).Return([]sso.User{
 			makeUserForDeleteTest("id4", "user4@example.com", "user4@example.com"),
 		}, nil).Once()
 
@@ -180,7 +196,7 @@ func TestRunDeleteUsers(t *testing.T) {
 	t.Run("GetUsers returns error", func(t *testing.T) {
 		resetFlags()
 		mockSso := new(MockSsoDeleter)
-		domain = "example.com" // Need to set one of the flags to trigger logic
+		domains = []string{"example.com"} // Need to set one of the flags to trigger logic
 
 		mockSso.On("GetUsers", validUUID, &logger).Return(nil, errors.New("API error")).Once()
 
@@ -193,7 +209,7 @@ func TestRunDeleteUsers(t *testing.T) {
 	t.Run("delete users by domain", func(t *testing.T) {
 		resetFlags()
 		mockSso := new(MockSsoDeleter)
-		domain = "example.com"
+		domains = []string{"example.com"}
 
 		mockSso.On("GetUsers", validUUID, &logger).Return(allSsoUsers, nil).Once()
 
@@ -202,7 +218,6 @@ func TestRunDeleteUsers(t *testing.T) {
 			makeUserForDeleteTest("id2", "user2@example.com", "user2@example2.com"),
 			makeUserForDeleteTest("id4", "user4@example.com", "user4@example.com"),
 		}
-		mockSso.On("FilterUsersByDomain", domain, *allSsoUsers, false, &logger).Return(filteredUsers, nil).Once()
 
 		expectedUsersToDelete := sso.Users{Data: filteredUsers}
 		mockSso.On("DeleteUsers", validUUID, mock.Anything, &logger).Run(func(args mock.Arguments) {
@@ -310,4 +325,45 @@ func TestRunDeleteUsers(t *testing.T) {
 		assert.NoError(t, err)
 		mockSso.AssertExpectations(t)
 	})
+
+	t.Run("delete users matching every filter", func(t *testing.T) {
+		resetFlags()
+		mockSso := new(MockSsoDeleter)
+		inactive := false
+		users := &sso.Users{Data: []sso.User{
+			makeUserForDeleteTest("id1", "jane.doe@old.com", "jane.doe@old.com"),
+			makeUserForDeleteTest("id2", "john.doe@old.com", "jdoe"),
+			makeUserForDeleteTest("id3", "bob@old.com", "bob"),
+			makeUserForDeleteTest("id4", "alice@new.com", "alice"),
+		}}
+		for i := range users.Data {
+			if i != 2 {
+				users.Data[i].Attributes.Active = &inactive
+			}
+		}
+		// inactive users on old.com whose username is not an email
+		domains, activeUsers, userNameRegex = []string{"old.com"}, "false", `^[^@]+$`
+		mockSso.On("GetUsers", validUUID, &logger).Return(users, nil).Once()
+		mockSso.On("DeleteUsers", validUUID, mock.Anything, &logger).Run(func(args mock.Arguments) {
+			usersArg := args.Get(1).(sso.Users)
+			assert.Equal(t, []sso.User{users.Data[1]}, usersArg.Data)
+		}).Return(nil).Once()
+
+		assert.NoError(t, runDeleteUsers([]string{validUUID}, &logger, mockSso))
+		mockSso.AssertExpectations(t)
+	})
+
+	t.Run("delete users not on the domain", func(t *testing.T) {
+		resetFlags()
+		mockSso := new(MockSsoDeleter)
+		notDomains = []string{"example.com"}
+		mockSso.On("GetUsers", validUUID, &logger).Return(allSsoUsers, nil).Once()
+		mockSso.On("DeleteUsers", validUUID, mock.Anything, &logger).Run(func(args mock.Arguments) {
+			usersArg := args.Get(1).(sso.Users)
+			assert.Equal(t, []sso.User{allSsoUsers.Data[2]}, usersArg.Data)
+		}).Return(nil).Once()
+
+		assert.NoError(t, runDeleteUsers([]string{validUUID}, &logger, mockSso))
+		mockSso.AssertExpectations(t)
+	})
 }
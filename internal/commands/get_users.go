@@ -1,9 +1,10 @@
 package commands
 
 import (
+	"fmt"
 	"io"
 	"os"
-	"strconv"
+	"slices"
 	"strings"
 
 	"github.com/rs/zerolog"
@@ -16,15 +17,27 @@ import (
 func GetUsers(logger *zerolog.Logger) *cobra.Command {
 	getCmd := cobra.Command{
 		Use:                   "get-users [groupID]",
-		Short:                 "Get users from a SSO matching specified criteria and output as CSV",
+		Short:                 "Get users from a SSO matching specified criteria and output as CSV, JSON, NDJSON, YAML or a table",
 		DisableFlagParsing:    false,
 		DisableFlagsInUseLine: false,
 		Args: func(_ *cobra.Command, args []string) error {
-			return validateGetDeleteArgs(logger, args)
+			if err := validateGetDeleteArgs(logger, args); err != nil {
+				return err
+			}
+			if _, err := newUserWriter(userOutputFormat, io.Discard, userColumns); err != nil {
+				logger.Error().Msg(err.Error())
+				return err
+			}
+			if sortBy != "" && !slices.Contains(userColumnNames, sortBy) {
+				msg := fmt.Sprintf("sortBy must be one of %s: %s", strings.Join(userColumnNames, ", "), sortBy)
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+			return nil
 		},
 		RunE: func(_ *cobra.Command, args []string) error {
 			c := client.New(config.New(), logger)
-			sc := sso.New(c)
+			sc := sso.New(c).WithConnection(connection)
 			return runGetUsers(args, logger, sc)
 		},
 	}
@@ -42,33 +55,17 @@ func runGetUsers(args []string, logger *zerolog.Logger, sc userFetcher) error {
 
 	// return matching users
 	if len(ssoUsers.Data) > 0 {
-		header := []string{"username", "email", "name", "active"}
-		if err := writeQuotedRecord(os.Stdout, header); err != nil {
-			logger.Error().Err(err).Msg("failed to write csv header")
+		uw, err := newUserWriter(userOutputFormat, os.Stdout, userColumns)
+		if err != nil {
+			logger.Error().Err(err).Send()
 			return err
 		}
-
-		for _, user := range ssoUsers.Data {
-			var username, email, name, active string
-			if user.Attributes != nil {
-				if user.Attributes.UserName != nil {
-					username = *user.Attributes.UserName
-				}
-				if user.Attributes.Email != nil {
-					email = *user.Attributes.Email
-				}
-				if user.Attributes.Name != nil {
-					name = *user.Attributes.Name
-				}
-				if user.Attributes.Active != nil {
-					active = strconv.FormatBool(*user.Attributes.Active)
-				}
-			}
-			record := []string{username, email, name, active}
-			if err := writeQuotedRecord(os.Stdout, record); err != nil {
-				logger.Error().Err(err).Msg("failed to write csv record")
-				return err
-			}
+		if sortBy != "" {
+			sortUsers(ssoUsers.Data, sortBy)
+		}
+		if err := uw.Write(ssoUsers.Data); err != nil {
+			logger.Error().Err(err).Msg("failed to write users")
+			return err
 		}
 	} else {
 		logger.Error().Msg("No users found matching the specified criteria")
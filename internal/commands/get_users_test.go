@@ -23,11 +23,6 @@ func (m *mockSSOGetter) GetUsers(groupID string, logger *zerolog.Logger) (*sso.U
 	return args.Get(0).(*sso.Users), args.Error(1)
 }
 
-func (m *mockSSOGetter) FilterUsersByDomain(domain string, users sso.Users, matchByUserName bool, logger *zerolog.Logger) ([]sso.User, error) {
-	args := m.Called(domain, users, matchByUserName, logger)
-	return args.Get(0).([]sso.User), args.Error(1)
-}
-
 func (m *mockSSOGetter) FilterUsersByProfileIDs(identifiers []string, users sso.Users, matchByUserName bool, logger *zerolog.Logger) ([]sso.User, error) {
 	args := m.Called(identifiers, users, matchByUserName, logger)
 	return args.Get(0).([]sso.User), args.Error(1)
@@ -50,7 +45,7 @@ func TestRunGetUsers(t *testing.T) {
 		name            string
 		args            []string
 		setupMock       func(*mockSSOGetter)
-		domain          string
+		domains         []string
 		email           string
 		csvFilePath     string
 		matchByUserName bool
@@ -59,9 +54,9 @@ func TestRunGetUsers(t *testing.T) {
 		expectedErrMsg  string
 	}{
 		{
-			name:   "successful get users with domain filter",
-			args:   []string{"group-id"},
-			domain: "example.com",
+			name:    "successful get users with domain filter",
+			args:    []string{"group-id"},
+			domains: []string{"example.com"},
 			setupMock: func(m *mockSSOGetter) {
 				users := &sso.Users{Data: []sso.User{}}
 				filteredUsers := []sso.User{
@@ -78,10 +73,15 @@ func TestRunGetUsers(t *testing.T) {
 						},
 					},
 				}
+				users.Data = append(filteredUsers, sso.User{Attributes: &struct {
+					Name     *string `json:"name"`
+					Email    *string `json:"email"`
+					UserName *string `json:"username"`
+					Active   *bool   `json:"active"`
+				}{Email: stringPtr("test@another.com"), UserName: stringPtr("another")}})
 				m.On("GetUsers", "group-id", mock.Anything).Return(users, nil)
-				m.On("FilterUsersByDomain", "example.com", *users, false, mock.Anything).Return(filteredUsers, nil)
 			},
-			expectedOutput: "\"username\",\"email\",\"name\",\"active\"\n\"testuser\",\"test@example.com\",\"\",\"true\"\n",
+			expectedOutput: "\"username\",\"email\",\"name\",\"active\",\"id\"\n\"testuser\",\"test@example.com\",\"\",\"true\",\"\"\n",
 			expectError:    false,
 		},
 		{
@@ -107,17 +107,16 @@ func TestRunGetUsers(t *testing.T) {
 				m.On("GetUsers", "group-id", mock.Anything).Return(users, nil)
 				m.On("FilterUsersByProfileIDs", []string{"test@example.com"}, *users, false, mock.Anything).Return(filteredUsers, nil)
 			},
-			expectedOutput: "\"username\",\"email\",\"name\",\"active\"\n\"testuser\",\"test@example.com\",\"\",\"true\"\n",
+			expectedOutput: "\"username\",\"email\",\"name\",\"active\",\"id\"\n\"testuser\",\"test@example.com\",\"\",\"true\",\"\"\n",
 			expectError:    false,
 		},
 		{
-			name:   "filter users error is ignored",
-			args:   []string{"group-id"},
-			domain: "example.com",
+			name:    "no users on the domain",
+			args:    []string{"group-id"},
+			domains: []string{"example.com"},
 			setupMock: func(m *mockSSOGetter) {
 				users := &sso.Users{Data: []sso.User{}}
 				m.On("GetUsers", "group-id", mock.Anything).Return(users, nil)
-				m.On("FilterUsersByDomain", "example.com", *users, false, mock.Anything).Return([]sso.User{}, errors.New("filter error"))
 			},
 			expectedOutput: "",
 			expectError:    false,
@@ -145,7 +144,7 @@ func TestRunGetUsers(t *testing.T) {
 				m.On("GetUsers", "group-id", mock.Anything).Return(users, nil)
 				m.On("FilterUsersByProfileIDs", []string{"csv@example.com"}, *users, false, mock.Anything).Return(filteredUsers, nil)
 			},
-			expectedOutput: "\"username\",\"email\",\"name\",\"active\"\n\"csvuser\",\"csv@example.com\",\"\",\"true\"\n",
+			expectedOutput: "\"username\",\"email\",\"name\",\"active\",\"id\"\n\"csvuser\",\"csv@example.com\",\"\",\"true\",\"\"\n",
 			expectError:    false,
 		},
 	}
@@ -153,17 +152,17 @@ func TestRunGetUsers(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Backup and restore package-level variables
-			oldDomain, oldEmail, oldCsvFilePath := domain, email, csvFilePath
+			oldDomains, oldEmail, oldCsvFilePath := domains, email, csvFilePath
 			oldMatchByUserName := matchByUserName
 			defer func() {
-				domain = oldDomain
+				domains = oldDomains
 				email = oldEmail
 				csvFilePath = oldCsvFilePath
 				matchByUserName = oldMatchByUserName
 			}()
 
 			// Set test values
-			domain = tt.domain
+			domains = tt.domains
 			email = tt.email
 			csvFilePath = tt.csvFilePath
 			matchByUserName = tt.matchByUserName
@@ -209,6 +208,33 @@ func TestRunGetUsers(t *testing.T) {
 	}
 }
 
+func TestGetUsersCommand_Args(t *testing.T) {
+	logger := zerolog.Nop()
+	cmd := GetUsers(&logger)
+	validUUID := "123e4567-e89b-12d3-a456-426614174000"
+	defer func() { userOutputFormat, userColumns, sortBy = "", nil, "" }()
+
+	t.Run("valid output, columns and sortBy", func(t *testing.T) {
+		userOutputFormat, userColumns, sortBy = outputFormatNDJSON, []string{"id", "localPart"}, "domain"
+		assert.NoError(t, cmd.Args(cmd, []string{validUUID}))
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		userOutputFormat, userColumns, sortBy = "xml", nil, ""
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "output must be one of csv, json, ndjson, yaml, table: xml")
+	})
+
+	t.Run("invalid columns", func(t *testing.T) {
+		userOutputFormat, userColumns, sortBy = "", []string{"phone"}, ""
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "columns must be among id, email, username, name, active, domain, localPart: phone")
+	})
+
+	t.Run("invalid sortBy", func(t *testing.T) {
+		userOutputFormat, userColumns, sortBy = "", nil, "phone"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "sortBy must be one of id, email, username, name, active, domain, localPart: phone")
+	})
+}
+
 func TestWriteQuotedRecord(t *testing.T) {
 	tests := []struct {
 		name     string
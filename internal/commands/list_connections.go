@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/client"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/config"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/spf13/cobra"
+)
+
+// connectionLister defines the interface for listing the SSO connections of a group.
+type connectionLister interface {
+	GetConnectionDetails(groupID string, logger *zerolog.Logger) ([]sso.ConnectionDetails, error)
+}
+
+func ListConnections(logger *zerolog.Logger) *cobra.Command {
+	listConnectionsCmd := cobra.Command{
+		Use:   "list-connections [groupID]",
+		Short: "List the ID, name, type and user count of every SSO connection of a group, to select one with --connection",
+		Args: func(_ *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				msg := fmt.Sprintf("expected groupID argument, got %d", len(args))
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+			if _, err := uuid.Parse(args[0]); err != nil {
+				msg := fmt.Sprintf("groupID must be a valid UUID: %s", args[0])
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+			if outputFormat != "" && outputFormat != outputFormatTable && outputFormat != outputFormatCSV && outputFormat != outputFormatJSON {
+				msg := fmt.Sprintf("output must be one of %s, %s or %s: %s", outputFormatTable, outputFormatCSV, outputFormatJSON, outputFormat)
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+			return nil
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			c := client.New(config.New(), logger)
+			sc := sso.New(c)
+			return runListConnections(args, os.Stdout, logger, sc)
+		},
+	}
+	return &listConnectionsCmd
+}
+
+func runListConnections(args []string, w io.Writer, logger *zerolog.Logger, cl connectionLister) error {
+	groupID := args[0]
+
+	connections, err := cl.GetConnectionDetails(groupID, logger)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to get SSO connections of groupID: %s", groupID)
+		return err
+	}
+
+	switch outputFormat {
+	case outputFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(connections); err != nil {
+			logger.Error().Err(err).Msg("failed to write connections")
+			return err
+		}
+	case outputFormatCSV:
+		if err := writeQuotedRecord(w, []string{"id", "name", "type", "users"}); err != nil {
+			logger.Error().Err(err).Msg("failed to write csv header")
+			return err
+		}
+		for _, c := range connections {
+			if err := writeQuotedRecord(w, []string{c.ID, c.Name, c.Type, strconv.Itoa(c.Users)}); err != nil {
+				logger.Error().Err(err).Msg("failed to write csv record")
+				return err
+			}
+		}
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tTYPE\tUSERS")
+		for _, c := range connections {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", c.ID, c.Name, c.Type, c.Users)
+		}
+		if err := tw.Flush(); err != nil {
+			logger.Error().Err(err).Msg("failed to write connections")
+			return err
+		}
+	}
+	return nil
+}
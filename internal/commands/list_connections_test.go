@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockConnectionLister is a mock for the connectionLister interface
+type mockConnectionLister struct {
+	mock.Mock
+}
+
+func (m *mockConnectionLister) GetConnectionDetails(groupID string, logger *zerolog.Logger) ([]sso.ConnectionDetails, error) {
+	args := m.Called(groupID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]sso.ConnectionDetails), args.Error(1)
+}
+
+func TestRunListConnections(t *testing.T) {
+	logger := zerolog.Nop()
+	defer func() { outputFormat = "" }()
+	cl := new(mockConnectionLister)
+	cl.On("GetConnectionDetails", "group-id").Return([]sso.ConnectionDetails{
+		{ID: "connection-1", Name: "Okta", Type: "sso_connection", Users: 12},
+		{ID: "connection-2", Name: "Entra ID", Type: "sso_connection", Users: 3},
+	}, nil)
+
+	t.Run("table output", func(t *testing.T) {
+		outputFormat = outputFormatTable
+		var b bytes.Buffer
+		assert.NoError(t, runListConnections([]string{"group-id"}, &b, &logger, cl))
+		assert.Equal(t, "ID            NAME      TYPE            USERS\n"+
+			"connection-1  Okta      sso_connection  12\n"+
+			"connection-2  Entra ID  sso_connection  3\n", b.String())
+	})
+
+	t.Run("csv output", func(t *testing.T) {
+		outputFormat = outputFormatCSV
+		var b bytes.Buffer
+		assert.NoError(t, runListConnections([]string{"group-id"}, &b, &logger, cl))
+		assert.Equal(t, `"id","name","type","users"`+"\n"+
+			`"connection-1","Okta","sso_connection","12"`+"\n"+
+			`"connection-2","Entra ID","sso_connection","3"`+"\n", b.String())
+	})
+
+	t.Run("json output", func(t *testing.T) {
+		outputFormat = outputFormatJSON
+		var b bytes.Buffer
+		assert.NoError(t, runListConnections([]string{"group-id"}, &b, &logger, cl))
+		var connections []sso.ConnectionDetails
+		assert.NoError(t, json.Unmarshal(b.Bytes(), &connections))
+		assert.Len(t, connections, 2)
+		assert.Equal(t, 12, connections[0].Users)
+	})
+
+	t.Run("connections error", func(t *testing.T) {
+		failing := new(mockConnectionLister)
+		failing.On("GetConnectionDetails", "group-id").Return(nil, errors.New("get error"))
+		var b bytes.Buffer
+		assert.EqualError(t, runListConnections([]string{"group-id"}, &b, &logger, failing), "get error")
+	})
+}
+
+func TestListConnections_Args(t *testing.T) {
+	logger := zerolog.Nop()
+	cmd := ListConnections(&logger)
+	validUUID := "123e4567-e89b-12d3-a456-426614174000"
+	defer func() { outputFormat = "" }()
+
+	outputFormat = outputFormatCSV
+	assert.NoError(t, cmd.Args(cmd, []string{validUUID}))
+	assert.EqualError(t, cmd.Args(cmd, []string{"invalid"}), "groupID must be a valid UUID: invalid")
+
+	outputFormat = "yaml"
+	assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "output must be one of table, csv or json: yaml")
+}
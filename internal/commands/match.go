@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/client"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/config"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputFormatTable = "table"
+	outputFormatJSON  = "json"
+	outputFormatCSV   = "csv"
+)
+
+func MatchUsers(logger *zerolog.Logger) *cobra.Command {
+	matchCmd := cobra.Command{
+		Use:   "match [groupID] [sourceIdentifier...]",
+		Short: "Explains the destination SSO user matched by sync to every source user, or to the given source users, without modifying any membership",
+		Args: func(_ *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				msg := fmt.Sprintf("expected groupID argument, got %d", len(args))
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+
+			if _, err := uuid.Parse(args[0]); err != nil {
+				msg := fmt.Sprintf("groupID must be a valid UUID: %s", args[0])
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+
+			if err := validateDomains(domains, domainMap); err != nil {
+				logger.Error().Msg(err.Error())
+				return err
+			}
+			var domainRegexp = regexp.MustCompile(`^(?:[a-zA-Z0-9-]+\.)+[a-zA-Z]{2,}$`)
+			if ssoDomain != "" && !domainRegexp.MatchString(ssoDomain) {
+				msg := fmt.Sprintf("ssoDomain must be a valid domain name: %s", ssoDomain)
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+			if err := validateDomainPairs(domains, ssoDomain, domainMap, matchToLocalPart, false); err != nil {
+				logger.Error().Msg(err.Error())
+				return err
+			}
+
+			if transformFilePath != "" {
+				if _, err := os.Stat(transformFilePath); os.IsNotExist(err) {
+					msg := fmt.Sprintf("transformFile does not exist: %s", transformFilePath)
+					logger.Error().Msg(msg)
+					return fmt.Errorf("%s", msg)
+				}
+			}
+
+			if overridesFilePath != "" {
+				if _, err := os.Stat(overridesFilePath); os.IsNotExist(err) {
+					msg := fmt.Sprintf("overridesFile does not exist: %s", overridesFilePath)
+					logger.Error().Msg(msg)
+					return fmt.Errorf("%s", msg)
+				}
+			}
+
+			if outputFormat != "" && outputFormat != outputFormatTable && outputFormat != outputFormatJSON {
+				msg := fmt.Sprintf("output must be one of %s or %s: %s", outputFormatTable, outputFormatJSON, outputFormat)
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+			return nil
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			c := client.New(config.New(), logger)
+			sc := sso.New(c).WithConnection(connection)
+			return runMatch(args, logger, sc)
+		},
+	}
+	return &matchCmd
+}
+
+func runMatch(args []string, logger *zerolog.Logger, sc userFetcher) error {
+	groupID := args[0]
+
+	match := membership.MatchOptions{
+		Domains:          domains,
+		SSODomain:        ssoDomain,
+		DomainMap:        domainMap,
+		MatchByUserName:  matchByUserName,
+		MatchToLocalPart: matchToLocalPart,
+	}
+	if transformFilePath != "" {
+		transforms, err := readTransformFile(transformFilePath, logger)
+		if err != nil {
+			return err
+		}
+		match.Transforms = transforms
+	}
+
+	ssoUsers, err := sc.GetUsers(groupID, logger)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get SSO users")
+		return err
+	}
+
+	if overridesFilePath != "" {
+		overrides, err := readOverridesFile(overridesFilePath, logger)
+		if err != nil {
+			return err
+		}
+		if err := membership.ValidateOverrides(*ssoUsers, overrides); err != nil {
+			logger.Error().Err(err).Msg("Invalid overrides file")
+			return err
+		}
+		match.Overrides = overrides
+	}
+
+	explanations := membership.ExplainMatches(*ssoUsers, match, args[1:])
+	if outputFormat == outputFormatJSON {
+		return membership.WriteExplanationsJSON(os.Stdout, explanations)
+	}
+	return membership.WriteExplanationsTable(os.Stdout, explanations, explain)
+}
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMatchUsersArgs(t *testing.T) {
+	logger := zerolog.Nop()
+	cmd := MatchUsers(&logger)
+	validUUID := "123e4567-e89b-12d3-a456-426614174000"
+	defer func() { domains, ssoDomain, transformFilePath, outputFormat = nil, "", "", "" }()
+
+	t.Run("valid arguments", func(t *testing.T) {
+		domains, ssoDomain, transformFilePath = []string{"example.com"}, "sso.example.com", ""
+		assert.NoError(t, cmd.Args(cmd, []string{validUUID}))
+	})
+
+	t.Run("same domain and ssoDomain", func(t *testing.T) {
+		domains, ssoDomain, transformFilePath = []string{"example.com"}, "example.com", ""
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "domain and ssoDomain must be different")
+	})
+
+	t.Run("source identifiers", func(t *testing.T) {
+		domains, ssoDomain, transformFilePath = []string{"example.com"}, "sso.example.com", ""
+		assert.NoError(t, cmd.Args(cmd, []string{validUUID, "user1@example.com", "user2@example.com"}))
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		domains, ssoDomain, transformFilePath, outputFormat = []string{"example.com"}, "sso.example.com", "", "xml"
+		defer func() { outputFormat = "" }()
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "output must be one of table or json: xml")
+	})
+
+	t.Run("missing transform file", func(t *testing.T) {
+		domains, ssoDomain, transformFilePath = []string{"example.com"}, "sso.example.com", "/path/to/nonexistent.json"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "transformFile does not exist: /path/to/nonexistent.json")
+	})
+}
+
+func TestRunMatch(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "123e4567-e89b-12d3-a456-426614174000"
+	defer func() { domains, ssoDomain, transformFilePath, explain, outputFormat = nil, "", "", false, "" }()
+
+	transformFile := writeTempPlanFile(t, `{"rules": [{"type": "replace", "from": "_", "to": "."}, {"type": "lowercase"}]}`)
+	defer os.Remove(transformFile)
+	domains, ssoDomain, transformFilePath = []string{"old.com"}, "new.com", transformFile
+
+	users := &sso.Users{Data: []sso.User{
+		makeUser("src-1", "John_Doe@old.com", "John_Doe@old.com"),
+		makeUser("src-2", "jane@old.com", "jane@old.com"),
+		makeUser("dst-1", "john.doe@new.com", "john.doe"),
+	}}
+	runMatchOutput := func(t *testing.T, args []string) string {
+		sc := new(mockSSOGetter)
+		sc.On("GetUsers", groupID, mock.Anything).Return(users, nil)
+
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := runMatch(args, &logger, sc)
+		w.Close()
+		os.Stdout = oldStdout
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+
+		assert.NoError(t, err)
+		sc.AssertExpectations(t)
+		return buf.String()
+	}
+
+	t.Run("explain table", func(t *testing.T) {
+		explain, outputFormat = true, outputFormatTable
+		expected := "SOURCE            STEP                                                                         RESULT\n" +
+			"John_Doe@old.com  source: email and username are set                                           pass\n" +
+			"John_Doe@old.com  source: email on domain old.com                                              pass\n" +
+			"John_Doe@old.com  source: username is an RFC5322 address                                       pass\n" +
+			"John_Doe@old.com  transform: replace \"_\" -> \".\"                                                John_Doe -> John.Doe\n" +
+			"John_Doe@old.com  transform: lowercase                                                         John.Doe -> john.doe\n" +
+			"John_Doe@old.com  candidate dst-1 (john.doe@new.com, john.doe): email equals john.doe@new.com  pass\n" +
+			"John_Doe@old.com  decision                                                                     matched john.doe@new.com (dst-1)\n" +
+			"Match: 1 Users, 1 matched, 0 not matched\n"
+		assert.Equal(t, expected, runMatchOutput(t, []string{groupID, "John_Doe@old.com"}))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		explain, outputFormat = false, outputFormatJSON
+		var explanations []membership.MatchExplanation
+		assert.NoError(t, json.Unmarshal([]byte(runMatchOutput(t, []string{groupID})), &explanations))
+		assert.Len(t, explanations, 2)
+		assert.Equal(t, membership.DecisionMatched, explanations[0].Decision)
+		assert.Equal(t, "dst-1", explanations[0].DestinationUserID)
+		assert.Equal(t, membership.DecisionNoDestinationUser, explanations[1].Decision)
+	})
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/client"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/config"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
+	"github.com/spf13/cobra"
+)
+
+// snapshotRestorer defines the interface for restoring the memberships of a snapshot needed by rollback.
+type snapshotRestorer interface {
+	PlanRollback(snapshot *membership.Snapshot, logger *zerolog.Logger) (*membership.Plan, error)
+	RollbackMemberships(snapshot *membership.Snapshot, logger *zerolog.Logger) error
+}
+
+func Rollback(logger *zerolog.Logger) *cobra.Command {
+	rollbackCmd := cobra.Command{
+		Use:   "rollback [snapshotFile]",
+		Short: "Restores exactly the Group and Org Memberships recorded in a snapshot written by sync or apply",
+		Args: func(_ *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				msg := fmt.Sprintf("expected snapshotFile argument, got %d", len(args))
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+			if _, err := os.Stat(args[0]); os.IsNotExist(err) {
+				msg := fmt.Sprintf("snapshotFile does not exist: %s", args[0])
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+			return nil
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			c := client.New(config.New(), logger)
+			mc := membership.New(c)
+			return runRollback(args, logger, mc)
+		},
+	}
+	return &rollbackCmd
+}
+
+func runRollback(args []string, logger *zerolog.Logger, sr snapshotRestorer) error {
+	snapshot, err := readSnapshotFile(args[0], logger)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		// compute the membership changes restoring the snapshot without modifying any membership
+		plan, err := sr.PlanRollback(snapshot, logger)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to plan rollback")
+			return err
+		}
+		return plan.Write(os.Stdout)
+	}
+
+	logger.Info().Msgf("Rolling back memberships of %d Users on groupID: %s", len(snapshot.Users), snapshot.GroupID)
+	if err := sr.RollbackMemberships(snapshot, logger); err != nil {
+		logger.Error().Err(err).Msg("Failed to rollback memberships")
+		return err
+	}
+	return nil
+}
+
+// readSnapshotFile reads a machine-readable snapshot written by sync or apply.
+func readSnapshotFile(filePath string, logger *zerolog.Logger) (*membership.Snapshot, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to open snapshot file: %s", filePath)
+		return nil, err
+	}
+	defer file.Close()
+
+	snapshot, err := membership.ReadSnapshot(file)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to read snapshot file: %s", filePath)
+		return nil, err
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockSnapshotRestorer is a mock for the snapshotRestorer interface
+type mockSnapshotRestorer struct {
+	mock.Mock
+}
+
+func (m *mockSnapshotRestorer) PlanRollback(snapshot *membership.Snapshot, logger *zerolog.Logger) (*membership.Plan, error) {
+	args := m.Called(snapshot, logger)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*membership.Plan), args.Error(1)
+}
+
+func (m *mockSnapshotRestorer) RollbackMemberships(snapshot *membership.Snapshot, logger *zerolog.Logger) error {
+	args := m.Called(snapshot, logger)
+	return args.Error(0)
+}
+
+func TestRollbackCommand_Args(t *testing.T) {
+	logger := zerolog.Nop()
+	cmd := Rollback(&logger)
+
+	t.Run("invalid number of arguments", func(t *testing.T) {
+		err := cmd.Args(cmd, []string{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "expected snapshotFile argument")
+	})
+
+	t.Run("missing snapshot file", func(t *testing.T) {
+		err := cmd.Args(cmd, []string{"/path/to/nonexistent.json"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "snapshotFile does not exist")
+	})
+}
+
+func TestRunRollback(t *testing.T) {
+	logger := zerolog.Nop()
+	snapshotContent := `{"groupId":"group-id","users":[{"identifier":"user1@destination.com","userId":"dst-1","memberships":[]}]}`
+
+	t.Run("restores the snapshot file", func(t *testing.T) {
+		snapshotFile := writeTempPlanFile(t, snapshotContent)
+		defer os.Remove(snapshotFile)
+
+		sr := new(mockSnapshotRestorer)
+		sr.On("RollbackMemberships", mock.MatchedBy(func(snapshot *membership.Snapshot) bool {
+			return snapshot.GroupID == "group-id" && len(snapshot.Users) == 1 && snapshot.Users[0].UserID == "dst-1"
+		}), mock.Anything).Return(nil)
+
+		err := runRollback([]string{snapshotFile}, &logger, sr)
+		assert.NoError(t, err)
+		sr.AssertExpectations(t)
+		sr.AssertNotCalled(t, "PlanRollback", mock.Anything, mock.Anything)
+	})
+
+	t.Run("dry run only plans the rollback", func(t *testing.T) {
+		snapshotFile := writeTempPlanFile(t, snapshotContent)
+		defer os.Remove(snapshotFile)
+		dryRun = true
+		defer func() { dryRun = false }()
+
+		sr := new(mockSnapshotRestorer)
+		sr.On("PlanRollback", mock.Anything, mock.Anything).Return(&membership.Plan{GroupID: "group-id"}, nil)
+
+		err := runRollback([]string{snapshotFile}, &logger, sr)
+		assert.NoError(t, err)
+		sr.AssertExpectations(t)
+		sr.AssertNotCalled(t, "RollbackMemberships", mock.Anything, mock.Anything)
+	})
+
+	t.Run("returns rollback error", func(t *testing.T) {
+		snapshotFile := writeTempPlanFile(t, snapshotContent)
+		defer os.Remove(snapshotFile)
+
+		sr := new(mockSnapshotRestorer)
+		sr.On("RollbackMemberships", mock.Anything, mock.Anything).Return(errors.New("api error"))
+
+		err := runRollback([]string{snapshotFile}, &logger, sr)
+		assert.EqualError(t, err, "api error")
+		sr.AssertExpectations(t)
+	})
+
+	t.Run("invalid snapshot file", func(t *testing.T) {
+		snapshotFile := writeTempPlanFile(t, `{"users":[]}`)
+		defer os.Remove(snapshotFile)
+
+		sr := new(mockSnapshotRestorer)
+		err := runRollback([]string{snapshotFile}, &logger, sr)
+		assert.EqualError(t, err, "snapshot has no groupId")
+		sr.AssertNotCalled(t, "RollbackMemberships", mock.Anything, mock.Anything)
+	})
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"encoding/csv"
 	"fmt"
 	"os"
 	"regexp"
@@ -15,79 +16,71 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const (
+	reportFormatJSON = "json"
+	reportFormatCSV  = "csv"
+)
+
 func SyncMemberships(logger *zerolog.Logger) *cobra.Command {
 	syncCmd := cobra.Command{
 		Use:   "sync [groupID]",
 		Short: "Synchronizes SSO user assigned Group and Org Memberships",
 		Args: func(_ *cobra.Command, args []string) error {
-			if len(args) != 1 {
-				return fmt.Errorf("expected groupID, arguments specified: %d", len(args))
-			}
-
+			return validateSyncArgs(args, logger)
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
 			groupID := args[0]
-			// Validate groupID and the flags
-			_, err := uuid.Parse(groupID)
+			run, err := newSyncRun(groupID, logger)
 			if err != nil {
-				logger.Error().Msgf("groupID must be a valid UUID: %s", args[0])
-				return fmt.Errorf("groupID must be a valid UUID: %s", args[0])
+				return err
 			}
-
-			var domainRegexp = regexp.MustCompile(`^(?:[a-zA-Z0-9-]+\.)+[a-zA-Z]{2,}$`)
-			if !domainRegexp.MatchString(domain) {
-				logger.Error().Msgf("domain must be a valid domain name: %s", domain)
-				return fmt.Errorf("domain must be a valid domain name: %s", domain)
-			}
-			if ssoDomain != "" && !domainRegexp.MatchString(ssoDomain) {
-				logger.Error().Msgf("ssoDomain must be a valid domain name: %s", ssoDomain)
-				return fmt.Errorf("ssoDomain must be a valid domain name: %s", ssoDomain)
-			}
-			if domain == ssoDomain {
-				logger.Error().Msg("domain and ssoDomain must be different")
-				return fmt.Errorf("domain and ssoDomain must be different")
+			if len(run.sourceUsers.Data) == 0 {
+				logger.Info().Msgf("No corresponding SSO users found on groupID: %s, no Users to synchronize", groupID)
+				return nil
 			}
 
-			if csvFilePath != "" {
-				if _, err := os.Stat(csvFilePath); os.IsNotExist(err) {
-					logger.Error().Msgf("csvFile does not exist: %s", csvFilePath)
-					return fmt.Errorf("csvFile does not exist: %s", csvFilePath)
-				}
+			if resumeFilePath != "" {
+				// skip the users completed by the interrupted sync and keep recording progress in its journal
+				run.opts.JournalPath = resumeFilePath
+				run.opts.Resume = true
 			}
-			return nil
-		},
-		RunE: func(_ *cobra.Command, args []string) error {
-			groupID := args[0]
-
-			// instantiate a new client and sso service
-			c := client.New(config.New(), logger)
-			sc := sso.New(c)
-			// get all sso users
-			ssoUsers, err := sc.GetUsers(groupID, logger)
-			if err != nil {
-				logger.Fatal().Err(err).Msg("Failed to get SSO users")
+			if dryRun || planFilePath != "" {
+				// compute the membership changes as a plan without modifying any membership
+				plan := run.plan(logger)
+				if conflictsFilePath != "" {
+					if err := writeConflictsFile(conflictsFilePath, plan.Conflicts, logger); err != nil {
+						return err
+					}
+				}
+				if planFilePath != "" {
+					if err := writePlanFile(planFilePath, plan, logger); err != nil {
+						return err
+					}
+				}
+				if dryRun {
+					return plan.Write(os.Stdout)
+				}
+				return nil
 			}
-
-			if csvFilePath != "" {
-				csvEmails, err := readCsvFile(csvFilePath, logger)
-
-				if err != nil {
-					logger.Error().Err(err).Msg("Failed to read CSV file")
+			// synchronize Group and Org memberships of matching users of domain to ssoDomain
+			report, err := run.synchronize(logger)
+			if report != nil && reportFilePath != "" {
+				if err := writeReportFile(reportFilePath, reportFormat, report, logger); err != nil {
 					return err
 				}
-				if len(csvEmails) == 0 {
-					logger.Error().Msg("CSV file is empty")
-					return fmt.Errorf("CSV file is empty")
+			}
+			if report != nil && conflictsFilePath != "" {
+				if err := writeConflictsFile(conflictsFilePath, report.Conflicts, logger); err != nil {
+					return err
 				}
-				// filter SSO individuals with provided CSV emails and include their corresponding provisioned email in the SSO domain
-				filteredUserData := filterUsers(csvEmails, *ssoUsers, true, matchByUserName, matchToLocalPart, logger)
-				ssoUsers.Data = filteredUserData
 			}
-
-			if len(ssoUsers.Data) > 0 {
-				mc := membership.New(c)
-				// synchronize Group and Org memberships of matching users of domain to ssoDomain
-				mc.SyncMemberships(groupID, domain, ssoDomain, *ssoUsers, matchByUserName, matchToLocalPart, logger)
-			} else {
-				logger.Info().Msgf("No corresponding SSO users found on groupID: %s, no Users to synchronize", groupID)
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to synchronize memberships")
+				return err
+			}
+			if err := report.Err(); err != nil {
+				logger.Error().Err(err).Msg("Failed to synchronize memberships")
+				return err
 			}
 			return nil
 		},
@@ -95,9 +88,530 @@ func SyncMemberships(logger *zerolog.Logger) *cobra.Command {
 	return &syncCmd
 }
 
+// validateSyncArgs validates the groupID and the flags shared by the sync and verify commands.
+func validateSyncArgs(args []string, logger *zerolog.Logger) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected groupID, arguments specified: %d", len(args))
+	}
+
+	groupID := args[0]
+	// Validate groupID and the flags
+	_, err := uuid.Parse(groupID)
+	if err != nil {
+		logger.Error().Msgf("groupID must be a valid UUID: %s", args[0])
+		return fmt.Errorf("groupID must be a valid UUID: %s", args[0])
+	}
+	if destinationGroupID != "" {
+		if _, err := uuid.Parse(destinationGroupID); err != nil {
+			logger.Error().Msgf("destinationGroup must be a valid UUID: %s", destinationGroupID)
+			return fmt.Errorf("destinationGroup must be a valid UUID: %s", destinationGroupID)
+		}
+		if destinationGroupID == groupID {
+			logger.Error().Msg("destinationGroup and groupID must be different")
+			return fmt.Errorf("destinationGroup and groupID must be different")
+		}
+	}
+	if destinationGroupID == "" && destinationConnection != "" && strings.EqualFold(destinationConnection, connection) {
+		logger.Error().Msg("destinationConnection and connection must be different")
+		return fmt.Errorf("destinationConnection and connection must be different")
+	}
+
+	// the domains are only required to match users by their domain, a mapping file pairs users explicitly
+	if mappingFilePath == "" || len(domains) > 0 {
+		if err := validateDomains(domains, domainMap); err != nil {
+			logger.Error().Msg(err.Error())
+			return err
+		}
+	}
+	var domainRegexp = regexp.MustCompile(`^(?:[a-zA-Z0-9-]+\.)+[a-zA-Z]{2,}$`)
+	if ssoDomain != "" && !domainRegexp.MatchString(ssoDomain) {
+		logger.Error().Msgf("ssoDomain must be a valid domain name: %s", ssoDomain)
+		return fmt.Errorf("ssoDomain must be a valid domain name: %s", ssoDomain)
+	}
+	if mappingFilePath == "" {
+		if err := validateDomainPairs(domains, ssoDomain, domainMap, matchToLocalPart, separateDestination()); err != nil {
+			logger.Error().Msg(err.Error())
+			return err
+		}
+	}
+
+	if mappingFilePath != "" {
+		if _, err := os.Stat(mappingFilePath); os.IsNotExist(err) {
+			logger.Error().Msgf("mappingFile does not exist: %s", mappingFilePath)
+			return fmt.Errorf("mappingFile does not exist: %s", mappingFilePath)
+		}
+	}
+
+	if orgMappingFilePath != "" {
+		if _, err := os.Stat(orgMappingFilePath); os.IsNotExist(err) {
+			logger.Error().Msgf("orgMappingFile does not exist: %s", orgMappingFilePath)
+			return fmt.Errorf("orgMappingFile does not exist: %s", orgMappingFilePath)
+		}
+	}
+
+	if csvFilePath != "" {
+		if _, err := os.Stat(csvFilePath); os.IsNotExist(err) {
+			logger.Error().Msgf("csvFile does not exist: %s", csvFilePath)
+			return fmt.Errorf("csvFile does not exist: %s", csvFilePath)
+		}
+	}
+
+	if transformFilePath != "" {
+		if _, err := os.Stat(transformFilePath); os.IsNotExist(err) {
+			logger.Error().Msgf("transformFile does not exist: %s", transformFilePath)
+			return fmt.Errorf("transformFile does not exist: %s", transformFilePath)
+		}
+	}
+
+	if roleMappingFilePath != "" {
+		if _, err := os.Stat(roleMappingFilePath); os.IsNotExist(err) {
+			logger.Error().Msgf("roleMappingFile does not exist: %s", roleMappingFilePath)
+			return fmt.Errorf("roleMappingFile does not exist: %s", roleMappingFilePath)
+		}
+	}
+	if err := membership.ValidateUnmappedRoles(unmappedRoles); err != nil {
+		logger.Error().Msg(err.Error())
+		return err
+	}
+	if unmappedRoles == membership.UnmappedRolesReject && roleMappingFilePath == "" {
+		logger.Error().Msgf("unmappedRoles %s requires a roleMappingFile", unmappedRoles)
+		return fmt.Errorf("unmappedRoles %s requires a roleMappingFile", unmappedRoles)
+	}
+
+	if includeOrgsFilePath != "" {
+		if _, err := os.Stat(includeOrgsFilePath); os.IsNotExist(err) {
+			logger.Error().Msgf("includeOrgsFile does not exist: %s", includeOrgsFilePath)
+			return fmt.Errorf("includeOrgsFile does not exist: %s", includeOrgsFilePath)
+		}
+	}
+	if excludeOrgsFilePath != "" {
+		if _, err := os.Stat(excludeOrgsFilePath); os.IsNotExist(err) {
+			logger.Error().Msgf("excludeOrgsFile does not exist: %s", excludeOrgsFilePath)
+			return fmt.Errorf("excludeOrgsFile does not exist: %s", excludeOrgsFilePath)
+		}
+	}
+
+	if overridesFilePath != "" {
+		if _, err := os.Stat(overridesFilePath); os.IsNotExist(err) {
+			logger.Error().Msgf("overridesFile does not exist: %s", overridesFilePath)
+			return fmt.Errorf("overridesFile does not exist: %s", overridesFilePath)
+		}
+	}
+
+	if resumeFilePath != "" {
+		if _, err := os.Stat(resumeFilePath); os.IsNotExist(err) {
+			logger.Error().Msgf("journal file to resume does not exist: %s", resumeFilePath)
+			return fmt.Errorf("journal file to resume does not exist: %s", resumeFilePath)
+		}
+	}
+
+	if concurrency < 0 {
+		logger.Error().Msgf("concurrency must not be negative: %d", concurrency)
+		return fmt.Errorf("concurrency must not be negative: %d", concurrency)
+	}
+
+	if reportFormat != "" && reportFormat != reportFormatJSON && reportFormat != reportFormatCSV {
+		logger.Error().Msgf("reportFormat must be one of %s or %s: %s", reportFormatJSON, reportFormatCSV, reportFormat)
+		return fmt.Errorf("reportFormat must be one of %s or %s: %s", reportFormatJSON, reportFormatCSV, reportFormat)
+	}
+
+	if err := membership.ValidateMode(syncMode); err != nil {
+		logger.Error().Msg(err.Error())
+		return err
+	}
+	if err := membership.ValidateRetireSource(retireSource, snapshotFilePath); err != nil {
+		logger.Error().Msg(err.Error())
+		return err
+	}
+	return nil
+}
+
+// syncRun holds the users and the options of a synchronization, shared by the sync and verify commands.
+type syncRun struct {
+	mc               *membership.Client
+	groupID          string
+	sourceUsers      *sso.Users
+	destinationUsers *sso.Users
+	match            membership.MatchOptions
+	migration        *membership.GroupMigration
+	opts             membership.SyncOptions
+}
+
+// newSyncRun fetches the SSO users of the groups and reads the files of the flags.
+// The orgs of the groups are only looked up when there are SSO users to synchronize.
+func newSyncRun(groupID string, logger *zerolog.Logger) (*syncRun, error) {
+	// instantiate a new client and sso service
+	c := client.New(config.New(), logger)
+	sc := sso.New(c).WithConnection(connection)
+	// get all sso users
+	ssoUsers, err := sc.GetUsers(groupID, logger)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get SSO users")
+		return nil, err
+	}
+	// the destination users are the users of the group, unless migrating the memberships to another group or another connection
+	destinationUsers := ssoUsers
+	if destinationGroupID != "" {
+		destinationUsers, err = sso.New(c).WithConnection(destinationConnection).GetUsers(destinationGroupID, logger)
+		if err != nil {
+			logger.Error().Err(err).Msgf("Failed to get SSO users of destinationGroup: %s", destinationGroupID)
+			return nil, err
+		}
+	} else if destinationConnection != "" {
+		destinationUsers, err = sso.New(c).WithConnection(destinationConnection).GetUsers(groupID, logger)
+		if err != nil {
+			logger.Error().Err(err).Msgf("Failed to get SSO users of destinationConnection: %s", destinationConnection)
+			return nil, err
+		}
+	}
+
+	var transforms *membership.Transforms
+	if transformFilePath != "" {
+		// rewrite the local part of the source users before looking up their destination user
+		transforms, err = readTransformFile(transformFilePath, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var roles *membership.RoleMapping
+	if roleMappingFilePath != "" {
+		// translate the roles of the memberships recreated for the destination users
+		roles, err = readRoleMappingFile(roleMappingFilePath, unmappedRoles, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if csvFilePath != "" {
+		csvEmails, err := readCsvFile(csvFilePath, logger)
+
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to read CSV file")
+			return nil, err
+		}
+		if len(csvEmails) == 0 {
+			logger.Error().Msg("CSV file is empty")
+			return nil, fmt.Errorf("CSV file is empty")
+		}
+		// filter SSO individuals with provided CSV emails and include their corresponding provisioned email in the SSO domain,
+		// the provisioned users of a migration are looked up in the destination group or connection instead
+		filteredUserData := filterUsers(csvEmails, *ssoUsers, !separateDestination(), matchByUserName, matchToLocalPart, transforms, logger)
+		ssoUsers.Data = filteredUserData
+	}
+
+	match := membership.MatchOptions{
+		Domains:          domains,
+		SSODomain:        ssoDomain,
+		DomainMap:        domainMap,
+		MatchByUserName:  matchByUserName,
+		MatchToLocalPart: matchToLocalPart,
+		Transforms:       transforms,
+	}
+	if mappingFilePath != "" {
+		// pair users exactly as listed in the mapping file instead of by their domain
+		mapping, err := readMappingFile(mappingFilePath, logger)
+		if err != nil {
+			return nil, err
+		}
+		if err := membership.ValidateMigrationMapping(*ssoUsers, *destinationUsers, mapping); err != nil {
+			logger.Error().Err(err).Msg("Invalid mapping file")
+			return nil, err
+		}
+		match.Mapping = mapping
+	}
+	if overridesFilePath != "" {
+		// pair the conflicting users exactly as listed in the overrides file
+		overrides, err := readOverridesFile(overridesFilePath, logger)
+		if err != nil {
+			return nil, err
+		}
+		if err := membership.ValidateMigrationOverrides(*ssoUsers, *destinationUsers, overrides); err != nil {
+			logger.Error().Err(err).Msg("Invalid overrides file")
+			return nil, err
+		}
+		match.Overrides = overrides
+	}
+
+	mc := membership.New(c)
+	run := &syncRun{
+		mc:               mc,
+		groupID:          groupID,
+		sourceUsers:      ssoUsers,
+		destinationUsers: destinationUsers,
+		match:            match,
+	}
+	if len(ssoUsers.Data) == 0 {
+		return run, nil
+	}
+	if destinationGroupID != "" {
+		// reproduce the memberships of the mapped orgs in the destination group
+		run.migration, err = readGroupMigration(mc, groupID, destinationGroupID, orgMappingFilePath, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var orgFilter *membership.OrgFilter
+	if len(includeOrgs) > 0 || len(excludeOrgs) > 0 || includeOrgsFilePath != "" || excludeOrgsFilePath != "" {
+		// restrict the org memberships recreated and deleted to the allowed orgs of the groups
+		groupIDs := []string{groupID}
+		if destinationGroupID != "" {
+			groupIDs = append(groupIDs, destinationGroupID)
+		}
+		orgFilter, err = readOrgFilter(mc, groupIDs, includeOrgs, excludeOrgs, includeOrgsFilePath, excludeOrgsFilePath, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	run.opts = membership.SyncOptions{
+		Mode:             syncMode,
+		RolePrecedence:   rolePrecedence,
+		SnapshotPath:     snapshotFilePath,
+		JournalPath:      journalFilePath,
+		Concurrency:      concurrency,
+		Roles:            roles,
+		Orgs:             orgFilter,
+		RetireSource:     retireSource,
+		SourceConnection: connection,
+	}
+	return run, nil
+}
+
+// plan computes the membership changes of the synchronization without modifying any membership.
+func (r *syncRun) plan(logger *zerolog.Logger) *membership.Plan {
+	if r.migration != nil {
+		return r.mc.PlanMigration(*r.migration, *r.sourceUsers, *r.destinationUsers, r.match, r.opts, logger)
+	}
+	if destinationConnection != "" {
+		return r.mc.PlanConnections(r.groupID, *r.sourceUsers, *r.destinationUsers, r.match, r.opts, logger)
+	}
+	return r.mc.PlanMemberships(r.groupID, *r.sourceUsers, r.match, r.opts, logger)
+}
+
+// synchronize issues the membership changes of the synchronization.
+func (r *syncRun) synchronize(logger *zerolog.Logger) (*membership.Report, error) {
+	if r.migration != nil {
+		return r.mc.MigrateMemberships(*r.migration, *r.sourceUsers, *r.destinationUsers, r.match, r.opts, logger)
+	}
+	if destinationConnection != "" {
+		return r.mc.SyncConnections(r.groupID, *r.sourceUsers, *r.destinationUsers, r.match, r.opts, logger)
+	}
+	return r.mc.SyncMemberships(r.groupID, *r.sourceUsers, r.match, r.opts, logger)
+}
+
+// separateDestination checks whether the destination users are looked up in another group or another SSO connection
+// than the source users.
+func separateDestination() bool {
+	return destinationGroupID != "" || destinationConnection != ""
+}
+
+// readMappingFile reads a two-column CSV file of source and destination user identifiers, an email or a username.
+// A first line with the source and destination headers is skipped.
+func readMappingFile(filePath string, logger *zerolog.Logger) ([]membership.UserMapping, error) {
+	return readUserMappingFile(filePath, "mapping", logger)
+}
+
+// readOverridesFile reads the source and destination user identifiers resolving the conflicts, in the format of the mapping file.
+func readOverridesFile(filePath string, logger *zerolog.Logger) ([]membership.UserMapping, error) {
+	return readUserMappingFile(filePath, "overrides", logger)
+}
+
+// readOrgMappingFile reads a two-column CSV file of source and destination orgs, each an ID, a slug or a name, in the format of the mapping file.
+func readOrgMappingFile(filePath string, logger *zerolog.Logger) ([]membership.OrgMapping, error) {
+	mapping, err := readUserMappingFile(filePath, "org mapping", logger)
+	if err != nil {
+		return nil, err
+	}
+	orgMapping := make([]membership.OrgMapping, 0, len(mapping))
+	for _, um := range mapping {
+		orgMapping = append(orgMapping, membership.OrgMapping{SourceOrg: um.SourceIdentifier, DestinationOrg: um.DestinationIdentifier})
+	}
+	return orgMapping, nil
+}
+
+// orgLister defines the interface for listing the orgs of a group.
+type orgLister interface {
+	GetGroupOrgs(groupID string) ([]membership.Org, error)
+}
+
+// readGroupMigration resolves the orgs of the org mapping file in the orgs of the source and the destination groups.
+func readGroupMigration(ol orgLister, sourceGroupID, destinationGroupID, filePath string, logger *zerolog.Logger) (*membership.GroupMigration, error) {
+	orgMapping, err := readOrgMappingFile(filePath, logger)
+	if err != nil {
+		return nil, err
+	}
+	sourceOrgs, err := ol.GetGroupOrgs(sourceGroupID)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to get orgs of groupID: %s", sourceGroupID)
+		return nil, err
+	}
+	destinationOrgs, err := ol.GetGroupOrgs(destinationGroupID)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to get orgs of groupID: %s", destinationGroupID)
+		return nil, err
+	}
+	orgs, err := membership.ResolveOrgMapping(sourceOrgs, destinationOrgs, orgMapping)
+	if err != nil {
+		logger.Error().Err(err).Msg("Invalid org mapping file")
+		return nil, err
+	}
+	logger.Info().Msgf("Migrating memberships of %d orgs from groupID: %s to groupID: %s", len(orgs), sourceGroupID, destinationGroupID)
+	return &membership.GroupMigration{SourceGroupID: sourceGroupID, DestinationGroupID: destinationGroupID, Orgs: orgs}, nil
+}
+
+// readOrgFilter builds the filter of the orgs matching the includeOrgs and excludeOrgs globs and the globs of their files,
+// looking up the slugs of the orgs of the groups.
+func readOrgFilter(ol orgLister, groupIDs, include, exclude []string, includeFilePath, excludeFilePath string, logger *zerolog.Logger) (*membership.OrgFilter, error) {
+	if includeFilePath != "" {
+		patterns, err := readOrgPatternsFile(includeFilePath, logger)
+		if err != nil {
+			return nil, err
+		}
+		include = append(append([]string{}, include...), patterns...)
+	}
+	if excludeFilePath != "" {
+		patterns, err := readOrgPatternsFile(excludeFilePath, logger)
+		if err != nil {
+			return nil, err
+		}
+		exclude = append(append([]string{}, exclude...), patterns...)
+	}
+
+	var orgs []membership.Org
+	for _, groupID := range groupIDs {
+		groupOrgs, err := ol.GetGroupOrgs(groupID)
+		if err != nil {
+			logger.Error().Err(err).Msgf("Failed to get orgs of groupID: %s", groupID)
+			return nil, err
+		}
+		orgs = append(orgs, groupOrgs...)
+	}
+	orgFilter, err := membership.NewOrgFilter(include, exclude, orgs)
+	if err != nil {
+		logger.Error().Msg(err.Error())
+		return nil, err
+	}
+	return orgFilter, nil
+}
+
+// readOrgPatternsFile reads the org globs of a file, one per line.
+func readOrgPatternsFile(filePath string, logger *zerolog.Logger) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to open org patterns file: %s", filePath)
+		return nil, err
+	}
+	defer file.Close()
+
+	patterns, err := membership.ReadOrgPatterns(file)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to read org patterns file: %s", filePath)
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// readUserMappingFile reads a two-column CSV file of source and destination identifiers, naming the file in the errors.
+func readUserMappingFile(filePath, name string, logger *zerolog.Logger) ([]membership.UserMapping, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to open %s file: %s", name, filePath)
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to read %s file: %s", name, filePath)
+		return nil, err
+	}
+
+	mapping := []membership.UserMapping{}
+	for i, record := range records {
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) < 2 || strings.TrimSpace(record[0]) == "" || strings.TrimSpace(record[1]) == "" {
+			err := fmt.Errorf("%s file line %d must have a source and a destination identifier", name, i+1)
+			logger.Error().Err(err).Send()
+			return nil, err
+		}
+		source, destination := strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		if i == 0 && strings.HasPrefix(strings.ToLower(source), "source") && strings.HasPrefix(strings.ToLower(destination), "destination") {
+			continue
+		}
+		mapping = append(mapping, membership.UserMapping{SourceIdentifier: source, DestinationIdentifier: destination})
+	}
+	if len(mapping) == 0 {
+		err := fmt.Errorf("%s file is empty", name)
+		logger.Error().Err(err).Send()
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// writePlanFile writes the machine-readable plan to be reviewed and executed later by the apply command.
+func writePlanFile(filePath string, plan *membership.Plan, logger *zerolog.Logger) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to create plan file: %s", filePath)
+		return err
+	}
+	defer file.Close()
+
+	if err := plan.WriteJSON(file); err != nil {
+		logger.Error().Err(err).Msgf("Failed to write plan file: %s", filePath)
+		return err
+	}
+	logger.Info().Msgf("Wrote plan of %d Users to: %s", len(plan.Users), filePath)
+	return nil
+}
+
+// writeReportFile writes the outcome of the synchronization of every user pair as JSON or CSV.
+func writeReportFile(filePath, format string, report *membership.Report, logger *zerolog.Logger) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to create report file: %s", filePath)
+		return err
+	}
+	defer file.Close()
+
+	if format == reportFormatCSV {
+		err = report.WriteCSV(file)
+	} else {
+		err = report.WriteJSON(file)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to write report file: %s", filePath)
+		return err
+	}
+	logger.Info().Msgf("Wrote report of %d Users to: %s", len(report.Users), filePath)
+	return nil
+}
+
+// writeConflictsFile writes a CSV line per conflicting source user and candidate destination user, to be resolved by an overrides file.
+func writeConflictsFile(filePath string, conflicts []membership.Conflict, logger *zerolog.Logger) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to create conflicts file: %s", filePath)
+		return err
+	}
+	defer file.Close()
+
+	if err := membership.WriteConflictsCSV(file, conflicts); err != nil {
+		logger.Error().Err(err).Msgf("Failed to write conflicts file: %s", filePath)
+		return err
+	}
+	logger.Info().Msgf("Wrote %d conflicts to: %s", len(conflicts), filePath)
+	return nil
+}
+
 // filterUsers filters the SSO users with provided CSV emails.
 // Depending on the includeSSODomain flag, this may include the corresponding same User on the SSO domain.
-func filterUsers(emails []string, users sso.Users, includeSSODomain, matchByUserName, matchToLocalPart bool, logger *zerolog.Logger) []sso.User {
+// The local part of the email is rewritten through the transforms, if not nil, before looking up the User on the SSO domain.
+func filterUsers(emails []string, users sso.Users, includeSSODomain, matchByUserName, matchToLocalPart bool, transforms *membership.Transforms, logger *zerolog.Logger) []sso.User {
 	var filteredUsers []sso.User
 	for _, email := range emails {
 		foundCount := 0
@@ -108,11 +622,18 @@ func filterUsers(emails []string, users sso.Users, includeSSODomain, matchByUser
 
 		if isValidEmailRFC5322(email) {
 			emailParts = strings.Split(email, "@")
-			localPart = emailParts[0]
+			localPart, _ = transforms.Apply(emailParts[0])
 		}
 
-		if includeSSODomain && ssoDomain != "" && localPart != "" {
-			provisionedEmail = localPart + "@" + ssoDomain
+		// the provisioned User is on the Sync Domain mapped to the domain of the email, if any
+		destination := ssoDomain
+		if localPart != "" {
+			if d, ok := domainMap[emailParts[1]]; ok {
+				destination = d
+			}
+		}
+		if includeSSODomain && destination != "" && localPart != "" {
+			provisionedEmail = localPart + "@" + destination
 		}
 
 		// iterate through SSO users looking up the domain User and the provisioned User on the SSO domain
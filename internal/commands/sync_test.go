@@ -6,8 +6,10 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
 	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 // Helper function to create a string pointer
@@ -21,18 +23,18 @@ func TestSyncMemberships_Args(t *testing.T) {
 	validUUID := uuid.New().String()
 
 	// Backup and restore package-level flag variables
-	oldDomain, oldSsoDomain, oldCsvFilePath := domain, ssoDomain, csvFilePath
+	oldDomains, oldSsoDomain, oldCsvFilePath := domains, ssoDomain, csvFilePath
 	defer func() {
-		domain, ssoDomain, csvFilePath = oldDomain, oldSsoDomain, oldCsvFilePath
+		domains, ssoDomain, csvFilePath = oldDomains, oldSsoDomain, oldCsvFilePath
 	}()
 
 	resetFlags := func() {
-		domain, ssoDomain, csvFilePath = "", "", ""
+		domains, ssoDomain, csvFilePath = nil, "", ""
 	}
 
 	t.Run("invalid number of arguments", func(t *testing.T) {
 		resetFlags()
-		domain = "example.com"
+		domains = []string{"example.com"}
 		ssoDomain = "sso.example.com"
 		err := cmd.Args(cmd, []string{})
 		assert.Error(t, err)
@@ -41,7 +43,7 @@ func TestSyncMemberships_Args(t *testing.T) {
 
 	t.Run("invalid groupID", func(t *testing.T) {
 		resetFlags()
-		domain = "example.com"
+		domains = []string{"example.com"}
 		ssoDomain = "sso.example.com"
 		err := cmd.Args(cmd, []string{"invalid-uuid"})
 		assert.Error(t, err)
@@ -50,7 +52,7 @@ func TestSyncMemberships_Args(t *testing.T) {
 
 	t.Run("invalid domain", func(t *testing.T) {
 		resetFlags()
-		domain = "invalid_domain_@@"
+		domains = []string{"invalid_domain_@@"}
 		ssoDomain = "example.com"
 		err := cmd.Args(cmd, []string{validUUID})
 		assert.Error(t, err)
@@ -59,7 +61,7 @@ func TestSyncMemberships_Args(t *testing.T) {
 
 	t.Run("valid domain, invalid ssoDomain", func(t *testing.T) {
 		resetFlags()
-		domain = "example.com"
+		domains = []string{"example.com"}
 		ssoDomain = "invalid_sso_domain_@@"
 		err := cmd.Args(cmd, []string{validUUID})
 		assert.Error(t, err)
@@ -68,15 +70,60 @@ func TestSyncMemberships_Args(t *testing.T) {
 
 	t.Run("valid domain and ssoDomain", func(t *testing.T) {
 		resetFlags()
-		domain = "example.com"
+		domains = []string{"example.com"}
 		ssoDomain = "sso.example.com"
 		err := cmd.Args(cmd, []string{validUUID})
 		assert.NoError(t, err)
 	})
 
+	t.Run("invalid mode", func(t *testing.T) {
+		resetFlags()
+		domains = []string{"example.com"}
+		ssoDomain = "sso.example.com"
+		oldSyncMode := syncMode
+		defer func() { syncMode = oldSyncMode }()
+		syncMode = "replace"
+		err := cmd.Args(cmd, []string{validUUID})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "mode must be one of mirror or merge")
+	})
+
+	t.Run("missing journal file to resume", func(t *testing.T) {
+		resetFlags()
+		domains = []string{"example.com"}
+		ssoDomain = "sso.example.com"
+		defer func() { resumeFilePath = "" }()
+		resumeFilePath = "/path/to/nonexistent.jsonl"
+		err := cmd.Args(cmd, []string{validUUID})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "journal file to resume does not exist")
+	})
+
+	t.Run("negative concurrency", func(t *testing.T) {
+		resetFlags()
+		domains = []string{"example.com"}
+		ssoDomain = "sso.example.com"
+		defer func() { concurrency = 0 }()
+		concurrency = -1
+		err := cmd.Args(cmd, []string{validUUID})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "concurrency must not be negative")
+	})
+
+	t.Run("invalid report format", func(t *testing.T) {
+		resetFlags()
+		domains = []string{"example.com"}
+		ssoDomain = "sso.example.com"
+		defer func() { reportFormat = "" }()
+		reportFormat = "xml"
+		err := cmd.Args(cmd, []string{validUUID})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "reportFormat must be one of json or csv")
+	})
+
 	t.Run("missing csv file", func(t *testing.T) {
 		resetFlags()
-		domain = "example.com"
+		domains = []string{"example.com"}
 		ssoDomain = "sso.example.com"
 		csvFilePath = "/path/to/nonexistent.csv"
 		err := cmd.Args(cmd, []string{validUUID})
@@ -86,7 +133,7 @@ func TestSyncMemberships_Args(t *testing.T) {
 
 	t.Run("existing csv file", func(t *testing.T) {
 		resetFlags()
-		domain = "example.com"
+		domains = []string{"example.com"}
 		ssoDomain = "sso.example.com"
 
 		tmpFile, err := os.CreateTemp("", "test*.csv")
@@ -100,6 +147,176 @@ func TestSyncMemberships_Args(t *testing.T) {
 		err = cmd.Args(cmd, []string{validUUID})
 		assert.NoError(t, err)
 	})
+
+	t.Run("several domains and a domainMap", func(t *testing.T) {
+		resetFlags()
+		defer func() { domainMap = nil }()
+		domains = []string{"a.com", "b.com", "c.io"}
+		domainMap = map[string]string{"c.io": "new.io"}
+		ssoDomain = "new.com"
+		assert.NoError(t, cmd.Args(cmd, []string{validUUID}))
+
+		// the ssoDomain is not needed when every domain is mapped
+		domains, ssoDomain = []string{"c.io"}, ""
+		assert.NoError(t, cmd.Args(cmd, []string{validUUID}))
+	})
+
+	t.Run("invalid domains and domainMap", func(t *testing.T) {
+		resetFlags()
+		defer func() { domainMap = nil }()
+		domains, ssoDomain = nil, "new.com"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "domain is required")
+
+		domains = []string{"a.com", "invalid_domain_@@"}
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "domain must be a valid domain name: invalid_domain_@@")
+
+		domains, domainMap = []string{"a.com"}, map[string]string{"b.com": "new.io"}
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "domainMap source must be one of the domains: b.com")
+
+		domainMap = map[string]string{"a.com": "invalid_domain_@@"}
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "domainMap destination must be a valid domain name: invalid_domain_@@")
+
+		domainMap = map[string]string{"a.com": "a.com"}
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "domain and ssoDomain must be different")
+
+		domains, ssoDomain, domainMap = []string{"a.com", "b.com"}, "", map[string]string{"a.com": "new.com"}
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "ssoDomain or a domainMap entry is required for domain: b.com")
+	})
+
+	t.Run("destination group", func(t *testing.T) {
+		resetFlags()
+		defer func() { destinationGroupID, orgMappingFilePath = "", "" }()
+		orgMappingFilePath = writeTempPlanFile(t, "payments,payments-eu\n")
+		defer os.Remove(orgMappingFilePath)
+
+		// the users may keep their domain across groups
+		domains, ssoDomain = []string{"example.com"}, "example.com"
+		destinationGroupID = uuid.New().String()
+		assert.NoError(t, cmd.Args(cmd, []string{validUUID}))
+
+		destinationGroupID = "invalid-uuid"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "destinationGroup must be a valid UUID: invalid-uuid")
+
+		destinationGroupID = validUUID
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "destinationGroup and groupID must be different")
+
+		// the users may keep their domain across the SSO connections of the group
+		destinationGroupID, orgMappingFilePath = "", ""
+		connection, destinationConnection = "Okta", "Entra ID"
+		defer func() { connection, destinationConnection = "", "" }()
+		assert.NoError(t, cmd.Args(cmd, []string{validUUID}))
+
+		destinationConnection = "okta"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "destinationConnection and connection must be different")
+
+		destinationGroupID, orgMappingFilePath = uuid.New().String(), "/path/to/nonexistent.csv"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "orgMappingFile does not exist: /path/to/nonexistent.csv")
+	})
+
+	t.Run("role mapping file", func(t *testing.T) {
+		resetFlags()
+		defer func() { roleMappingFilePath, unmappedRoles = "", "" }()
+		domains, ssoDomain = []string{"example.com"}, "sso.example.com"
+		roleMappingFilePath = writeTempPlanFile(t, `{"rules": [{"role": "Org Admin", "roleId": "role-collaborator"}]}`)
+		defer os.Remove(roleMappingFilePath)
+		unmappedRoles = "reject"
+		assert.NoError(t, cmd.Args(cmd, []string{validUUID}))
+
+		unmappedRoles = "drop"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "unmappedRoles must be one of passthrough or reject: drop")
+
+		unmappedRoles, roleMappingFilePath = "reject", ""
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "unmappedRoles reject requires a roleMappingFile")
+
+		roleMappingFilePath = "/path/to/nonexistent.json"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "roleMappingFile does not exist: /path/to/nonexistent.json")
+	})
+
+	t.Run("missing org patterns files", func(t *testing.T) {
+		resetFlags()
+		defer func() { includeOrgsFilePath, excludeOrgsFilePath = "", "" }()
+		domains, ssoDomain = []string{"example.com"}, "sso.example.com"
+		includeOrgsFilePath = "/path/to/nonexistent.txt"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "includeOrgsFile does not exist: /path/to/nonexistent.txt")
+
+		includeOrgsFilePath, excludeOrgsFilePath = "", "/path/to/nonexistent.txt"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "excludeOrgsFile does not exist: /path/to/nonexistent.txt")
+	})
+
+	t.Run("invalid retireSource", func(t *testing.T) {
+		resetFlags()
+		defer func() { retireSource, snapshotFilePath = "", "" }()
+		domains, ssoDomain = []string{"example.com"}, "sso.example.com"
+		retireSource = "delete-user"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "retireSource requires a snapshotFile")
+
+		snapshotFilePath = "snapshot.json"
+		assert.NoError(t, cmd.Args(cmd, []string{validUUID}))
+
+		retireSource = "disable"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "retireSource must be one of remove-memberships or delete-user: disable")
+	})
+
+	t.Run("mapping file without domain", func(t *testing.T) {
+		resetFlags()
+		defer func() { mappingFilePath = "" }()
+		mappingFilePath = writeTempPlanFile(t, "jdoe@old.com,jane.smith@new.com\n")
+		defer os.Remove(mappingFilePath)
+		err := cmd.Args(cmd, []string{validUUID})
+		assert.NoError(t, err)
+	})
+
+	t.Run("missing overrides file", func(t *testing.T) {
+		resetFlags()
+		domains, ssoDomain = []string{"example.com"}, "sso.example.com"
+		defer func() { overridesFilePath = "" }()
+		overridesFilePath = "/path/to/nonexistent.csv"
+		err := cmd.Args(cmd, []string{validUUID})
+		assert.EqualError(t, err, "overridesFile does not exist: /path/to/nonexistent.csv")
+	})
+
+	t.Run("missing mapping file", func(t *testing.T) {
+		resetFlags()
+		defer func() { mappingFilePath = "" }()
+		mappingFilePath = "/path/to/nonexistent.csv"
+		err := cmd.Args(cmd, []string{validUUID})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "mappingFile does not exist")
+	})
+}
+
+func TestReadMappingFile(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("two columns with header", func(t *testing.T) {
+		filePath := writeTempPlanFile(t, "source,destination\njdoe@old.com, jane.smith@new.com\n\nbob@old.com,robert\n")
+		defer os.Remove(filePath)
+
+		mapping, err := readMappingFile(filePath, &logger)
+		assert.NoError(t, err)
+		assert.Equal(t, []membership.UserMapping{
+			{SourceIdentifier: "jdoe@old.com", DestinationIdentifier: "jane.smith@new.com"},
+			{SourceIdentifier: "bob@old.com", DestinationIdentifier: "robert"},
+		}, mapping)
+	})
+
+	t.Run("missing destination", func(t *testing.T) {
+		filePath := writeTempPlanFile(t, "jdoe@old.com,jane.smith@new.com\nbob@old.com\n")
+		defer os.Remove(filePath)
+
+		_, err := readMappingFile(filePath, &logger)
+		assert.EqualError(t, err, "mapping file line 2 must have a source and a destination identifier")
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		filePath := writeTempPlanFile(t, "source,destination\n")
+		defer os.Remove(filePath)
+
+		_, err := readMappingFile(filePath, &logger)
+		assert.EqualError(t, err, "mapping file is empty")
+		_, err = readOverridesFile(filePath, &logger)
+		assert.EqualError(t, err, "overrides file is empty")
+	})
 }
 
 func TestFilterUsers(t *testing.T) {
@@ -127,7 +344,7 @@ func TestFilterUsers(t *testing.T) {
 	t.Run("includeSSODomain false - exact match", func(t *testing.T) {
 		ssoDomain = "" // Should not be used
 		emailsToFilter := []string{"user1@example.com", "user4@another.com"}
-		filtered := filterUsers(emailsToFilter, ssoUsers, false, false, false, &logger)
+		filtered := filterUsers(emailsToFilter, ssoUsers, false, false, false, nil, &logger)
 		assert.Len(t, filtered, 2)
 		assert.Equal(t, "user1@example.com", *filtered[0].Attributes.Email)
 		assert.Equal(t, "user4@another.com", *filtered[1].Attributes.Email)
@@ -136,7 +353,7 @@ func TestFilterUsers(t *testing.T) {
 	t.Run("includeSSODomain true - match original and sso domain email", func(t *testing.T) {
 		ssoDomain = "sso.example.com"
 		emailsToFilter := []string{"user1@example.com"}
-		filtered := filterUsers(emailsToFilter, ssoUsers, true, false, false, &logger)
+		filtered := filterUsers(emailsToFilter, ssoUsers, true, false, false, nil, &logger)
 		assert.Len(t, filtered, 2)
 		// Order might vary, so check for presence
 		foundOriginal := false
@@ -156,7 +373,7 @@ func TestFilterUsers(t *testing.T) {
 	t.Run("includeSSODomain true - only original email found", func(t *testing.T) {
 		ssoDomain = "sso.example.com"
 		emailsToFilter := []string{"user2@example.com"} // No user2@sso.example.com in ssoUsers
-		filtered := filterUsers(emailsToFilter, ssoUsers, true, false, false, &logger)
+		filtered := filterUsers(emailsToFilter, ssoUsers, true, false, false, nil, &logger)
 		assert.Len(t, filtered, 1)
 		assert.Equal(t, "user2@example.com", *filtered[0].Attributes.Email)
 	})
@@ -165,15 +382,27 @@ func TestFilterUsers(t *testing.T) {
 		ssoDomain = "sso.example.com"
 		// We filter by "user5@example.com", expecting to find "user5@sso.example.com"
 		emailsToFilter := []string{"user5@example.com"}
-		filtered := filterUsers(emailsToFilter, ssoUsers, true, false, false, &logger)
+		filtered := filterUsers(emailsToFilter, ssoUsers, true, false, false, nil, &logger)
 		assert.Len(t, filtered, 1)
 		assert.Equal(t, "user5@sso.example.com", *filtered[0].Attributes.Email)
 	})
 
+	t.Run("includeSSODomain true - domainMap entry of the email domain", func(t *testing.T) {
+		ssoDomain = ""
+		defer func() { domainMap = nil }()
+		domainMap = map[string]string{"another.com": "sso.example.com"}
+		// user5@another.com is provisioned on the mapped domain, user1@example.com has no domainMap entry nor ssoDomain
+		emailsToFilter := []string{"user5@another.com", "user1@example.com"}
+		filtered := filterUsers(emailsToFilter, ssoUsers, true, false, false, nil, &logger)
+		assert.Len(t, filtered, 2)
+		assert.Equal(t, "user5@sso.example.com", *filtered[0].Attributes.Email)
+		assert.Equal(t, "user1@example.com", *filtered[1].Attributes.Email)
+	})
+
 	t.Run("includeSSODomain true - ssoDomain not set", func(t *testing.T) {
 		ssoDomain = ""
 		emailsToFilter := []string{"user1@example.com"}
-		filtered := filterUsers(emailsToFilter, ssoUsers, true, false, false, &logger)
+		filtered := filterUsers(emailsToFilter, ssoUsers, true, false, false, nil, &logger)
 		assert.Len(t, filtered, 1)
 		assert.Equal(t, "user1@example.com", *filtered[0].Attributes.Email)
 	})
@@ -181,14 +410,14 @@ func TestFilterUsers(t *testing.T) {
 	t.Run("no matching users", func(t *testing.T) {
 		ssoDomain = "sso.example.com"
 		emailsToFilter := []string{"nonexistent@example.com"}
-		filtered := filterUsers(emailsToFilter, ssoUsers, false, false, false, &logger)
+		filtered := filterUsers(emailsToFilter, ssoUsers, false, false, false, nil, &logger)
 		assert.Len(t, filtered, 0)
 	})
 
 	t.Run("empty email list", func(t *testing.T) {
 		ssoDomain = "sso.example.com"
 		var emailsToFilter []string
-		filtered := filterUsers(emailsToFilter, ssoUsers, false, false, false, &logger)
+		filtered := filterUsers(emailsToFilter, ssoUsers, false, false, false, nil, &logger)
 		assert.Len(t, filtered, 0)
 	})
 
@@ -196,7 +425,7 @@ func TestFilterUsers(t *testing.T) {
 		ssoDomain = "sso.example.com"
 		emptySsoUsers := sso.Users{Data: []sso.User{}}
 		emailsToFilter := []string{"user1@example.com"}
-		filtered := filterUsers(emailsToFilter, emptySsoUsers, false, false, false, &logger)
+		filtered := filterUsers(emailsToFilter, emptySsoUsers, false, false, false, nil, &logger)
 		assert.Len(t, filtered, 0)
 	})
 
@@ -204,7 +433,7 @@ func TestFilterUsers(t *testing.T) {
 		ssoDomain = "sso.example.com"
 		usersWithNil := sso.Users{Data: []sso.User{{ID: stringPtr("nil-attr")}, makeUser("id1", "user1@example.com", "user1@example2.com")}}
 		emailsToFilter := []string{"user1@example.com"}
-		filtered := filterUsers(emailsToFilter, usersWithNil, false, false, false, &logger)
+		filtered := filterUsers(emailsToFilter, usersWithNil, false, false, false, nil, &logger)
 		assert.Len(t, filtered, 1)
 		assert.Equal(t, "user1@example.com", *filtered[0].Attributes.Email)
 	})
@@ -213,7 +442,7 @@ func TestFilterUsers(t *testing.T) {
 		ssoDomain = "sso.example.com"
 		// The full string from the CSV is used for matching against the UserName attribute.
 		emailsToFilter := []string{"user-to-match@by.username"}
-		filtered := filterUsers(emailsToFilter, ssoUsers, true, true, false, &logger)
+		filtered := filterUsers(emailsToFilter, ssoUsers, true, true, false, nil, &logger)
 
 		// It should find user id9, which has a UserName of "user-to-match@by.username"
 		assert.Len(t, filtered, 1)
@@ -227,7 +456,7 @@ func TestFilterUsers(t *testing.T) {
 		// With matchToLocalPart=true, the logic also checks for username matching the local part of the email.
 		// The logic is: `matchUserByUserName(user, email) || (includeSSODomain && matchToLocalPart && matchUserByUserName(user, localPart))`
 		// This will match users where UserName is "csv.user".
-		filtered := filterUsers(emailsToFilter, ssoUsers, true, true, true, &logger)
+		filtered := filterUsers(emailsToFilter, ssoUsers, true, true, true, nil, &logger)
 
 		// It should find user id6 (by username) and id8 (by username)
 		// The inner loop of filterUsers breaks after finding 2 users for a given email.
@@ -246,6 +475,16 @@ func TestFilterUsers(t *testing.T) {
 		assert.True(t, foundId6, "User with id6 (username: csv.user) not found")
 		assert.True(t, foundId8, "User with id8 (username: csv.user) not found")
 	})
+
+	t.Run("includeSSODomain true - transformed local part", func(t *testing.T) {
+		ssoDomain = "sso.example.com"
+		transforms, err := membership.NewTransforms([]membership.TransformRule{{Type: membership.TransformRegex, Pattern: `_ext$`}})
+		assert.NoError(t, err)
+		emailsToFilter := []string{"user5_ext@example.com"}
+		filtered := filterUsers(emailsToFilter, ssoUsers, true, false, false, transforms, &logger)
+		assert.Len(t, filtered, 1)
+		assert.Equal(t, "id5", *filtered[0].ID)
+	})
 }
 
 // Helper to create sso.User
@@ -378,3 +617,69 @@ func TestMatchDomainUser(t *testing.T) {
 		})
 	}
 }
+
+// mockOrgLister is a mock for the orgLister interface
+type mockOrgLister struct {
+	mock.Mock
+}
+
+func (m *mockOrgLister) GetGroupOrgs(groupID string) ([]membership.Org, error) {
+	args := m.Called(groupID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]membership.Org), args.Error(1)
+}
+
+func TestReadGroupMigration(t *testing.T) {
+	logger := zerolog.Nop()
+	filePath := writeTempPlanFile(t, "source,destination\npayments,Payments EU\nsrc-org-2,dst-org-2\n")
+	defer os.Remove(filePath)
+
+	ol := new(mockOrgLister)
+	ol.On("GetGroupOrgs", "src-group").Return([]membership.Org{
+		{ID: "src-org-1", Name: "Payments", Slug: "payments"},
+		{ID: "src-org-2", Name: "Billing", Slug: "billing"},
+	}, nil)
+	ol.On("GetGroupOrgs", "dst-group").Return([]membership.Org{
+		{ID: "dst-org-1", Name: "Payments EU", Slug: "payments-eu"},
+		{ID: "dst-org-2", Name: "Billing EU", Slug: "billing-eu"},
+	}, nil)
+
+	migration, err := readGroupMigration(ol, "src-group", "dst-group", filePath, &logger)
+	assert.NoError(t, err)
+	assert.Equal(t, &membership.GroupMigration{
+		SourceGroupID:      "src-group",
+		DestinationGroupID: "dst-group",
+		Orgs: map[string]membership.Org{
+			"src-org-1": {ID: "dst-org-1", Name: "Payments EU", Slug: "payments-eu"},
+			"src-org-2": {ID: "dst-org-2", Name: "Billing EU", Slug: "billing-eu"},
+		},
+	}, migration)
+
+	invalidFilePath := writeTempPlanFile(t, "legacy,Payments EU\n")
+	defer os.Remove(invalidFilePath)
+	_, err = readGroupMigration(ol, "src-group", "dst-group", invalidFilePath, &logger)
+	assert.EqualError(t, err, "org mapping has 1 invalid Orgs: source legacy not found")
+}
+
+func TestReadOrgFilter(t *testing.T) {
+	logger := zerolog.Nop()
+	filePath := writeTempPlanFile(t, "# never touched\n*-sandbox\n")
+	defer os.Remove(filePath)
+
+	ol := new(mockOrgLister)
+	ol.On("GetGroupOrgs", "src-group").Return([]membership.Org{{ID: "src-org-1", Name: "Payments", Slug: "payments"}}, nil)
+	ol.On("GetGroupOrgs", "dst-group").Return([]membership.Org{{ID: "dst-org-1", Name: "Payments EU", Slug: "payments-eu"}}, nil)
+
+	orgFilter, err := readOrgFilter(ol, []string{"src-group", "dst-group"}, []string{"payments*"}, []string{"legacy"}, "", filePath, &logger)
+	assert.NoError(t, err)
+	assert.NotNil(t, orgFilter)
+	ol.AssertExpectations(t)
+
+	_, err = readOrgFilter(ol, []string{"src-group"}, []string{"[payments"}, nil, "", "", &logger)
+	assert.EqualError(t, err, "includeOrgs has an invalid pattern: [payments")
+
+	_, err = readOrgFilter(ol, []string{"src-group"}, nil, nil, "/path/to/nonexistent.txt", "", &logger)
+	assert.Error(t, err)
+}
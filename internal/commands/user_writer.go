@@ -0,0 +1,282 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputFormatNDJSON = "ndjson"
+	outputFormatYAML   = "yaml"
+)
+
+const (
+	userColumnID        = "id"
+	userColumnEmail     = "email"
+	userColumnUserName  = "username"
+	userColumnName      = "name"
+	userColumnActive    = "active"
+	userColumnDomain    = "domain"
+	userColumnLocalPart = "localPart"
+)
+
+// userColumnNames lists every column of the users written by get-users.
+var userColumnNames = []string{userColumnID, userColumnEmail, userColumnUserName, userColumnName, userColumnActive, userColumnDomain, userColumnLocalPart}
+
+// defaultUserColumns are the columns written without --columns, the identifier first so that the CSV output is a valid csvFilePath.
+var defaultUserColumns = []string{userColumnUserName, userColumnEmail, userColumnName, userColumnActive, userColumnID}
+
+// validateUserColumns checks every column is one of the userColumnNames.
+func validateUserColumns(columns []string) error {
+	for _, c := range columns {
+		if !slices.Contains(userColumnNames, c) {
+			return fmt.Errorf("columns must be among %s: %s", strings.Join(userColumnNames, ", "), c)
+		}
+	}
+	return nil
+}
+
+// userColumnValue returns the value of the column of a user: a string, or a *bool for the active column.
+// The domain and local part are the ones of the email of the user, as matched by the sync.
+func userColumnValue(user sso.User, column string) any {
+	if column == userColumnID {
+		if user.ID != nil {
+			return *user.ID
+		}
+		return ""
+	}
+	if column == userColumnActive {
+		if user.Attributes != nil {
+			return user.Attributes.Active
+		}
+		return (*bool)(nil)
+	}
+	var value *string
+	if user.Attributes != nil {
+		switch column {
+		case userColumnEmail, userColumnDomain, userColumnLocalPart:
+			value = user.Attributes.Email
+		case userColumnUserName:
+			value = user.Attributes.UserName
+		case userColumnName:
+			value = user.Attributes.Name
+		}
+	}
+	if value == nil {
+		return ""
+	}
+	switch column {
+	case userColumnDomain:
+		return membership.IdentifierDomain(*value)
+	case userColumnLocalPart:
+		if *value == "" {
+			return ""
+		}
+		return membership.IdentifierLocalPart(*value)
+	}
+	return *value
+}
+
+// userColumnString formats the value of the column of a user for CSV and table output, empty for an unknown active state.
+func userColumnString(user sso.User, column string) string {
+	switch v := userColumnValue(user, column).(type) {
+	case *bool:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatBool(*v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// sortUsers sorts the users by the case-insensitive value of the column, keeping the API order of equal values.
+func sortUsers(users []sso.User, column string) {
+	slices.SortStableFunc(users, func(a, b sso.User) int {
+		return strings.Compare(strings.ToLower(userColumnString(a, column)), strings.ToLower(userColumnString(b, column)))
+	})
+}
+
+// userRow is a user restricted to the columns, marshaled as an object keeping the order of the columns.
+type userRow struct {
+	user    sso.User
+	columns []string
+}
+
+func (r userRow) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, c := range r.columns {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		key, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(userColumnValue(r.user, c))
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteString(":")
+		b.Write(value)
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+func (r userRow) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, c := range r.columns {
+		var value yaml.Node
+		if err := value.Encode(userColumnValue(r.user, c)); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: c}, &value)
+	}
+	return node, nil
+}
+
+func userRows(users []sso.User, columns []string) []userRow {
+	rows := make([]userRow, 0, len(users))
+	for _, user := range users {
+		rows = append(rows, userRow{user: user, columns: columns})
+	}
+	return rows
+}
+
+// userWriter writes SSO users in an output format.
+type userWriter interface {
+	Write(users []sso.User) error
+}
+
+// userWriters creates the userWriter of every output format of get-users, writing the columns of the users.
+var userWriters = map[string]func(w io.Writer, columns []string) userWriter{
+	outputFormatCSV:    func(w io.Writer, columns []string) userWriter { return &csvUserWriter{w: w, columns: columns} },
+	outputFormatJSON:   func(w io.Writer, columns []string) userWriter { return &jsonUserWriter{w: w, columns: columns} },
+	outputFormatNDJSON: func(w io.Writer, columns []string) userWriter { return &ndjsonUserWriter{w: w, columns: columns} },
+	outputFormatYAML:   func(w io.Writer, columns []string) userWriter { return &yamlUserWriter{w: w, columns: columns} },
+	outputFormatTable:  func(w io.Writer, columns []string) userWriter { return &tableUserWriter{w: w, columns: columns} },
+}
+
+// userOutputFormats lists the output formats of get-users, CSV first as the default.
+var userOutputFormats = []string{outputFormatCSV, outputFormatJSON, outputFormatNDJSON, outputFormatYAML, outputFormatTable}
+
+// newUserWriter returns the userWriter of the output format, CSV if the format is empty,
+// writing the columns of the users or the defaultUserColumns if there are none.
+func newUserWriter(format string, w io.Writer, columns []string) (userWriter, error) {
+	if format == "" {
+		format = outputFormatCSV
+	}
+	newWriter, ok := userWriters[format]
+	if !ok {
+		return nil, fmt.Errorf("output must be one of %s: %s", strings.Join(userOutputFormats, ", "), format)
+	}
+	if len(columns) == 0 {
+		columns = defaultUserColumns
+	}
+	if err := validateUserColumns(columns); err != nil {
+		return nil, err
+	}
+	return newWriter(w, columns), nil
+}
+
+// csvUserWriter writes a quoted CSV header and a record per user.
+type csvUserWriter struct {
+	w       io.Writer
+	columns []string
+}
+
+func (cw *csvUserWriter) Write(users []sso.User) error {
+	if err := writeQuotedRecord(cw.w, cw.columns); err != nil {
+		return err
+	}
+	for _, user := range users {
+		record := make([]string, 0, len(cw.columns))
+		for _, c := range cw.columns {
+			record = append(record, userColumnString(user, c))
+		}
+		if err := writeQuotedRecord(cw.w, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonUserWriter writes the users as an indented JSON array.
+type jsonUserWriter struct {
+	w       io.Writer
+	columns []string
+}
+
+func (jw *jsonUserWriter) Write(users []sso.User) error {
+	encoder := json.NewEncoder(jw.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(userRows(users, jw.columns))
+}
+
+// ndjsonUserWriter writes a JSON object per line and user.
+type ndjsonUserWriter struct {
+	w       io.Writer
+	columns []string
+}
+
+func (nw *ndjsonUserWriter) Write(users []sso.User) error {
+	encoder := json.NewEncoder(nw.w)
+	for _, r := range userRows(users, nw.columns) {
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlUserWriter writes the users as a YAML sequence.
+type yamlUserWriter struct {
+	w       io.Writer
+	columns []string
+}
+
+func (yw *yamlUserWriter) Write(users []sso.User) error {
+	encoder := yaml.NewEncoder(yw.w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(userRows(users, yw.columns)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// tableUserWriter writes the users as an aligned table.
+type tableUserWriter struct {
+	w       io.Writer
+	columns []string
+}
+
+func (tw *tableUserWriter) Write(users []sso.User) error {
+	table := tabwriter.NewWriter(tw.w, 0, 0, 2, ' ', 0)
+	header := make([]string, 0, len(tw.columns))
+	for _, c := range tw.columns {
+		header = append(header, strings.ToUpper(c))
+	}
+	fmt.Fprintln(table, strings.Join(header, "\t"))
+	for _, user := range users {
+		record := make([]string, 0, len(tw.columns))
+		for _, c := range tw.columns {
+			record = append(record, userColumnString(user, c))
+		}
+		fmt.Fprintln(table, strings.Join(record, "\t"))
+	}
+	return table.Flush()
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/stretchr/testify/assert"
+)
+
+func testWriterUsers() []sso.User {
+	stringPtr := func(s string) *string { return &s }
+	boolPtr := func(b bool) *bool { return &b }
+	newUser := func(id, email, username, name string, active *bool) sso.User {
+		return sso.User{
+			ID: stringPtr(id),
+			Attributes: &struct {
+				Name     *string `json:"name"`
+				Email    *string `json:"email"`
+				UserName *string `json:"username"`
+				Active   *bool   `json:"active"`
+			}{Name: stringPtr(name), Email: stringPtr(email), UserName: stringPtr(username), Active: active},
+		}
+	}
+	return []sso.User{
+		newUser("user-1", "jane.doe@example.com", "jane.doe", "Jane \"JD\" Doe", boolPtr(true)),
+		newUser("user-2", "john.doe@example.com", "john.doe@example.com", "John Doe", nil),
+	}
+}
+
+func TestUserWriters(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: "",
+			expected: `"username","email","name","active","id"` + "\n" +
+				`"jane.doe","jane.doe@example.com","Jane ""JD"" Doe","true","user-1"` + "\n" +
+				`"john.doe@example.com","john.doe@example.com","John Doe","","user-2"` + "\n",
+		},
+		{
+			format: outputFormatJSON,
+			expected: `[
+  {
+    "username": "jane.doe",
+    "email": "jane.doe@example.com",
+    "name": "Jane \"JD\" Doe",
+    "active": true,
+    "id": "user-1"
+  },
+  {
+    "username": "john.doe@example.com",
+    "email": "john.doe@example.com",
+    "name": "John Doe",
+    "active": null,
+    "id": "user-2"
+  }
+]
+`,
+		},
+		{
+			format: outputFormatNDJSON,
+			expected: `{"username":"jane.doe","email":"jane.doe@example.com","name":"Jane \"JD\" Doe","active":true,"id":"user-1"}` + "\n" +
+				`{"username":"john.doe@example.com","email":"john.doe@example.com","name":"John Doe","active":null,"id":"user-2"}` + "\n",
+		},
+		{
+			format: outputFormatYAML,
+			expected: `- username: jane.doe
+  email: jane.doe@example.com
+  name: Jane "JD" Doe
+  active: true
+  id: user-1
+- username: john.doe@example.com
+  email: john.doe@example.com
+  name: John Doe
+  active: null
+  id: user-2
+`,
+		},
+		{
+			format: outputFormatTable,
+			expected: "USERNAME              EMAIL                 NAME           ACTIVE  ID\n" +
+				"jane.doe              jane.doe@example.com  Jane \"JD\" Doe  true    user-1\n" +
+				"john.doe@example.com  john.doe@example.com  John Doe               user-2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("format "+tt.format, func(t *testing.T) {
+			var b bytes.Buffer
+			uw, err := newUserWriter(tt.format, &b, nil)
+			assert.NoError(t, err)
+			assert.NoError(t, uw.Write(testWriterUsers()))
+			assert.Equal(t, tt.expected, b.String())
+		})
+	}
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := newUserWriter("xml", &bytes.Buffer{}, nil)
+		assert.EqualError(t, err, "output must be one of csv, json, ndjson, yaml, table: xml")
+	})
+}
+
+func TestUserWriters_Columns(t *testing.T) {
+	columns := []string{userColumnID, userColumnLocalPart, userColumnDomain, userColumnActive}
+
+	t.Run("csv", func(t *testing.T) {
+		var b bytes.Buffer
+		uw, err := newUserWriter(outputFormatCSV, &b, columns)
+		assert.NoError(t, err)
+		assert.NoError(t, uw.Write(testWriterUsers()))
+		assert.Equal(t, `"id","localPart","domain","active"`+"\n"+
+			`"user-1","jane.doe","example.com","true"`+"\n"+
+			`"user-2","john.doe","example.com",""`+"\n", b.String())
+	})
+
+	t.Run("ndjson keeps the column order", func(t *testing.T) {
+		var b bytes.Buffer
+		uw, err := newUserWriter(outputFormatNDJSON, &b, columns)
+		assert.NoError(t, err)
+		assert.NoError(t, uw.Write(testWriterUsers()[:1]))
+		assert.Equal(t, `{"id":"user-1","localPart":"jane.doe","domain":"example.com","active":true}`+"\n", b.String())
+	})
+
+	t.Run("yaml keeps the column order", func(t *testing.T) {
+		var b bytes.Buffer
+		uw, err := newUserWriter(outputFormatYAML, &b, columns)
+		assert.NoError(t, err)
+		assert.NoError(t, uw.Write(testWriterUsers()[:1]))
+		assert.Equal(t, "- id: user-1\n  localPart: jane.doe\n  domain: example.com\n  active: true\n", b.String())
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		_, err := newUserWriter(outputFormatCSV, &bytes.Buffer{}, []string{"id", "phone"})
+		assert.EqualError(t, err, "columns must be among id, email, username, name, active, domain, localPart: phone")
+	})
+}
+
+func TestSortUsers(t *testing.T) {
+	users := testWriterUsers()
+	users[0].Attributes.Email = func(s string) *string { return &s }("Zed@example.com")
+
+	sortUsers(users, userColumnEmail)
+	assert.Equal(t, "user-2", *users[0].ID)
+	assert.Equal(t, "user-1", *users[1].ID)
+
+	// users with equal values keep their order
+	sortUsers(users, userColumnDomain)
+	assert.Equal(t, "user-2", *users[0].ID)
+
+	sortUsers(users, userColumnActive)
+	assert.Equal(t, "user-2", *users[0].ID, "unknown active state sorts first")
+	assert.Equal(t, "user-1", *users[1].ID)
+}
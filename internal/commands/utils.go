@@ -4,39 +4,48 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
+	"maps"
 	"net/mail"
 	"os"
 	"regexp"
+	"slices"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
 	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
 )
 
 // userFetcher defines a common interface for getting and filtering SSO users.
 type userFetcher interface {
 	GetUsers(groupID string, logger *zerolog.Logger) (*sso.Users, error)
-	FilterUsersByDomain(domain string, users sso.Users, matchByUserName bool, logger *zerolog.Logger) ([]sso.User, error)
 	FilterUsersByProfileIDs(identifiers []string, users sso.Users, matchByUserName bool, logger *zerolog.Logger) ([]sso.User, error)
 }
 
-// getAndFilterUsers fetches all users and then filters them based on the command-line flags.
+// getAndFilterUsers fetches all users, selects the users of the email or CSV file flags,
+// and then keeps the selected users matching every filter flag.
 func getAndFilterUsers(groupID string, logger *zerolog.Logger, sc userFetcher) (*sso.Users, error) {
+	filter, err := userFilter()
+	if err != nil {
+		logger.Error().Err(err).Msg("Invalid user filters")
+		return nil, err
+	}
+
 	// get all sso users
-	ssoUsers, err := sc.GetUsers(groupID, logger)
+	allUsers, err := sc.GetUsers(groupID, logger)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to get SSO users")
 		return nil, err
 	}
+	// narrow down a copy, leaving the fetched users untouched
+	ssoUsers := &sso.Users{Data: allUsers.Data}
 
-	if domain != "" {
+	if email != "" {
 		// Errors from filter functions are intentionally ignored to allow processing to continue.
 		// If no users are found, an empty list is returned, which is handled by the calling function.
-		filteredUserData, _ := sc.FilterUsersByDomain(domain, *ssoUsers, matchByUserName, logger)
-		ssoUsers.Data = filteredUserData
-	} else if email != "" {
 		userEmails := []string{email}
-		filteredUserData, _ := sc.FilterUsersByProfileIDs(userEmails, *ssoUsers, matchByUserName, logger)
+		filteredUserData, _ := sc.FilterUsersByProfileIDs(userEmails, *allUsers, matchByUserName, logger)
 		ssoUsers.Data = filteredUserData
 	} else if csvFilePath != "" {
 		csvEmails, err := readCsvFile(csvFilePath, logger)
@@ -50,13 +59,63 @@ func getAndFilterUsers(groupID string, logger *zerolog.Logger, sc userFetcher) (
 			return nil, err
 		}
 		// filter for a specific SSO User from the provided email in CSV line
-		filteredUserData, _ := sc.FilterUsersByProfileIDs(csvEmails, *ssoUsers, matchByUserName, logger)
+		filteredUserData, _ := sc.FilterUsersByProfileIDs(csvEmails, *allUsers, matchByUserName, logger)
 		ssoUsers.Data = filteredUserData
 	}
 
+	if filter != nil {
+		ssoUsers.Data = sso.FilterUsers(*ssoUsers, filter)
+		logger.Info().Msgf("Filtered %d users matching the filters", len(ssoUsers.Data))
+	}
 	return ssoUsers, nil
 }
 
+// userFilter composes the filter flags of get-users and delete-users into a filter expression, every flag
+// narrowing the users down: the users must be on one of the domains, on none of the notDomains, have the active state
+// and match every regular expression. It returns nil without any filter flag.
+func userFilter() (sso.UserFilter, error) {
+	var filters []sso.UserFilter
+	if len(domains) > 0 {
+		var onDomains []sso.UserFilter
+		for _, d := range domains {
+			onDomains = append(onDomains, sso.OfDomain(d, matchByUserName))
+		}
+		filters = append(filters, sso.AnyOf(onDomains...))
+	}
+	for _, d := range notDomains {
+		filters = append(filters, sso.Not(sso.OfDomain(d, matchByUserName)))
+	}
+	if activeUsers != "" {
+		active, err := strconv.ParseBool(activeUsers)
+		if err != nil {
+			return nil, fmt.Errorf("active must be true or false: %s", activeUsers)
+		}
+		filters = append(filters, sso.IsActive(active))
+	}
+	for _, rf := range []struct {
+		flag, expr string
+		filter     func(*regexp.Regexp) sso.UserFilter
+	}{
+		{"nameRegex", nameRegex, sso.NameMatches},
+		{"emailRegex", emailRegex, sso.EmailMatches},
+		{"userNameRegex", userNameRegex, sso.UserNameMatches},
+	} {
+		if rf.expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(rf.expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a valid regular expression: %s", rf.flag, rf.expr)
+		}
+		filters = append(filters, rf.filter(re))
+	}
+
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	return sso.AllOf(filters...), nil
+}
+
 // readCsvFile reads a CSV file and returns a slice of strings from the first column.
 func readCsvFile(filePath string, logger *zerolog.Logger) ([]string, error) {
 	file, err := os.Open(filePath)
@@ -85,6 +144,40 @@ func readCsvFile(filePath string, logger *zerolog.Logger) ([]string, error) {
 	return records, nil
 }
 
+// readTransformFile reads the JSON rules rewriting the local part of the source users before looking up their destination user.
+func readTransformFile(filePath string, logger *zerolog.Logger) (*membership.Transforms, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to open transform file: %s", filePath)
+		return nil, err
+	}
+	defer file.Close()
+
+	transforms, err := membership.ReadTransforms(file)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to read transform file: %s", filePath)
+		return nil, err
+	}
+	return transforms, nil
+}
+
+// readRoleMappingFile reads the JSON rules translating the roles of the source users into the roles of their destination user.
+func readRoleMappingFile(filePath, unmapped string, logger *zerolog.Logger) (*membership.RoleMapping, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to open role mapping file: %s", filePath)
+		return nil, err
+	}
+	defer file.Close()
+
+	roles, err := membership.ReadRoleMapping(file, unmapped)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to read role mapping file: %s", filePath)
+		return nil, err
+	}
+	return roles, nil
+}
+
 // isValidEmailRFC5322 checks an email is a valid address based on RFC5322 standards.
 func isValidEmailRFC5322(email string) bool {
 	_, err := mail.ParseAddress(email)
@@ -107,10 +200,19 @@ func validateGetDeleteArgs(logger *zerolog.Logger, args []string) error {
 	}
 
 	var domainRegexp = regexp.MustCompile(`^(?:[a-zA-Z0-9-]+\.)+[a-zA-Z]{2,}$`)
-	if domain != "" && !domainRegexp.MatchString(domain) {
-		msg := fmt.Sprintf("domain must be a valid domain name: %s", domain)
-		logger.Error().Msg(msg)
-		return fmt.Errorf("%s", msg)
+	for _, d := range domains {
+		if !domainRegexp.MatchString(d) {
+			msg := fmt.Sprintf("domain must be a valid domain name: %s", d)
+			logger.Error().Msg(msg)
+			return fmt.Errorf("%s", msg)
+		}
+	}
+	for _, d := range notDomains {
+		if !domainRegexp.MatchString(d) {
+			msg := fmt.Sprintf("notDomain must be a valid domain name: %s", d)
+			logger.Error().Msg(msg)
+			return fmt.Errorf("%s", msg)
+		}
 	}
 
 	if email != "" {
@@ -129,5 +231,52 @@ func validateGetDeleteArgs(logger *zerolog.Logger, args []string) error {
 		}
 	}
 
+	if _, err := userFilter(); err != nil {
+		logger.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// validateDomains checks every source domain and every domain of the domainMap is a valid domain name,
+// and that the domainMap only maps source domains.
+func validateDomains(domains []string, domainMap map[string]string) error {
+	var domainRegexp = regexp.MustCompile(`^(?:[a-zA-Z0-9-]+\.)+[a-zA-Z]{2,}$`)
+	if len(domains) == 0 {
+		return fmt.Errorf("domain is required")
+	}
+	for _, d := range domains {
+		if !domainRegexp.MatchString(d) {
+			return fmt.Errorf("domain must be a valid domain name: %s", d)
+		}
+	}
+	for _, source := range slices.Sorted(maps.Keys(domainMap)) {
+		if !slices.Contains(domains, source) {
+			return fmt.Errorf("domainMap source must be one of the domains: %s", source)
+		}
+		if destination := domainMap[source]; !domainRegexp.MatchString(destination) {
+			return fmt.Errorf("domainMap destination must be a valid domain name: %s", destination)
+		}
+	}
+	return nil
+}
+
+// validateDomainPairs checks every source domain is paired with a destination domain, its domainMap entry if any, otherwise the ssoDomain.
+// A destination domain is not needed to match to the local part, and may only be the source domain when migrating across Groups
+// or SSO connections.
+func validateDomainPairs(domains []string, ssoDomain string, domainMap map[string]string, matchToLocalPart, separateDestination bool) error {
+	for _, d := range domains {
+		destination, ok := domainMap[d]
+		if !ok {
+			destination = ssoDomain
+		}
+		if destination == "" && !matchToLocalPart {
+			return fmt.Errorf("ssoDomain or a domainMap entry is required for domain: %s", d)
+		}
+		if destination == d && !separateDestination {
+			return fmt.Errorf("domain and ssoDomain must be different")
+		}
+	}
 	return nil
 }
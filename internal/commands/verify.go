@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
+	"github.com/spf13/cobra"
+)
+
+func VerifyMemberships(logger *zerolog.Logger) *cobra.Command {
+	verifyCmd := cobra.Command{
+		Use:   "verify [groupID]",
+		Short: "Audits the memberships of the destination SSO users against their source users, without modifying any membership",
+		Args: func(_ *cobra.Command, args []string) error {
+			if err := validateSyncArgs(args, logger); err != nil {
+				return err
+			}
+			if outputFormat != "" && outputFormat != outputFormatTable && outputFormat != outputFormatJSON {
+				msg := fmt.Sprintf("output must be one of %s or %s: %s", outputFormatTable, outputFormatJSON, outputFormat)
+				logger.Error().Msg(msg)
+				return fmt.Errorf("%s", msg)
+			}
+			return nil
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			groupID := args[0]
+			run, err := newSyncRun(groupID, logger)
+			if err != nil {
+				return err
+			}
+			if len(run.sourceUsers.Data) == 0 {
+				logger.Info().Msgf("No corresponding SSO users found on groupID: %s, no Users to verify", groupID)
+				return nil
+			}
+			// the memberships are verified once the sync would not plan any further change
+			return runVerify(run.plan(logger), os.Stdout, logger)
+		},
+	}
+	return &verifyCmd
+}
+
+// runVerify writes the verification of the plan, returning an error if any user pair failed the verification.
+func runVerify(plan *membership.Plan, w io.Writer, logger *zerolog.Logger) error {
+	verification := plan.Verify()
+	var err error
+	if outputFormat == outputFormatJSON {
+		err = verification.WriteJSON(w)
+	} else {
+		err = verification.Write(w)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to write verification")
+		return err
+	}
+	if err := verification.Err(); err != nil {
+		logger.Error().Err(err).Msg("Failed to verify memberships")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/membership"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyMemberships_Args(t *testing.T) {
+	logger := zerolog.Nop()
+	cmd := VerifyMemberships(&logger)
+	validUUID := "123e4567-e89b-12d3-a456-426614174000"
+	defer func() { domains, ssoDomain, syncMode, outputFormat = nil, "", "", "" }()
+
+	t.Run("valid arguments", func(t *testing.T) {
+		domains, ssoDomain, syncMode, outputFormat = []string{"example.com"}, "sso.example.com", membership.ModeMerge, outputFormatJSON
+		assert.NoError(t, cmd.Args(cmd, []string{validUUID}))
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		domains, ssoDomain, syncMode, outputFormat = []string{"example.com"}, "sso.example.com", "replace", ""
+		assert.Error(t, cmd.Args(cmd, []string{validUUID}))
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		domains, ssoDomain, syncMode, outputFormat = []string{"example.com"}, "sso.example.com", "", "xml"
+		assert.EqualError(t, cmd.Args(cmd, []string{validUUID}), "output must be one of table or json: xml")
+	})
+}
+
+func TestRunVerify(t *testing.T) {
+	logger := zerolog.Nop()
+	defer func() { outputFormat = "" }()
+
+	plan := &membership.Plan{GroupID: "group-id", Users: []membership.UserPlan{
+		{SourceIdentifier: "jane.doe@example.com", DestinationIdentifier: "jane.doe@sso.example.com"},
+		{SourceIdentifier: "john.doe@example.com", DestinationIdentifier: "john.doe@sso.example.com", Operations: []membership.Operation{
+			{Action: membership.ActionCreateOrgMembership, OrgID: "org-1", OrgName: "Org One", RoleID: "role-admin", RoleName: "Org Admin"},
+		}},
+	}}
+
+	t.Run("table output", func(t *testing.T) {
+		outputFormat = outputFormatTable
+		var b bytes.Buffer
+		err := runVerify(plan, &b, &logger)
+		assert.EqualError(t, err, "memberships of 1 Users failed verification: john.doe@example.com")
+		assert.Contains(t, b.String(), "PASS jane.doe@example.com -> jane.doe@sso.example.com\n")
+		assert.Contains(t, b.String(), "FAIL john.doe@example.com -> john.doe@sso.example.com\n"+
+			"  missing OrgMembership, Org: Org One, expected Role: Org Admin\n")
+	})
+
+	t.Run("json output", func(t *testing.T) {
+		outputFormat = outputFormatJSON
+		var b bytes.Buffer
+		assert.Error(t, runVerify(plan, &b, &logger))
+		var verification membership.Verification
+		assert.NoError(t, json.Unmarshal(b.Bytes(), &verification))
+		assert.False(t, verification.Passed)
+		assert.Len(t, verification.Users, 2)
+		assert.Equal(t, membership.DiscrepancyMissing, verification.Users[1].Discrepancies[0].Kind)
+	})
+
+	t.Run("passed", func(t *testing.T) {
+		outputFormat = outputFormatTable
+		var b bytes.Buffer
+		assert.NoError(t, runVerify(&membership.Plan{GroupID: "group-id", Users: plan.Users[:1]}, &b, &logger))
+		assert.Contains(t, b.String(), "Verification: PASS, 1 Users, 1 passed, 0 failed, 0 unmatched, 0 conflicts\n")
+	})
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -79,12 +80,14 @@ func (c *SnykClientImpl) Request(method, path string, body io.Reader) (*http.Res
 		return nil, err
 	}
 	if resp != nil && resp.StatusCode >= http.StatusBadRequest {
-		if resp.ContentLength > 0 {
-			if body, err := io.ReadAll(resp.Body); err == nil {
-				c.logger.Debug().Msg(fmt.Sprintf("%d response body: %s", resp.StatusCode, string(body)))
-			}
+		body, err := io.ReadAll(resp.Body)
+		if err == nil && len(body) > 0 {
+			c.logger.Debug().Msg(fmt.Sprintf("%d response body: %s", resp.StatusCode, string(body)))
 		}
-		return resp, fmt.Errorf("failed to %s %s: %d", method, urlValue, resp.StatusCode)
+		// keep the body readable by the caller
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, newAPIError(method, urlValue, resp, body)
 	}
 	c.logger.Debug().Msg(fmt.Sprintf("%d response: %s: %s", resp.StatusCode, method, urlValue))
 	return resp, err
@@ -108,6 +111,9 @@ func (c *SnykClientImpl) Get(path string) ([]byte, error) {
 func (c *SnykClientImpl) Post(path string, body io.Reader) ([]byte, error) {
 	resp, err := c.Request("POST", path, body)
 	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
 		return nil, err
 	}
 	defer resp.Body.Close()
@@ -122,6 +128,9 @@ func (c *SnykClientImpl) Post(path string, body io.Reader) ([]byte, error) {
 func (c *SnykClientImpl) Patch(path string, body io.Reader) ([]byte, error) {
 	resp, err := c.Request("PATCH", path, body)
 	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
 		return nil, err
 	}
 	if resp.StatusCode == 204 {
@@ -139,6 +148,9 @@ func (c *SnykClientImpl) Patch(path string, body io.Reader) ([]byte, error) {
 func (c *SnykClientImpl) Delete(path string) ([]byte, error) {
 	resp, err := c.Request("DELETE", path, nil)
 	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
 		return nil, err
 	}
 	if resp.StatusCode == 204 {
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RequestIDHeader is the response header identifying a request to the Snyk API, to be quoted to Snyk support.
+const RequestIDHeader = "snyk-request-id"
+
+// APIErrorSource points at the part of the request an APIErrorObject is about.
+type APIErrorSource struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+}
+
+// APIErrorObject is an error object of a JSON:API error response.
+type APIErrorObject struct {
+	ID     string          `json:"id,omitempty"`
+	Status string          `json:"status,omitempty"`
+	Code   string          `json:"code,omitempty"`
+	Title  string          `json:"title,omitempty"`
+	Detail string          `json:"detail,omitempty"`
+	Source *APIErrorSource `json:"source,omitempty"`
+}
+
+// APIError is the error of a request answered by the Snyk API with an error status code.
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	RequestID  string
+	// Errors are the JSON:API error objects of the response, empty if its body is not a JSON:API document
+	Errors []APIErrorObject
+}
+
+// newAPIError builds the APIError of an error response from its request ID header and JSON:API body.
+func newAPIError(method, url string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Method:     method,
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(RequestIDHeader),
+	}
+	var document struct {
+		Errors []APIErrorObject `json:"errors"`
+	}
+	if json.Unmarshal(body, &document) == nil {
+		apiErr.Errors = document.Errors
+	}
+	return apiErr
+}
+
+// Error describes the failed request, followed by the details of its JSON:API errors and its request ID.
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "failed to %s %s: %d", e.Method, e.URL, e.StatusCode)
+	for _, o := range e.Errors {
+		detail := o.Detail
+		if detail == "" {
+			detail = o.Title
+		}
+		if o.Code != "" {
+			detail = fmt.Sprintf("%s (%s)", detail, o.Code)
+		}
+		if o.Source != nil && o.Source.Pointer != "" {
+			detail = fmt.Sprintf("%s at %s", detail, o.Source.Pointer)
+		}
+		if detail != "" {
+			fmt.Fprintf(&b, ", %s", detail)
+		}
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, ", request ID: %s", e.RequestID)
+	}
+	return b.String()
+}
+
+// AsAPIError finds the APIError of an error chain.
+func AsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// IsConflict checks whether the error is a 409 Conflict answer of the Snyk API, such as an already existing membership.
+func IsConflict(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.StatusCode == http.StatusConflict
+}
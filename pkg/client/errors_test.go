@@ -0,0 +1,40 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIError(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusConflict, Header: http.Header{}}
+	resp.Header.Set(RequestIDHeader, "request-id")
+	body := []byte(`{"jsonapi": {"version": "1.0"}, "errors": [{"status": "409", "code": "SNYK-0003",
+		"detail": "Membership already exists for the specified user", "source": {"pointer": "/data/relationships/user"}}]}`)
+
+	apiErr := newAPIError(http.MethodPost, "https://api.snyk.io/rest/orgs/org-1/memberships", resp, body)
+
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	assert.Equal(t, "request-id", apiErr.RequestID)
+	assert.Len(t, apiErr.Errors, 1)
+	assert.Equal(t, "SNYK-0003", apiErr.Errors[0].Code)
+	assert.Equal(t, "failed to POST https://api.snyk.io/rest/orgs/org-1/memberships: 409, "+
+		"Membership already exists for the specified user (SNYK-0003) at /data/relationships/user, request ID: request-id", apiErr.Error())
+}
+
+func TestIsConflict(t *testing.T) {
+	conflict := &APIError{StatusCode: http.StatusConflict}
+	assert.True(t, IsConflict(conflict))
+	assert.True(t, IsConflict(fmt.Errorf("wrapped: %w", conflict)))
+	assert.False(t, IsConflict(&APIError{StatusCode: http.StatusInternalServerError}))
+	assert.False(t, IsConflict(errors.New("status code 409")))
+	assert.False(t, IsConflict(nil))
+
+	// a response body that is not a JSON:API document is not an error detail
+	apiErr := newAPIError(http.MethodGet, "/rest/groups", &http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{}}, []byte("<html>"))
+	assert.Empty(t, apiErr.Errors)
+	assert.Equal(t, "failed to GET /rest/groups: 502", apiErr.Error())
+}
@@ -175,19 +175,14 @@ func (m *Client) getUserOrgMembershipsOfGroup(groupID, userID string) (*UserOrgM
 	return &UserOrgMemberships{Data: allMemberships}, nil
 }
 
-// func (m *Client) getUserOrgMembershipsOfOrg(orgID, userID string) (*UserOrgMemberships, error) {
-// 	requestPath := fmt.Sprintf("/rest/orgs/%s/memberships?limit=100&user_id=%s", orgID, userID)
-// 	respBody, err := m.client.Get(requestPath)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	var orgMemberships UserOrgMemberships
-// 	encodingError := json.Unmarshal(respBody, &orgMemberships)
-// 	if encodingError != nil {
-// 		return nil, encodingError
-// 	}
-// 	return &orgMemberships, nil
-// }
+func (m *Client) getUserOrgMembershipsOfOrg(orgID, userID string) (*UserOrgMemberships, error) {
+	requestPath := fmt.Sprintf("/rest/orgs/%s/memberships?limit=100&user_id=%s", orgID, userID)
+	allMemberships, err := m.getPaginatedMemberships(requestPath)
+	if err != nil {
+		return nil, err
+	}
+	return &UserOrgMemberships{Data: allMemberships}, nil
+}
 
 func toTypeIdentifier(typeIDAttributes *TypeIdentifierAttributes) *TypeIdentifier {
 	return &TypeIdentifier{
@@ -264,11 +259,7 @@ func (m *Client) createMembershipRequestBody(mbrshipType string, mbrRelationship
 	return &reqBody
 }
 
-func (m *Client) updateRoleAtUserGroupMembership(groupID, membershipID string, mbr Membership) error {
-	roleRelationship := MemberRelationship{
-		Role: mbr.Relationship.Role,
-	}
-
+func (m *Client) createRoleRequestBody(mbrshipType, membershipID string, mbr Membership) *RoleRequestBody {
 	reqBody := RoleRequestBody{
 		Data: &struct {
 			ID            string `json:"id"`
@@ -285,16 +276,21 @@ func (m *Client) updateRoleAtUserGroupMembership(groupID, membershipID string, m
 					Data *TypeIdentifier `json:"data"`
 				} `json:"role"`
 			}{},
-			Type: GroupMembershipType,
+			Type: mbrshipType,
 		},
 	}
 
 	reqBody.Data.Relationships.Role = &struct {
 		Data *TypeIdentifier `json:"data"`
 	}{
-		Data: toTypeIdentifier(roleRelationship.Role.Data),
+		Data: toTypeIdentifier(mbr.Relationship.Role.Data),
 	}
 
+	return &reqBody
+}
+
+func (m *Client) updateRoleAtUserGroupMembership(groupID, membershipID string, mbr Membership) error {
+	reqBody := m.createRoleRequestBody(GroupMembershipType, membershipID, mbr)
 	encodedBody, err := json.Marshal(reqBody)
 	if err != nil {
 		return err
@@ -309,6 +305,22 @@ func (m *Client) updateRoleAtUserGroupMembership(groupID, membershipID string, m
 	return nil
 }
 
+func (m *Client) updateRoleAtUserOrgMembership(orgID, membershipID string, mbr Membership) error {
+	reqBody := m.createRoleRequestBody(OrgMembershipType, membershipID, mbr)
+	encodedBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	requestPath := fmt.Sprintf("/rest/orgs/%s/memberships/%s", orgID, membershipID)
+	_, err = m.client.Patch(requestPath, bytes.NewBuffer(encodedBody))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (m *Client) createUserOrgMembership(orgID string, mbrRelationship MemberRelationship) (*Response, error) {
 	reqBody := m.createMembershipRequestBody(OrgMembershipType, mbrRelationship)
 	encodedBody, err := json.Marshal(reqBody)
@@ -360,3 +372,13 @@ func (m *Client) deleteOrgMembership(orgID, membershipID string) error {
 
 	return nil
 }
+
+func (m *Client) deleteGroupMembership(groupID, membershipID string) error {
+	requestPath := fmt.Sprintf("/rest/groups/%s/memberships/%s", groupID, membershipID)
+	_, err := m.client.Delete(requestPath)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
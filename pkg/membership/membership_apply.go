@@ -0,0 +1,220 @@
+package membership
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// MembershipState is the identifying state of an existing Group or Org membership.
+type MembershipState struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	GroupID   string `json:"groupId,omitempty"`
+	GroupName string `json:"groupName,omitempty"`
+	OrgID     string `json:"orgId,omitempty"`
+	OrgName   string `json:"orgName,omitempty"`
+	RoleID    string `json:"roleId"`
+	RoleName  string `json:"roleName,omitempty"`
+}
+
+// toMembershipState extracts the identifying state of a Group or Org membership.
+func toMembershipState(mbr Membership) MembershipState {
+	state := MembershipState{}
+	if mbr.ID != nil {
+		state.ID = *mbr.ID
+	}
+	if mbr.Type != nil {
+		state.Type = *mbr.Type
+	}
+	if mbr.Relationship == nil {
+		return state
+	}
+	if mbr.Relationship.Group != nil && mbr.Relationship.Group.Data != nil && mbr.Relationship.Group.Data.ID != nil {
+		state.GroupID = *mbr.Relationship.Group.Data.ID
+		state.GroupName = relationshipName(mbr.Relationship.Group.Data)
+	}
+	if mbr.Relationship.Org != nil && mbr.Relationship.Org.Data != nil && mbr.Relationship.Org.Data.ID != nil {
+		state.OrgID = *mbr.Relationship.Org.Data.ID
+		state.OrgName = relationshipName(mbr.Relationship.Org.Data)
+	}
+	if mbr.Relationship.Role != nil && mbr.Relationship.Role.Data != nil && mbr.Relationship.Role.Data.ID != nil {
+		state.RoleID = *mbr.Relationship.Role.Data.ID
+		state.RoleName = relationshipName(mbr.Relationship.Role.Data)
+	}
+	return state
+}
+
+// toMembershipStates extracts the identifying state of the Group and Org memberships of a User.
+func toMembershipStates(groupMemberships *UserGroupMemberships, orgMemberships *UserOrgMemberships) []MembershipState {
+	states := []MembershipState{}
+	if groupMemberships != nil {
+		for _, gm := range groupMemberships.Data {
+			states = append(states, toMembershipState(gm))
+		}
+	}
+	if orgMemberships != nil {
+		for _, om := range orgMemberships.Data {
+			states = append(states, toMembershipState(om))
+		}
+	}
+	return states
+}
+
+// stateKeys returns the sorted keys of membership states, ignoring names which may be renamed.
+func stateKeys(states []MembershipState) []string {
+	keys := make([]string, 0, len(states))
+	for _, s := range states {
+		keys = append(keys, strings.Join([]string{s.ID, s.Type, s.GroupID, s.OrgID, s.RoleID}, "|"))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hasDrifted checks whether the live memberships differ from the memberships a plan was computed against.
+func hasDrifted(planned, live []MembershipState) bool {
+	plannedKeys := stateKeys(planned)
+	liveKeys := stateKeys(live)
+	if len(plannedKeys) != len(liveKeys) {
+		return true
+	}
+	for i := range plannedKeys {
+		if plannedKeys[i] != liveKeys[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// getUserMembershipStates fetches the live state of the Group and Org memberships of a User.
+func (m *Client) getUserMembershipStates(groupID, userID string) ([]MembershipState, error) {
+	groupMemberships, err := m.getUserGroupMemberships(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	orgMemberships, err := m.getUserOrgMembershipsOfGroup(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return toMembershipStates(groupMemberships, orgMemberships), nil
+}
+
+// checkPlanDrift verifies the live memberships of every provisioned User of the plan are unchanged since planning.
+// It returns an error listing every drifted User.
+func (m *Client) checkPlanDrift(plan *Plan, logger *zerolog.Logger) error {
+	var drifted []string
+	for _, up := range plan.Users {
+		live, err := m.getUserMembershipStates(plan.GroupID, up.DestinationUserID)
+		if err != nil {
+			logger.Error().Msg(fmt.Sprintf("Failed to get memberships of User: username: %s", up.DestinationIdentifier))
+			return err
+		}
+		if hasDrifted(up.DestinationMemberships, live) {
+			logger.Error().Msg(fmt.Sprintf("Memberships of User: username: %s have drifted from the plan", up.DestinationIdentifier))
+			drifted = append(drifted, up.DestinationIdentifier)
+		}
+	}
+
+	if len(drifted) > 0 {
+		return fmt.Errorf("plan is stale, memberships of %d Users have drifted: %s", len(drifted), strings.Join(drifted, ", "))
+	}
+	return nil
+}
+
+// applyPlan issues the Operations of every User pair of the plan, up to opts.Concurrency User pairs in parallel,
+// recording their progress in the journal if any. It returns the outcome of every User pair of the plan,
+// the error is only returned if the progress cannot be recorded, the remaining User pairs are then skipped.
+func (m *Client) applyPlan(plan *Plan, opts SyncOptions, journal *Journal, logger *zerolog.Logger) (*Report, error) {
+	userCount := len(plan.Users)
+	logger.Info().Msg(fmt.Sprintf("Found %d Users to synchronize", userCount))
+
+	var stopped atomic.Bool
+	userReports := make([]UserReport, userCount)
+	errs := make([]error, userCount)
+	forEachOrdered(userCount, opts.Concurrency, logger, func(i int, logger *zerolog.Logger) {
+		up := &plan.Users[i]
+		if stopped.Load() {
+			userReports[i] = newUserReport(up)
+			return
+		}
+		logger.Info().Msg(fmt.Sprintf("Start synchronization of memberships %d/%d User: username: %s", i+1, userCount, up.DestinationIdentifier))
+		userReports[i], errs[i] = m.applyUserPlan(up, opts, journal, logger)
+		if errs[i] != nil {
+			logger.Error().Err(errs[i]).Msg("Failed to record progress in journal, stopping synchronization")
+			stopped.Store(true)
+		}
+	})
+
+	report := &Report{GroupID: plan.GroupID, Users: userReports, Conflicts: plan.Conflicts}
+	for _, sourceIdentifier := range plan.Unmatched {
+		report.Users = append(report.Users, newUnmatchedUserReport(sourceIdentifier))
+	}
+	for _, c := range plan.Conflicts {
+		for _, sourceIdentifier := range c.SourceIdentifiers {
+			report.Users = append(report.Users, newConflictUserReport(sourceIdentifier, c))
+		}
+	}
+	sort.SliceStable(report.Users, func(i, j int) bool {
+		return report.Users[i].SourceIdentifier < report.Users[j].SourceIdentifier
+	})
+	report.Domains = domainStats(report.Users)
+	for _, err := range errs {
+		if err != nil {
+			return report, err
+		}
+	}
+
+	for _, ds := range report.Domains {
+		logger.Info().Msg(ds.String())
+	}
+	logger.Info().Msg(fmt.Sprintf("End synchronization of memberships, %d Users failed", len(report.FailedUsers())))
+	return report, nil
+}
+
+// executePlan persists a snapshot of the memberships about to be modified, if requested, then issues the plan Operations.
+func (m *Client) executePlan(plan *Plan, opts SyncOptions, journal *Journal, logger *zerolog.Logger) (*Report, error) {
+	if opts.SnapshotPath != "" {
+		if err := writeSnapshotFile(opts.SnapshotPath, plan, logger); err != nil {
+			return nil, err
+		}
+	}
+	return m.applyPlan(plan, opts, journal, logger)
+}
+
+// ApplyPlan executes exactly the Operations of a previously computed plan.
+// It refuses to modify any membership if the live memberships of a provisioned User have drifted from the plan,
+// and returns an error if any Operation failed.
+func (m *Client) ApplyPlan(plan *Plan, opts SyncOptions, logger *zerolog.Logger) error {
+	if err := m.checkPlanDrift(plan, logger); err != nil {
+		return err
+	}
+	report, err := m.executePlan(plan, opts, nil, logger)
+	if err != nil {
+		return err
+	}
+	return report.Err()
+}
+
+// WriteJSON writes the machine-readable Plan.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// ReadPlan reads a machine-readable Plan.
+func ReadPlan(r io.Reader) (*Plan, error) {
+	var plan Plan
+	if err := json.NewDecoder(r).Decode(&plan); err != nil {
+		return nil, err
+	}
+	if plan.GroupID == "" {
+		return nil, fmt.Errorf("plan has no groupId")
+	}
+	return &plan, nil
+}
@@ -0,0 +1,151 @@
+package membership
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockMembershipsResponse is a helper to mock a single page membership API response
+func mockMembershipsResponse(memberships ...Membership) []byte {
+	body, _ := json.Marshal(UserMembershipResponse{Data: memberships})
+	return body
+}
+
+func TestHasDrifted(t *testing.T) {
+	planned := []MembershipState{
+		{ID: "gm-1", Type: GroupMembershipType, GroupID: "group-id", RoleID: "role-member"},
+		{ID: "om-1", Type: OrgMembershipType, OrgID: "org-1", OrgName: "Org One", RoleID: "role-admin"},
+	}
+
+	t.Run("same memberships in a different order and renamed Org", func(t *testing.T) {
+		live := []MembershipState{
+			{ID: "om-1", Type: OrgMembershipType, OrgID: "org-1", OrgName: "Org 1", RoleID: "role-admin"},
+			{ID: "gm-1", Type: GroupMembershipType, GroupID: "group-id", RoleID: "role-member"},
+		}
+		assert.False(t, hasDrifted(planned, live))
+	})
+
+	t.Run("role changed", func(t *testing.T) {
+		live := []MembershipState{
+			{ID: "gm-1", Type: GroupMembershipType, GroupID: "group-id", RoleID: "role-member"},
+			{ID: "om-1", Type: OrgMembershipType, OrgID: "org-1", RoleID: "role-collaborator"},
+		}
+		assert.True(t, hasDrifted(planned, live))
+	})
+
+	t.Run("membership added", func(t *testing.T) {
+		live := append([]MembershipState{{ID: "om-2", Type: OrgMembershipType, OrgID: "org-2", RoleID: "role-admin"}}, planned...)
+		assert.True(t, hasDrifted(planned, live))
+	})
+}
+
+func TestApplyPlan(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "group-id"
+	userID := "dst-1"
+	groupMembershipsPath := fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=%s", groupID, userID)
+	orgMembershipsPath := fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=%s", groupID, userID)
+	dstOrgMembership := makeOrgMembership("om-dst-1", "org-2", "Org Two", "role-admin", "Org Admin")
+
+	newPlan := func() *Plan {
+		return &Plan{
+			GroupID: groupID,
+			Users: []UserPlan{
+				{
+					SourceIdentifier:      "user1@source.com",
+					SourceUserID:          "src-1",
+					DestinationIdentifier: "user1",
+					DestinationUserID:     userID,
+					Operations: []Operation{
+						{Action: ActionCreateOrgMembership, OrgID: "org-1", OrgName: "Org One", RoleID: "role-admin"},
+					},
+					DestinationMemberships: toMembershipStates(nil, &UserOrgMemberships{Data: []Membership{dstOrgMembership}}),
+				},
+			},
+		}
+	}
+
+	t.Run("applies the plan when memberships have not drifted", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+
+		mockClient.On("Get", groupMembershipsPath).Return(mockMembershipsResponse(), nil)
+		mockClient.On("Get", orgMembershipsPath).Return(mockMembershipsResponse(dstOrgMembership), nil)
+		mockClient.On("Post", "/rest/orgs/org-1/memberships", mock.Anything).Return([]byte(`{"data":{"id":"om-dst-2"}}`), nil)
+
+		err := m.ApplyPlan(newPlan(), SyncOptions{}, &logger)
+		assert.NoError(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("writes a snapshot before applying the plan", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+
+		mockClient.On("Get", groupMembershipsPath).Return(mockMembershipsResponse(), nil)
+		mockClient.On("Get", orgMembershipsPath).Return(mockMembershipsResponse(dstOrgMembership), nil)
+		mockClient.On("Post", "/rest/orgs/org-1/memberships", mock.Anything).Return([]byte(`{"data":{"id":"om-dst-2"}}`), nil)
+
+		err := m.ApplyPlan(newPlan(), SyncOptions{SnapshotPath: snapshotPath}, &logger)
+		assert.NoError(t, err)
+		assert.FileExists(t, snapshotPath)
+	})
+
+	t.Run("refuses to apply the plan when memberships have drifted", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+
+		changedOrgMembership := makeOrgMembership("om-dst-1", "org-2", "Org Two", "role-collaborator", "Org Collaborator")
+		mockClient.On("Get", groupMembershipsPath).Return(mockMembershipsResponse(), nil)
+		mockClient.On("Get", orgMembershipsPath).Return(mockMembershipsResponse(changedOrgMembership), nil)
+
+		err := m.ApplyPlan(newPlan(), SyncOptions{}, &logger)
+		assert.EqualError(t, err, "plan is stale, memberships of 1 Users have drifted: user1")
+		mockClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestPlanJSONRoundTrip(t *testing.T) {
+	plan := &Plan{
+		GroupID: "group-id",
+		Users: []UserPlan{
+			{
+				SourceIdentifier:      "user1@source.com",
+				SourceUserID:          "src-1",
+				DestinationIdentifier: "user1",
+				DestinationUserID:     "dst-1",
+				Operations: []Operation{
+					{Action: ActionDeleteOrgMembership, Method: "DELETE", Path: "/rest/orgs/org-1/memberships/om-1", OrgID: "org-1", MembershipID: "om-1", RoleID: "role-admin"},
+				},
+				DestinationMemberships: []MembershipState{
+					{ID: "om-1", Type: OrgMembershipType, OrgID: "org-1", RoleID: "role-admin"},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, plan.WriteJSON(&buf))
+
+	readPlan, err := ReadPlan(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, plan, readPlan)
+}
+
+func TestReadPlan_Invalid(t *testing.T) {
+	_, err := ReadPlan(bytes.NewBufferString("invalid json"))
+	assert.Error(t, err)
+
+	_, err = ReadPlan(bytes.NewBufferString(`{"users":[]}`))
+	assert.EqualError(t, err, "plan has no groupId")
+}
@@ -0,0 +1,81 @@
+package membership
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// forEachOrdered calls fn for every index from 0 to n-1 with up to concurrency calls in parallel.
+// In parallel, every call logs to its own buffer which is replayed to logger in the index order,
+// so the log of a User is never interleaved with the log of another User.
+func forEachOrdered(n, concurrency int, logger *zerolog.Logger, fn func(i int, logger *zerolog.Logger)) {
+	if concurrency < 2 || n < 2 {
+		for i := 0; i < n; i++ {
+			fn(i, logger)
+		}
+		return
+	}
+
+	buffers := make([]bytes.Buffer, n)
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				bufferedLogger := logger.Output(&buffers[i])
+				fn(i, &bufferedLogger)
+				close(done[i])
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < n; i++ {
+			indexes <- i
+		}
+		close(indexes)
+	}()
+
+	for i := 0; i < n; i++ {
+		<-done[i]
+		replayLog(&buffers[i], logger)
+	}
+	wg.Wait()
+}
+
+// replayLog writes the buffered JSON log events to logger, keeping their level, message and fields.
+func replayLog(buf *bytes.Buffer, logger *zerolog.Logger) {
+	scanner := bufio.NewScanner(buf)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil {
+			logger.Info().Msg(scanner.Text())
+			continue
+		}
+
+		level := zerolog.NoLevel
+		if l, ok := fields[zerolog.LevelFieldName].(string); ok {
+			if parsed, err := zerolog.ParseLevel(l); err == nil {
+				level = parsed
+			}
+		}
+		message, _ := fields[zerolog.MessageFieldName].(string)
+		delete(fields, zerolog.LevelFieldName)
+		delete(fields, zerolog.MessageFieldName)
+		// the replayed event is timestamped by logger
+		delete(fields, zerolog.TimestampFieldName)
+		logger.WithLevel(level).Fields(fields).Msg(message)
+	}
+	buf.Reset()
+}
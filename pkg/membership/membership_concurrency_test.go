@@ -0,0 +1,97 @@
+package membership
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestForEachOrdered(t *testing.T) {
+	for _, concurrency := range []int{0, 1, 4} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := zerolog.New(&buf)
+			var calls atomic.Int32
+
+			forEachOrdered(10, concurrency, &logger, func(i int, logger *zerolog.Logger) {
+				logger.Info().Msg(fmt.Sprintf("start %d", i))
+				// finish the first indexes last
+				time.Sleep(time.Duration(10-i) * time.Millisecond)
+				logger.Warn().Str("user", fmt.Sprint(i)).Msg(fmt.Sprintf("end %d", i))
+				calls.Add(1)
+			})
+
+			assert.Equal(t, int32(10), calls.Load())
+			var expected []string
+			for i := 0; i < 10; i++ {
+				expected = append(expected,
+					fmt.Sprintf(`{"level":"info","message":"start %d"}`, i),
+					fmt.Sprintf(`{"level":"warn","user":"%d","message":"end %d"}`, i, i))
+			}
+			assert.Equal(t, expected, strings.Split(strings.TrimSpace(buf.String()), "\n"))
+		})
+	}
+}
+
+func TestApplyPlan_Concurrency(t *testing.T) {
+	logger := zerolog.Nop()
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	plan := &Plan{GroupID: "group-id"}
+	for i := 0; i < 20; i++ {
+		orgID := fmt.Sprintf("org-%d", i)
+		plan.Users = append(plan.Users, UserPlan{
+			SourceIdentifier:  fmt.Sprintf("user%d@source.com", i),
+			DestinationUserID: fmt.Sprintf("dst-%d", i),
+			Operations:        []Operation{{Action: ActionCreateOrgMembership, OrgID: orgID, RoleID: "role-admin"}},
+		})
+		mockClient.On("Post", fmt.Sprintf("/rest/orgs/%s/memberships", orgID), mock.Anything).Return([]byte(`{"data":{"id":"om-1"}}`), nil).Once()
+	}
+
+	report, err := m.applyPlan(plan, SyncOptions{Concurrency: 8}, nil, &logger)
+	assert.NoError(t, err)
+	assert.Len(t, report.Users, 20)
+	assert.Empty(t, report.FailedUsers())
+	mockClient.AssertExpectations(t)
+}
+
+func TestPlanMemberships_Concurrency(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "group-id"
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	users := sso.Users{}
+	for i := 0; i < 10; i++ {
+		srcID := fmt.Sprintf("src-%d", i)
+		dstID := fmt.Sprintf("dst-%d", i)
+		users.Data = append(users.Data,
+			makeUser(srcID, fmt.Sprintf("user%d@source.com", i), fmt.Sprintf("user%d@source.com", i)),
+			makeUser(dstID, fmt.Sprintf("user%d@destination.com", i), fmt.Sprintf("user%d", i)))
+
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=%s", groupID, srcID)).
+			Return(mockMembershipsResponse(makeGroupMembership("gm-"+srcID, groupID, "Group", "role-member", "Group Member")), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=%s", groupID, srcID)).
+			Return(mockMembershipsResponse(makeOrgMembership("om-"+srcID, "org-1", "Org One", "role-admin", "Org Admin")), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=%s", groupID, dstID)).
+			Return(mockMembershipsResponse(), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=%s", groupID, dstID)).
+			Return(mockMembershipsResponse(), nil)
+	}
+
+	match := MatchOptions{Domains: []string{"source.com"}, SSODomain: "destination.com"}
+	sequential := m.PlanMemberships(groupID, users, match, SyncOptions{}, &logger)
+	concurrent := m.PlanMemberships(groupID, users, match, SyncOptions{Concurrency: 4}, &logger)
+	assert.Len(t, concurrent.Users, 10)
+	assert.Equal(t, sequential, concurrent)
+}
@@ -0,0 +1,213 @@
+package membership
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+)
+
+const (
+	// ConflictOneToMany is the kind of conflict of a pre-migrated User matching several provisioned Users.
+	ConflictOneToMany = "one-to-many"
+	// ConflictManyToOne is the kind of conflict of several pre-migrated Users matching the same provisioned User.
+	ConflictManyToOne = "many-to-one"
+	// ConflictDuplicateSource is the kind of conflict of several pre-migrated Users sharing the same identifier.
+	ConflictDuplicateSource = "duplicate-source"
+)
+
+// Conflict is an ambiguous pairing of pre-migrated Users with provisioned Users, none of its pre-migrated Users is synchronized.
+type Conflict struct {
+	Kind                   string   `json:"kind"`
+	SourceIdentifiers      []string `json:"sourceIdentifiers"`
+	DestinationIdentifiers []string `json:"destinationIdentifiers"`
+	DestinationUserIDs     []string `json:"destinationUserIds"`
+}
+
+// String describes the Conflict.
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s conflict: %s -> %s", c.Kind, strings.Join(c.SourceIdentifiers, ", "), strings.Join(c.DestinationIdentifiers, ", "))
+}
+
+// destinationIdentifier returns the identifier of a provisioned User, its username if matchToLocalPart is true, otherwise its email.
+func destinationIdentifier(u sso.User, matchToLocalPart bool) string {
+	if matchToLocalPart {
+		return attribute(u.Attributes.UserName)
+	}
+	return attribute(u.Attributes.Email)
+}
+
+// findProvisionedUsers returns every provisioned User matching a pre-migrated User.
+func findProvisionedUsers(prevKeyID string, users sso.Users, match MatchOptions) []sso.User {
+	localPart, provisionedEmail, _ := provisionedIdentifiers(prevKeyID, match)
+	var found []sso.User
+	for _, u := range users.Data {
+		if u.ID != nil && u.Attributes != nil && matchToUserProperty(u, localPart, provisionedEmail, match.MatchToLocalPart) {
+			found = append(found, u)
+		}
+	}
+	return found
+}
+
+// findOverride returns the destination identifier overriding the pairing of a pre-migrated User.
+func findOverride(prevKeyID string, overrides []UserMapping) (string, bool) {
+	for _, o := range overrides {
+		if strings.EqualFold(o.SourceIdentifier, prevKeyID) {
+			return o.DestinationIdentifier, true
+		}
+	}
+	return "", false
+}
+
+// pairProvisionedUsers pairs every pre-migrated User with its single provisioned User, the overrides pair their Users as listed.
+// A pre-migrated User sharing its identifier, matching several provisioned Users or matching the same provisioned User as another
+// pre-migrated User is not paired and listed in the conflicts instead. A pairing overridden wins over a matched one.
+// It returns the pairs and the conflicts sorted by the identifier of the pre-migrated User.
+func pairProvisionedUsers(sourceUsers []sso.User, users sso.Users, match MatchOptions) ([]userPair, []Conflict) {
+	var conflicts []Conflict
+
+	sourcesByKeyID := make(map[string][]sso.User)
+	for _, u := range sourceUsers {
+		prevKeyID := userKeyIdentifier(u, match.MatchByUserName)
+		sourcesByKeyID[prevKeyID] = append(sourcesByKeyID[prevKeyID], u)
+	}
+	prevKeyIDs := make([]string, 0, len(sourcesByKeyID))
+	for prevKeyID := range sourcesByKeyID {
+		prevKeyIDs = append(prevKeyIDs, prevKeyID)
+	}
+	sort.Strings(prevKeyIDs)
+
+	var pairs []userPair
+	for _, prevKeyID := range prevKeyIDs {
+		sources := sourcesByKeyID[prevKeyID]
+		if len(sources) > 1 {
+			conflicts = append(conflicts, Conflict{
+				Kind:                   ConflictDuplicateSource,
+				SourceIdentifiers:      []string{prevKeyID},
+				DestinationIdentifiers: []string{},
+				DestinationUserIDs:     []string{},
+			})
+			continue
+		}
+
+		if identifier, ok := findOverride(prevKeyID, match.Overrides); ok {
+			destination, err := resolveMappingUser(users, identifier)
+			if err == nil {
+				pairs = append(pairs, userPair{
+					mapping:     UserMapping{SourceIdentifier: prevKeyID, DestinationIdentifier: identifier},
+					source:      sources[0],
+					destination: destination,
+				})
+				continue
+			}
+		}
+
+		candidates := findProvisionedUsers(prevKeyID, users, match)
+		switch len(candidates) {
+		case 0:
+			continue
+		case 1:
+			pairs = append(pairs, userPair{
+				mapping:     UserMapping{SourceIdentifier: prevKeyID, DestinationIdentifier: destinationIdentifier(candidates[0], match.MatchToLocalPart)},
+				source:      sources[0],
+				destination: candidates[0],
+			})
+		default:
+			c := Conflict{Kind: ConflictOneToMany, SourceIdentifiers: []string{prevKeyID}}
+			for _, u := range candidates {
+				c.DestinationIdentifiers = append(c.DestinationIdentifiers, destinationIdentifier(u, match.MatchToLocalPart))
+				c.DestinationUserIDs = append(c.DestinationUserIDs, *u.ID)
+			}
+			conflicts = append(conflicts, c)
+		}
+	}
+
+	paired, manyToOne := refuseManyToOne(pairs, match.Overrides, match.MatchToLocalPart)
+	conflicts = append(conflicts, manyToOne...)
+
+	sort.SliceStable(paired, func(i, j int) bool {
+		return paired[i].mapping.SourceIdentifier < paired[j].mapping.SourceIdentifier
+	})
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].SourceIdentifiers[0] < conflicts[j].SourceIdentifiers[0]
+	})
+	return paired, conflicts
+}
+
+// refuseManyToOne refuses the pairs of several pre-migrated Users with the same provisioned User, listing them in a many-to-one conflict,
+// unless a single one of these pairs is overridden: the overridden pair is kept and the other pairs are refused.
+// It returns the kept pairs in their order and the conflicts in the order of their provisioned User.
+func refuseManyToOne(pairs []userPair, overrides []UserMapping, matchToLocalPart bool) ([]userPair, []Conflict) {
+	pairsByDestination := make(map[string][]userPair)
+	var destinationIDs []string
+	for _, pair := range pairs {
+		destinationID := *pair.destination.ID
+		if len(pairsByDestination[destinationID]) == 0 {
+			destinationIDs = append(destinationIDs, destinationID)
+		}
+		pairsByDestination[destinationID] = append(pairsByDestination[destinationID], pair)
+	}
+
+	var paired []userPair
+	var conflicts []Conflict
+	for _, destinationID := range destinationIDs {
+		destinationPairs := pairsByDestination[destinationID]
+		if len(destinationPairs) == 1 {
+			paired = append(paired, destinationPairs[0])
+			continue
+		}
+		var overridden []userPair
+		for _, pair := range destinationPairs {
+			if isOverride(pair, overrides) {
+				overridden = append(overridden, pair)
+			}
+		}
+		c := Conflict{
+			Kind:                   ConflictManyToOne,
+			DestinationIdentifiers: []string{destinationIdentifier(destinationPairs[0].destination, matchToLocalPart)},
+			DestinationUserIDs:     []string{destinationID},
+		}
+		for _, pair := range destinationPairs {
+			if len(overridden) == 1 && isOverride(pair, overrides) {
+				paired = append(paired, pair)
+			} else {
+				c.SourceIdentifiers = append(c.SourceIdentifiers, pair.mapping.SourceIdentifier)
+			}
+		}
+		conflicts = append(conflicts, c)
+	}
+	return paired, conflicts
+}
+
+// isOverride checks the pair was paired by an override.
+func isOverride(pair userPair, overrides []UserMapping) bool {
+	_, ok := findOverride(pair.mapping.SourceIdentifier, overrides)
+	return ok
+}
+
+// WriteConflictsCSV writes a line per conflicting pre-migrated User and candidate provisioned User.
+func WriteConflictsCSV(w io.Writer, conflicts []Conflict) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"kind", "sourceIdentifier", "destinationIdentifier", "destinationUserId"}); err != nil {
+		return err
+	}
+	for _, c := range conflicts {
+		for _, sourceIdentifier := range c.SourceIdentifiers {
+			if len(c.DestinationUserIDs) == 0 {
+				if err := writer.Write([]string{c.Kind, sourceIdentifier, "", ""}); err != nil {
+					return err
+				}
+			}
+			for i, destinationUserID := range c.DestinationUserIDs {
+				if err := writer.Write([]string{c.Kind, sourceIdentifier, c.DestinationIdentifiers[i], destinationUserID}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
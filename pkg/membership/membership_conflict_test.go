@@ -0,0 +1,143 @@
+package membership
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPairProvisionedUsers(t *testing.T) {
+	transforms, err := NewTransforms([]TransformRule{{Type: TransformReplace, From: "_", To: "."}})
+	assert.NoError(t, err)
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "alice@old.com", "alice@old.com"),
+		makeUser("src-2", "john.doe@old.com", "john.doe@old.com"),
+		makeUser("src-3", "john_doe@old.com", "john_doe@old.com"),
+		makeUser("src-4", "bob@old.com", "bob@old.com"),
+		makeUser("src-5", "bob@old.com", "bob2@old.com"),
+		makeUser("src-6", "carol@old.com", "carol@old.com"),
+		makeUser("dst-1", "alice@new.com", "alice"),
+		makeUser("dst-2", "john.doe@new.com", "john.doe"),
+		makeUser("dst-3", "carol@new.com", "carol"),
+		makeUser("dst-4", "carol@new.com", "carol.smith"),
+	}}
+	var sourceUsers []sso.User
+	for _, u := range users.Data[:6] {
+		sourceUsers = append(sourceUsers, u)
+	}
+	match := MatchOptions{Domains: []string{"old.com"}, SSODomain: "new.com", Transforms: transforms}
+
+	t.Run("refuses the conflicting pairings", func(t *testing.T) {
+		pairs, conflicts := pairProvisionedUsers(sourceUsers, users, match)
+		assert.Len(t, pairs, 1)
+		assert.Equal(t, "alice@old.com", pairs[0].mapping.SourceIdentifier)
+		assert.Equal(t, "dst-1", *pairs[0].destination.ID)
+
+		assert.Equal(t, []Conflict{
+			{Kind: ConflictDuplicateSource, SourceIdentifiers: []string{"bob@old.com"}, DestinationIdentifiers: []string{}, DestinationUserIDs: []string{}},
+			{Kind: ConflictOneToMany, SourceIdentifiers: []string{"carol@old.com"}, DestinationIdentifiers: []string{"carol@new.com", "carol@new.com"}, DestinationUserIDs: []string{"dst-3", "dst-4"}},
+			{Kind: ConflictManyToOne, SourceIdentifiers: []string{"john.doe@old.com", "john_doe@old.com"}, DestinationIdentifiers: []string{"john.doe@new.com"}, DestinationUserIDs: []string{"dst-2"}},
+		}, conflicts)
+	})
+
+	t.Run("overrides resolve the conflicts", func(t *testing.T) {
+		match := match
+		match.Overrides = []UserMapping{
+			{SourceIdentifier: "john.doe@old.com", DestinationIdentifier: "john.doe"},
+			{SourceIdentifier: "carol@old.com", DestinationIdentifier: "carol.smith"},
+		}
+		pairs, conflicts := pairProvisionedUsers(sourceUsers, users, match)
+		assert.Len(t, pairs, 3)
+		assert.Equal(t, "carol@old.com", pairs[1].mapping.SourceIdentifier)
+		assert.Equal(t, "dst-4", *pairs[1].destination.ID)
+		assert.Equal(t, "john.doe@old.com", pairs[2].mapping.SourceIdentifier)
+		assert.Equal(t, "dst-2", *pairs[2].destination.ID)
+
+		// the matched pairing colliding with an overridden one is still refused
+		assert.Len(t, conflicts, 2)
+		assert.Equal(t, ConflictDuplicateSource, conflicts[0].Kind)
+		assert.Equal(t, ConflictManyToOne, conflicts[1].Kind)
+		assert.Equal(t, []string{"john_doe@old.com"}, conflicts[1].SourceIdentifiers)
+	})
+
+	t.Run("overrides to the same provisioned User conflict", func(t *testing.T) {
+		match := match
+		match.Overrides = []UserMapping{
+			{SourceIdentifier: "alice@old.com", DestinationIdentifier: "john.doe"},
+			{SourceIdentifier: "john.doe@old.com", DestinationIdentifier: "john.doe"},
+		}
+		pairs, conflicts := pairProvisionedUsers(sourceUsers, users, match)
+		assert.Empty(t, pairs)
+		assert.Contains(t, conflicts, Conflict{
+			Kind:                   ConflictManyToOne,
+			SourceIdentifiers:      []string{"alice@old.com", "john.doe@old.com", "john_doe@old.com"},
+			DestinationIdentifiers: []string{"john.doe@new.com"},
+			DestinationUserIDs:     []string{"dst-2"},
+		})
+	})
+}
+
+func TestPlanMemberships_Conflicts(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "group-id"
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "john.doe@old.com", "john.doe@old.com"),
+		makeUser("src-2", "john_doe@old.com", "john_doe@old.com"),
+		makeUser("dst-1", "jdoe@new.com", "john.doe"),
+	}}
+	for _, userID := range []string{"src-1", "src-2"} {
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=%s", groupID, userID)).
+			Return(mockMembershipsResponse(makeGroupMembership("gm-"+userID, groupID, "Group", "role-member", "Group Member")), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=%s", groupID, userID)).
+			Return(mockMembershipsResponse(), nil)
+	}
+
+	// both source users match the same destination user by their transformed local part
+	transforms, err := NewTransforms([]TransformRule{{Type: TransformReplace, From: "_", To: "."}})
+	assert.NoError(t, err)
+	match := MatchOptions{Domains: []string{"old.com"}, MatchToLocalPart: true, Transforms: transforms}
+	plan := m.PlanMemberships(groupID, users, match, SyncOptions{}, &logger)
+	assert.Empty(t, plan.Users)
+	assert.Empty(t, plan.Unmatched)
+	assert.Equal(t, []Conflict{{
+		Kind:                   ConflictManyToOne,
+		SourceIdentifiers:      []string{"john.doe@old.com", "john_doe@old.com"},
+		DestinationIdentifiers: []string{"john.doe"},
+		DestinationUserIDs:     []string{"dst-1"},
+	}}, plan.Conflicts)
+	// the memberships of the destination user are never fetched
+	mockClient.AssertNotCalled(t, "Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=dst-1", groupID))
+
+	var buf bytes.Buffer
+	assert.NoError(t, plan.Write(&buf))
+	assert.Contains(t, buf.String(), "Skipped many-to-one conflict: john.doe@old.com, john_doe@old.com -> john.doe\n")
+
+	report, err := m.applyPlan(plan, SyncOptions{Concurrency: 1}, nil, &logger)
+	assert.NoError(t, err)
+	assert.Len(t, report.Users, 2)
+	assert.Equal(t, StatusSkipped, report.Users[0].Status)
+	assert.Equal(t, "many-to-one conflict: john.doe@old.com, john_doe@old.com -> john.doe", report.Users[0].Error)
+	assert.Equal(t, plan.Conflicts, report.Conflicts)
+	assert.NoError(t, report.Err())
+}
+
+func TestWriteConflictsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteConflictsCSV(&buf, []Conflict{
+		{Kind: ConflictOneToMany, SourceIdentifiers: []string{"carol@old.com"}, DestinationIdentifiers: []string{"carol", "carol.smith"}, DestinationUserIDs: []string{"dst-3", "dst-4"}},
+		{Kind: ConflictDuplicateSource, SourceIdentifiers: []string{"bob@old.com"}, DestinationIdentifiers: []string{}, DestinationUserIDs: []string{}},
+	}))
+	expected := "kind,sourceIdentifier,destinationIdentifier,destinationUserId\n" +
+		"one-to-many,carol@old.com,carol,dst-3\n" +
+		"one-to-many,carol@old.com,carol.smith,dst-4\n" +
+		"duplicate-source,bob@old.com,,\n"
+	assert.Equal(t, expected, buf.String())
+}
@@ -0,0 +1,331 @@
+package membership
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+)
+
+const (
+	// DecisionMatched is the decision of a pre-migrated User paired with its provisioned User.
+	DecisionMatched = "matched"
+	// DecisionNotSourceUser is the decision of a User rejected as a pre-migrated User.
+	DecisionNotSourceUser = "not a source user"
+	// DecisionNoDestinationUser is the decision of a pre-migrated User without a provisioned User.
+	DecisionNoDestinationUser = "no destination user"
+	// DecisionSourceNotFound is the decision of an identifier without a User.
+	DecisionSourceNotFound = "source user not found"
+	// DecisionOverridden is the decision of a pre-migrated User paired with its provisioned User by an override.
+	DecisionOverridden = "overridden"
+	// DecisionConflict is the decision of a pre-migrated User refused by an ambiguous pairing.
+	DecisionConflict = "conflict"
+)
+
+// RuleResult is the outcome of a rule evaluated to pair the Users.
+type RuleResult struct {
+	Rule   string `json:"rule"`
+	Passed bool   `json:"passed"`
+}
+
+// MatchCandidate is a User considered as the provisioned User, sharing the local part of the pre-migrated User.
+type MatchCandidate struct {
+	UserID   string       `json:"userId"`
+	Email    string       `json:"email"`
+	UserName string       `json:"username"`
+	Rules    []RuleResult `json:"rules"`
+	Matched  bool         `json:"matched"`
+}
+
+// MatchExplanation describes how the provisioned User of a pre-migrated User is looked up.
+type MatchExplanation struct {
+	SourceIdentifier      string           `json:"sourceIdentifier"`
+	SourceUserID          string           `json:"sourceUserId,omitempty"`
+	SourceRules           []RuleResult     `json:"sourceRules"`
+	LocalPart             string           `json:"localPart,omitempty"`
+	Transforms            []TransformStep  `json:"transforms,omitempty"`
+	DestinationIdentifier string           `json:"destinationIdentifier,omitempty"`
+	Candidates            []MatchCandidate `json:"candidates"`
+	DestinationUserID     string           `json:"destinationUserId,omitempty"`
+	Matched               bool             `json:"matched"`
+	Decision              string           `json:"decision"`
+	Conflict              *Conflict        `json:"conflict,omitempty"`
+}
+
+// attribute returns the value of an optional User attribute.
+func attribute(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// explainSourceDomainUser evaluates the rules of matchSourceDomainUser one by one, the User is a pre-migrated User if every rule passed.
+func explainSourceDomainUser(u sso.User, match MatchOptions) []RuleResult {
+	hasAttributes := u.Attributes != nil && u.Attributes.Email != nil && u.Attributes.UserName != nil
+	rules := []RuleResult{{Rule: "email and username are set", Passed: hasAttributes}}
+	if !hasAttributes {
+		return rules
+	}
+	email, userName := *u.Attributes.Email, *u.Attributes.UserName
+
+	// the domain rule names the domain the User is on, or every domain if none
+	domain, onDomain := match.sourceDomain(userKeyIdentifier(u, match.MatchByUserName))
+	domains := domain
+	if !onDomain {
+		domains = strings.Join(match.Domains, " or ")
+	}
+
+	if match.MatchByUserName {
+		rules = append(rules, RuleResult{Rule: "username on domain " + domains, Passed: onDomain})
+		if match.MatchToLocalPart {
+			return append(rules, RuleResult{Rule: "email is an RFC5322 address", Passed: isValidEmailRFC5322(email)})
+		}
+		ssoDomain := match.ssoDomainOf(domain)
+		return append(rules, RuleResult{Rule: "email not on ssoDomain " + ssoDomain, Passed: !strings.HasSuffix(email, "@"+ssoDomain)})
+	}
+
+	rules = append(rules, RuleResult{Rule: "email on domain " + domains, Passed: onDomain})
+	if match.MatchToLocalPart {
+		return append(rules, RuleResult{Rule: "username is an RFC5322 address", Passed: isValidEmailRFC5322(userName)})
+	}
+	return rules
+}
+
+// passed checks every rule passed.
+func passed(rules []RuleResult) bool {
+	for _, rule := range rules {
+		if !rule.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// sharesLocalPart checks the email, the username or the local part of the username of a User is the case-insensitive local part.
+func sharesLocalPart(u sso.User, localPart string) bool {
+	if u.Attributes == nil {
+		return false
+	}
+	email, userName := attribute(u.Attributes.Email), attribute(u.Attributes.UserName)
+	return strings.EqualFold(strings.Split(email, "@")[0], localPart) ||
+		strings.EqualFold(strings.Split(userName, "@")[0], localPart)
+}
+
+// explainMatch explains the lookup of the provisioned User of a User, evaluating sourceRules to accept it as a pre-migrated User.
+func explainMatch(u sso.User, users sso.Users, match MatchOptions, sourceRules []RuleResult) MatchExplanation {
+	prevKeyID := userKeyIdentifier(u, match.MatchByUserName)
+	e := MatchExplanation{
+		SourceIdentifier: prevKeyID,
+		SourceUserID:     attribute(u.ID),
+		SourceRules:      sourceRules,
+		Candidates:       []MatchCandidate{},
+		Decision:         DecisionNotSourceUser,
+	}
+	if !passed(sourceRules) {
+		return e
+	}
+
+	localPart, provisionedEmail, steps := provisionedIdentifiers(prevKeyID, match)
+	e.LocalPart = IdentifierLocalPart(prevKeyID)
+	e.Transforms = steps
+	rule := "email equals " + provisionedEmail
+	e.DestinationIdentifier = provisionedEmail
+	if match.MatchToLocalPart {
+		rule = "username equals " + localPart
+		e.DestinationIdentifier = localPart
+	}
+
+	e.Decision = DecisionNoDestinationUser
+	for _, pu := range users.Data {
+		if pu.ID == nil || (u.ID != nil && *pu.ID == *u.ID) || !sharesLocalPart(pu, localPart) {
+			continue
+		}
+		c := MatchCandidate{
+			UserID:   *pu.ID,
+			Email:    attribute(pu.Attributes.Email),
+			UserName: attribute(pu.Attributes.UserName),
+			Rules:    []RuleResult{{Rule: rule, Passed: matchToUserProperty(pu, localPart, provisionedEmail, match.MatchToLocalPart)}},
+		}
+		e.Candidates = append(e.Candidates, c)
+	}
+	return e
+}
+
+// decideMatches pairs the pre-migrated Users of the explanations as sync does, refusing the conflicting pairings.
+func decideMatches(explanations []MatchExplanation, sourceUsers []sso.User, users sso.Users, match MatchOptions) {
+	pairs, conflicts := pairProvisionedUsers(sourceUsers, users, match)
+	pairsByKeyID := make(map[string]userPair)
+	for _, pair := range pairs {
+		pairsByKeyID[pair.mapping.SourceIdentifier] = pair
+	}
+	conflictsByKeyID := make(map[string]Conflict)
+	for _, c := range conflicts {
+		for _, prevKeyID := range c.SourceIdentifiers {
+			conflictsByKeyID[prevKeyID] = c
+		}
+	}
+
+	for i := range explanations {
+		e := &explanations[i]
+		if e.Decision != DecisionNoDestinationUser {
+			continue
+		}
+		if c, ok := conflictsByKeyID[e.SourceIdentifier]; ok {
+			e.Decision = DecisionConflict
+			e.Conflict = &c
+			continue
+		}
+		pair, ok := pairsByKeyID[e.SourceIdentifier]
+		if !ok {
+			continue
+		}
+		e.Matched = true
+		e.Decision = DecisionMatched
+		e.DestinationIdentifier = pair.mapping.DestinationIdentifier
+		e.DestinationUserID = *pair.destination.ID
+		overridden := isOverride(pair, match.Overrides)
+		if overridden {
+			e.Decision = DecisionOverridden
+		}
+
+		found := false
+		for j := range e.Candidates {
+			c := &e.Candidates[j]
+			if c.UserID != e.DestinationUserID {
+				continue
+			}
+			found = true
+			c.Matched = true
+			if overridden {
+				c.Rules = append(c.Rules, RuleResult{Rule: "override " + pair.mapping.DestinationIdentifier, Passed: true})
+			}
+		}
+		if !found {
+			e.Candidates = append(e.Candidates, MatchCandidate{
+				UserID:   e.DestinationUserID,
+				Email:    attribute(pair.destination.Attributes.Email),
+				UserName: attribute(pair.destination.Attributes.UserName),
+				Rules:    []RuleResult{{Rule: "override " + pair.mapping.DestinationIdentifier, Passed: true}},
+				Matched:  true,
+			})
+		}
+	}
+}
+
+// ExplainMatches explains the lookup of the provisioned User of the pre-migrated Users as sync does, without fetching any membership.
+// Every User identified on the domain is explained, or only the Users of the identifiers if any.
+// The explanations of the domain are sorted by the identifier of the pre-migrated User.
+func ExplainMatches(users sso.Users, match MatchOptions, identifiers []string) []MatchExplanation {
+	explanations := []MatchExplanation{}
+	var sourceUsers []sso.User
+	if len(identifiers) > 0 {
+		for _, identifier := range identifiers {
+			found := false
+			for _, u := range users.Data {
+				if u.ID == nil || userKeyIdentifier(u, match.MatchByUserName) != identifier {
+					continue
+				}
+				found = true
+				sourceRules := explainSourceDomainUser(u, match)
+				if !match.MatchByUserName && !match.MatchToLocalPart {
+					// a listed email also requires a username of email-address format
+					sourceRules = append(sourceRules, RuleResult{Rule: "username is an RFC5322 address", Passed: u.Attributes.UserName != nil && isValidEmailRFC5322(*u.Attributes.UserName)})
+				}
+				explanations = append(explanations, explainMatch(u, users, match, sourceRules))
+				if passed(sourceRules) {
+					sourceUsers = append(sourceUsers, u)
+				}
+			}
+			if !found {
+				explanations = append(explanations, MatchExplanation{
+					SourceIdentifier: identifier,
+					SourceRules:      []RuleResult{},
+					Candidates:       []MatchCandidate{},
+					Decision:         DecisionSourceNotFound,
+				})
+			}
+		}
+		decideMatches(explanations, sourceUsers, users, match)
+		return explanations
+	}
+
+	for _, u := range users.Data {
+		if _, ok := match.sourceDomain(userKeyIdentifier(u, match.MatchByUserName)); u.ID == nil || !ok {
+			continue
+		}
+		sourceRules := explainSourceDomainUser(u, match)
+		explanations = append(explanations, explainMatch(u, users, match, sourceRules))
+		if passed(sourceRules) {
+			sourceUsers = append(sourceUsers, u)
+		}
+	}
+	decideMatches(explanations, sourceUsers, users, match)
+	sort.SliceStable(explanations, func(i, j int) bool {
+		return explanations[i].SourceIdentifier < explanations[j].SourceIdentifier
+	})
+	return explanations
+}
+
+// passedMark renders the outcome of a rule.
+func passedMark(passed bool) string {
+	if passed {
+		return "pass"
+	}
+	return "fail"
+}
+
+// WriteExplanationsTable writes a table of the decision of every pre-migrated User.
+// If explain is true, the table lists every rule evaluated, transform step and candidate instead.
+func WriteExplanationsTable(w io.Writer, explanations []MatchExplanation, explain bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	var matched int
+	if explain {
+		fmt.Fprintln(tw, "SOURCE\tSTEP\tRESULT")
+	} else {
+		fmt.Fprintln(tw, "SOURCE\tDESTINATION\tDECISION")
+	}
+	for _, e := range explanations {
+		if e.Matched {
+			matched++
+		}
+		if !explain {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", e.SourceIdentifier, e.DestinationIdentifier, e.Decision)
+			continue
+		}
+		for _, rule := range e.SourceRules {
+			fmt.Fprintf(tw, "%s\tsource: %s\t%s\n", e.SourceIdentifier, rule.Rule, passedMark(rule.Passed))
+		}
+		for _, step := range e.Transforms {
+			fmt.Fprintf(tw, "%s\ttransform: %s\t%s -> %s\n", e.SourceIdentifier, step.Rule, step.Input, step.Output)
+		}
+		for _, c := range e.Candidates {
+			for _, rule := range c.Rules {
+				fmt.Fprintf(tw, "%s\tcandidate %s (%s, %s): %s\t%s\n", e.SourceIdentifier, c.UserID, c.Email, c.UserName, rule.Rule, passedMark(rule.Passed))
+			}
+		}
+		decision := e.Decision
+		if e.Matched {
+			decision = fmt.Sprintf("%s %s (%s)", e.Decision, e.DestinationIdentifier, e.DestinationUserID)
+		} else if e.Conflict != nil {
+			decision = e.Conflict.String()
+		}
+		fmt.Fprintf(tw, "%s\tdecision\t%s\n", e.SourceIdentifier, decision)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "Match: %d Users, %d matched, %d not matched\n", len(explanations), matched, len(explanations)-matched)
+	return err
+}
+
+// WriteExplanationsJSON writes the explanations as indented JSON.
+func WriteExplanationsJSON(w io.Writer, explanations []MatchExplanation) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(explanations)
+}
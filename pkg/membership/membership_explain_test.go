@@ -0,0 +1,204 @@
+package membership
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainMatches_Decisions(t *testing.T) {
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "John_Doe+ci@old.com", "John_Doe+ci@old.com"),
+		makeUser("src-2", "jane@old.com", "jane@old.com"),
+		makeUser("src-3", "bob@old.com", "bob"),
+		makeUser("dst-1", "john.doe@new.com", "john.doe"),
+		makeUser("dst-2", "Jane@new.com", "jane"),
+	}}
+	transforms, err := NewTransforms([]TransformRule{
+		{Type: TransformRegex, Pattern: `\+.*$`},
+		{Type: TransformReplace, From: "_", To: "."},
+		{Type: TransformLowercase},
+	})
+	assert.NoError(t, err)
+	match := MatchOptions{Domains: []string{"old.com"}, SSODomain: "new.com", Transforms: transforms}
+
+	t.Run("every user of the domain", func(t *testing.T) {
+		explanations := ExplainMatches(users, match, nil)
+		assert.Len(t, explanations, 3)
+
+		bob := explanations[1]
+		assert.Equal(t, "bob@old.com", bob.SourceIdentifier)
+		assert.Equal(t, DecisionNoDestinationUser, bob.Decision)
+		assert.Empty(t, bob.Candidates)
+
+		jane := explanations[2]
+		assert.Equal(t, "jane@old.com", jane.SourceIdentifier)
+		assert.Equal(t, DecisionNoDestinationUser, jane.Decision)
+		assert.Equal(t, []MatchCandidate{{
+			UserID:   "dst-2",
+			Email:    "Jane@new.com",
+			UserName: "jane",
+			Rules:    []RuleResult{{Rule: "email equals jane@new.com", Passed: false}},
+		}}, jane.Candidates)
+
+		john := explanations[0]
+		assert.Equal(t, "John_Doe+ci@old.com", john.SourceIdentifier)
+		assert.Equal(t, []RuleResult{{Rule: "email and username are set", Passed: true}, {Rule: "email on domain old.com", Passed: true}}, john.SourceRules)
+		assert.Equal(t, "John_Doe+ci", john.LocalPart)
+		assert.Len(t, john.Transforms, 3)
+		assert.Equal(t, "john.doe@new.com", john.DestinationIdentifier)
+		assert.Equal(t, "dst-1", john.DestinationUserID)
+		assert.True(t, john.Matched)
+		assert.Equal(t, DecisionMatched, john.Decision)
+	})
+
+	t.Run("given identifiers", func(t *testing.T) {
+		explanations := ExplainMatches(users, match, []string{"bob@old.com", "missing@old.com"})
+		assert.Len(t, explanations, 2)
+		assert.Equal(t, DecisionNotSourceUser, explanations[0].Decision)
+		assert.Equal(t, RuleResult{Rule: "username is an RFC5322 address", Passed: false}, explanations[0].SourceRules[2])
+		assert.Equal(t, "missing@old.com", explanations[1].SourceIdentifier)
+		assert.Equal(t, DecisionSourceNotFound, explanations[1].Decision)
+	})
+}
+
+func TestExplainMatches_Conflicts(t *testing.T) {
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "carol@old.com", "carol@old.com"),
+		makeUser("src-2", "dave@old.com", "dave@old.com"),
+		makeUser("dst-1", "carol@new.com", "carol"),
+		makeUser("dst-2", "carol@new.com", "carol.smith"),
+		makeUser("dst-3", "david@new.com", "dave"),
+	}}
+	match := MatchOptions{Domains: []string{"old.com"}, SSODomain: "new.com", Overrides: []UserMapping{{SourceIdentifier: "dave@old.com", DestinationIdentifier: "david@new.com"}}}
+
+	explanations := ExplainMatches(users, match, nil)
+	assert.Len(t, explanations, 2)
+
+	carol := explanations[0]
+	assert.Equal(t, DecisionConflict, carol.Decision)
+	assert.False(t, carol.Matched)
+	assert.Equal(t, ConflictOneToMany, carol.Conflict.Kind)
+	assert.Len(t, carol.Candidates, 2)
+
+	dave := explanations[1]
+	assert.Equal(t, DecisionOverridden, dave.Decision)
+	assert.True(t, dave.Matched)
+	assert.Equal(t, "dst-3", dave.DestinationUserID)
+	assert.Equal(t, []MatchCandidate{{
+		UserID:   "dst-3",
+		Email:    "david@new.com",
+		UserName: "dave",
+		Rules: []RuleResult{
+			{Rule: "email equals dave@new.com", Passed: false},
+			{Rule: "override david@new.com", Passed: true},
+		},
+		Matched: true,
+	}}, dave.Candidates)
+}
+
+func TestExplainSourceDomainUser(t *testing.T) {
+	users := []sso.User{
+		makeUser("1", "user@old.com", "user@old.com"),
+		makeUser("2", "user@old.com", "user"),
+		makeUser("3", "user@new.com", "user@old.com"),
+		makeUser("4", "not an email", "user@old.com"),
+		makeUser("5", "user@other.com", "user@other.com"),
+	}
+	for _, matchByUserName := range []bool{false, true} {
+		for _, matchToLocalPart := range []bool{false, true} {
+			match := MatchOptions{Domains: []string{"old.com"}, SSODomain: "new.com", MatchByUserName: matchByUserName, MatchToLocalPart: matchToLocalPart}
+			for _, u := range users {
+				assert.Equal(t, matchSourceDomainUser(u, match.Domains[0], match.SSODomain, matchByUserName, matchToLocalPart),
+					passed(explainSourceDomainUser(u, match)), "user %s, matchByUserName %t, matchToLocalPart %t", *u.ID, matchByUserName, matchToLocalPart)
+			}
+		}
+	}
+}
+
+func TestExplainSourceDomainUser_Domains(t *testing.T) {
+	match := MatchOptions{Domains: []string{"old.com", "other.com"}, SSODomain: "new.com", DomainMap: map[string]string{"other.com": "new.io"}}
+	for _, u := range []sso.User{
+		makeUser("1", "user@old.com", "user@old.com"),
+		makeUser("2", "user@other.com", "user@other.com"),
+		makeUser("3", "user@new.com", "user@new.com"),
+	} {
+		for _, matchByUserName := range []bool{false, true} {
+			match.MatchByUserName = matchByUserName
+			assert.Equal(t, matchSourceUser(u, match), passed(explainSourceDomainUser(u, match)), "user %s, matchByUserName %t", *u.ID, matchByUserName)
+		}
+	}
+
+	match.MatchByUserName = true
+	assert.Equal(t, []RuleResult{
+		{Rule: "email and username are set", Passed: true},
+		{Rule: "username on domain other.com", Passed: true},
+		{Rule: "email not on ssoDomain new.io", Passed: true},
+	}, explainSourceDomainUser(makeUser("2", "user@other.com", "user@other.com"), match))
+	assert.Equal(t, []RuleResult{
+		{Rule: "email and username are set", Passed: true},
+		{Rule: "username on domain old.com or other.com", Passed: false},
+		{Rule: "email not on ssoDomain new.com", Passed: false},
+	}, explainSourceDomainUser(makeUser("3", "user@new.com", "user@new.com"), match))
+}
+
+func TestWriteExplanations(t *testing.T) {
+	explanations := []MatchExplanation{
+		{
+			SourceIdentifier:      "John_Doe@old.com",
+			SourceUserID:          "src-1",
+			SourceRules:           []RuleResult{{Rule: "email on domain old.com", Passed: true}},
+			LocalPart:             "John_Doe",
+			Transforms:            []TransformStep{{Rule: "lowercase", Input: "John_Doe", Output: "john_doe"}},
+			DestinationIdentifier: "john_doe@new.com",
+			Candidates: []MatchCandidate{{
+				UserID: "dst-1", Email: "john_doe@new.com", UserName: "john_doe",
+				Rules: []RuleResult{{Rule: "email equals john_doe@new.com", Passed: true}}, Matched: true,
+			}},
+			DestinationUserID: "dst-1",
+			Matched:           true,
+			Decision:          DecisionMatched,
+		},
+		{
+			SourceIdentifier: "bob@old.com",
+			SourceRules:      []RuleResult{{Rule: "email on domain old.com", Passed: false}},
+			Candidates:       []MatchCandidate{},
+			Decision:         DecisionNotSourceUser,
+		},
+	}
+
+	t.Run("table", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, WriteExplanationsTable(&buf, explanations, false))
+		expected := "SOURCE            DESTINATION       DECISION\n" +
+			"John_Doe@old.com  john_doe@new.com  matched\n" +
+			"bob@old.com                         not a source user\n" +
+			"Match: 2 Users, 1 matched, 1 not matched\n"
+		assert.Equal(t, expected, buf.String())
+	})
+
+	t.Run("explain table", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, WriteExplanationsTable(&buf, explanations, true))
+		expected := "SOURCE            STEP                                                                         RESULT\n" +
+			"John_Doe@old.com  source: email on domain old.com                                              pass\n" +
+			"John_Doe@old.com  transform: lowercase                                                         John_Doe -> john_doe\n" +
+			"John_Doe@old.com  candidate dst-1 (john_doe@new.com, john_doe): email equals john_doe@new.com  pass\n" +
+			"John_Doe@old.com  decision                                                                     matched john_doe@new.com (dst-1)\n" +
+			"bob@old.com       source: email on domain old.com                                              fail\n" +
+			"bob@old.com       decision                                                                     not a source user\n" +
+			"Match: 2 Users, 1 matched, 1 not matched\n"
+		assert.Equal(t, expected, buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, WriteExplanationsJSON(&buf, explanations))
+		var read []MatchExplanation
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &read))
+		assert.Equal(t, explanations, read)
+	})
+}
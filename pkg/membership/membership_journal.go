@@ -0,0 +1,150 @@
+package membership
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// JournalStatusPending records a User pair whose memberships are about to be synchronized.
+	JournalStatusPending = "pending"
+	// JournalStatusGroupSynced records a User pair whose Group memberships are synchronized.
+	JournalStatusGroupSynced = "group-synced"
+	// JournalStatusOrgsSynced records a User pair whose Group and Org memberships are synchronized.
+	JournalStatusOrgsSynced = "orgs-synced"
+	// JournalStatusFailed records a User pair whose memberships failed to synchronize, it is retried on resume.
+	JournalStatusFailed = "failed"
+)
+
+// JournalRecord is a single progress line of the journal of a synchronization.
+type JournalRecord struct {
+	Time                  string `json:"time"`
+	GroupID               string `json:"groupId"`
+	SourceIdentifier      string `json:"sourceIdentifier"`
+	DestinationIdentifier string `json:"destinationIdentifier"`
+	Status                string `json:"status"`
+	Error                 string `json:"error,omitempty"`
+}
+
+// Journal records the synchronization progress of every User pair as JSON lines appended to a file,
+// so that an interrupted synchronization can be resumed. A nil Journal records nothing.
+// A Journal is safe for concurrent use.
+type Journal struct {
+	mu       sync.Mutex
+	groupID  string
+	statuses map[string]string
+	w        io.WriteCloser
+}
+
+// OpenJournal opens the journal file of a synchronization of groupID.
+// If resume is true, the progress recorded in the existing journal is loaded and new records are appended to it,
+// otherwise a new journal is created. An empty filePath opens a nil Journal.
+func OpenJournal(filePath, groupID string, resume bool) (*Journal, error) {
+	if filePath == "" {
+		if resume {
+			return nil, fmt.Errorf("resume requires a journal file")
+		}
+		return nil, nil
+	}
+
+	j := &Journal{groupID: groupID, statuses: make(map[string]string)}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		err = j.load(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		flag = os.O_APPEND | os.O_WRONLY
+	}
+
+	file, err := os.OpenFile(filePath, flag, 0664)
+	if err != nil {
+		return nil, err
+	}
+	j.w = file
+	return j, nil
+}
+
+// load replays the records of a journal, the last record of a User pair is its status.
+func (j *Journal) load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record JournalRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			// the last record may be truncated if the process was killed while writing it
+			continue
+		}
+		if record.GroupID != j.groupID {
+			return fmt.Errorf("journal of groupID %s cannot be resumed on groupID: %s", record.GroupID, j.groupID)
+		}
+		j.statuses[record.SourceIdentifier] = record.Status
+	}
+	return scanner.Err()
+}
+
+// Record appends the status of a User pair to the journal, with the failure if any.
+func (j *Journal) Record(up *UserPlan, status string, failure error) error {
+	if j == nil {
+		return nil
+	}
+
+	record := JournalRecord{
+		Time:                  time.Now().UTC().Format(time.RFC3339),
+		GroupID:               j.groupID,
+		SourceIdentifier:      up.SourceIdentifier,
+		DestinationIdentifier: up.DestinationIdentifier,
+		Status:                status,
+	}
+	if failure != nil {
+		record.Error = failure.Error()
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	j.statuses[up.SourceIdentifier] = status
+	return nil
+}
+
+// Status returns the last recorded status of a User pair by its source identifier, empty if it was never recorded.
+func (j *Journal) Status(sourceIdentifier string) string {
+	if j == nil {
+		return ""
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.statuses[sourceIdentifier]
+}
+
+// Completed checks whether the Group and Org memberships of a User pair were synchronized.
+func (j *Journal) Completed(sourceIdentifier string) bool {
+	return j.Status(sourceIdentifier) == JournalStatusOrgsSynced
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	if j == nil || j.w == nil {
+		return nil
+	}
+	return j.w.Close()
+}
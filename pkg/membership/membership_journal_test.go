@@ -0,0 +1,240 @@
+package membership
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/client"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// makeUser is a helper to create an SSO User
+func makeUser(id, email, userName string) sso.User {
+	return sso.User{ID: stringPtr(id), Attributes: &struct {
+		Name     *string `json:"name"`
+		Email    *string `json:"email"`
+		UserName *string `json:"username"`
+		Active   *bool   `json:"active"`
+	}{Email: stringPtr(email), UserName: stringPtr(userName)}}
+}
+
+func TestOpenJournal(t *testing.T) {
+	up := &UserPlan{SourceIdentifier: "user1@source.com", DestinationIdentifier: "user1"}
+
+	t.Run("no journal without file", func(t *testing.T) {
+		journal, err := OpenJournal("", "group-id", false)
+		assert.NoError(t, err)
+		assert.Nil(t, journal)
+		assert.NoError(t, journal.Record(up, JournalStatusPending, nil))
+		assert.False(t, journal.Completed("user1@source.com"))
+
+		_, err = OpenJournal("", "group-id", true)
+		assert.EqualError(t, err, "resume requires a journal file")
+	})
+
+	t.Run("resumes the recorded progress", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "journal.jsonl")
+		journal, err := OpenJournal(filePath, "group-id", false)
+		assert.NoError(t, err)
+		assert.NoError(t, journal.Record(up, JournalStatusPending, nil))
+		assert.NoError(t, journal.Record(up, JournalStatusOrgsSynced, nil))
+		assert.NoError(t, journal.Record(&UserPlan{SourceIdentifier: "user2@source.com"}, JournalStatusFailed, errors.New("api error")))
+		assert.NoError(t, journal.Close())
+
+		// a record truncated by an interrupted process is ignored
+		file, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0664)
+		assert.NoError(t, err)
+		_, err = file.WriteString(`{"time":"2025-01-01T00:00:00Z","groupId":"group-id","sourceIdent`)
+		assert.NoError(t, err)
+		file.Close()
+
+		resumed, err := OpenJournal(filePath, "group-id", true)
+		assert.NoError(t, err)
+		defer resumed.Close()
+		assert.True(t, resumed.Completed("user1@source.com"))
+		assert.False(t, resumed.Completed("user2@source.com"))
+		assert.Equal(t, JournalStatusFailed, resumed.Status("user2@source.com"))
+	})
+
+	t.Run("refuses to resume the journal of another Group", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "journal.jsonl")
+		journal, err := OpenJournal(filePath, "group-id", false)
+		assert.NoError(t, err)
+		assert.NoError(t, journal.Record(up, JournalStatusPending, nil))
+		journal.Close()
+
+		_, err = OpenJournal(filePath, "other-group-id", true)
+		assert.EqualError(t, err, "journal of groupID group-id cannot be resumed on groupID: other-group-id")
+	})
+}
+
+func TestSkipCompletedUsers(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal, err := OpenJournal(filePath, "group-id", false)
+	assert.NoError(t, err)
+	defer journal.Close()
+	assert.NoError(t, journal.Record(&UserPlan{SourceIdentifier: "user1@source.com"}, JournalStatusOrgsSynced, nil))
+	assert.NoError(t, journal.Record(&UserPlan{SourceIdentifier: "user2@source.com"}, JournalStatusGroupSynced, nil))
+
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "user1@source.com", "user1@source.com"),
+		makeUser("src-2", "user2@source.com", "user2@source.com"),
+		makeUser("dst-1", "user1@destination.com", "user1"),
+	}}
+
+	remaining := skipCompletedUsers(users, journal, false)
+	assert.Len(t, remaining.Data, 2)
+	assert.Equal(t, "src-2", *remaining.Data[0].ID)
+	assert.Equal(t, "dst-1", *remaining.Data[1].ID)
+}
+
+func TestResumeScope(t *testing.T) {
+	logger := zerolog.Nop()
+	filePath := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal, err := OpenJournal(filePath, "group-id", false)
+	assert.NoError(t, err)
+	defer journal.Close()
+	assert.NoError(t, journal.Record(&UserPlan{SourceIdentifier: "user1@source.com"}, JournalStatusOrgsSynced, nil))
+
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "user1@source.com", "user1@source.com"),
+		makeUser("src-2", "user2@source.com", "user2@source.com"),
+	}}
+	match := MatchOptions{Domains: []string{"source.com"}}
+
+	t.Run("keeps every User pair without resume", func(t *testing.T) {
+		scope, _ := resumeScope(groupScope("group-id", users), match, SyncOptions{}, journal, &logger)
+		assert.Len(t, scope.sourceUsers.Data, 2)
+	})
+
+	t.Run("skips the completed User pairs", func(t *testing.T) {
+		scope, _ := resumeScope(groupScope("group-id", users), match, SyncOptions{Resume: true}, journal, &logger)
+		assert.Len(t, scope.sourceUsers.Data, 1)
+		assert.Equal(t, "src-2", *scope.sourceUsers.Data[0].ID)
+	})
+
+	t.Run("keeps the completed User pairs to retire their pre-migrated Users", func(t *testing.T) {
+		opts := SyncOptions{Resume: true, RetireSource: RetireRemoveMemberships}
+		scope, _ := resumeScope(groupScope("group-id", users), match, opts, journal, &logger)
+		assert.Len(t, scope.sourceUsers.Data, 2)
+
+		mapping := []UserMapping{{SourceIdentifier: "user1@source.com", DestinationIdentifier: "user1@destination.com"}}
+		_, resumed := resumeScope(groupScope("group-id", users), MatchOptions{Mapping: mapping}, opts, journal, &logger)
+		assert.Equal(t, mapping, resumed.Mapping)
+	})
+}
+
+func TestApplyUserPlan_Journal(t *testing.T) {
+	logger := zerolog.Nop()
+	newUserPlan := func() *UserPlan {
+		return &UserPlan{
+			SourceIdentifier:      "user1@source.com",
+			DestinationIdentifier: "user1",
+			DestinationUserID:     "dst-1",
+			Operations: []Operation{
+				{Action: ActionUpdateGroupMembershipRole, GroupID: "group-id", MembershipID: "gm-1", RoleID: "role-1"},
+				{Action: ActionDeleteOrgMembership, OrgID: "org-1", MembershipID: "om-1"},
+			},
+		}
+	}
+	readStatuses := func(t *testing.T, filePath string) []string {
+		content, err := os.ReadFile(filePath)
+		assert.NoError(t, err)
+		var statuses []string
+		for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			for _, status := range []string{JournalStatusPending, JournalStatusGroupSynced, JournalStatusOrgsSynced, JournalStatusFailed} {
+				if strings.Contains(line, `"status":"`+status+`"`) {
+					statuses = append(statuses, status)
+				}
+			}
+		}
+		return statuses
+	}
+
+	t.Run("records the progress of a synchronized User pair", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "journal.jsonl")
+		journal, err := OpenJournal(filePath, "group-id", false)
+		assert.NoError(t, err)
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+
+		mockClient.On("Patch", "/rest/groups/group-id/memberships/gm-1", mock.Anything).Return([]byte{}, nil)
+		mockClient.On("Delete", "/rest/orgs/org-1/memberships/om-1").Return([]byte{}, nil)
+
+		ur, err := m.applyUserPlan(newUserPlan(), SyncOptions{}, journal, &logger)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusSucceeded, ur.Status)
+		journal.Close()
+		assert.Equal(t, []string{JournalStatusPending, JournalStatusGroupSynced, JournalStatusOrgsSynced}, readStatuses(t, filePath))
+	})
+
+	t.Run("records the failure of a User pair", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "journal.jsonl")
+		journal, err := OpenJournal(filePath, "group-id", false)
+		assert.NoError(t, err)
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+
+		mockClient.On("Patch", "/rest/groups/group-id/memberships/gm-1", mock.Anything).Return([]byte{}, nil)
+		mockClient.On("Delete", "/rest/orgs/org-1/memberships/om-1").Return([]byte{}, errors.New("api error"))
+
+		ur, err := m.applyUserPlan(newUserPlan(), SyncOptions{}, journal, &logger)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusFailed, ur.Status)
+		assert.Equal(t, JournalStatusFailed, journal.Status("user1@source.com"))
+		journal.Close()
+		assert.Equal(t, []string{JournalStatusPending, JournalStatusGroupSynced, JournalStatusFailed}, readStatuses(t, filePath))
+	})
+
+	t.Run("ignores an already existing membership", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		up := &UserPlan{
+			SourceIdentifier:  "user1@source.com",
+			DestinationUserID: "dst-1",
+			Operations:        []Operation{{Action: ActionCreateOrgMembership, OrgID: "org-1", RoleID: "role-1"}},
+		}
+
+		mockClient.On("Post", "/rest/orgs/org-1/memberships", mock.Anything).Return([]byte{}, &client.APIError{StatusCode: http.StatusConflict})
+		mockClient.On("Get", "/rest/orgs/org-1/memberships?limit=100&user_id=dst-1").Return(mockMembershipsResponse(
+			makeOrgMembership("om-1", "org-1", "Org One", "role-1", "Role One"),
+		), nil)
+
+		filePath := filepath.Join(t.TempDir(), "journal.jsonl")
+		journal, err := OpenJournal(filePath, "group-id", false)
+		assert.NoError(t, err)
+		defer journal.Close()
+		ur, err := m.applyUserPlan(up, SyncOptions{}, journal, &logger)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusUnchanged, ur.Status)
+		assert.True(t, journal.Completed("user1@source.com"))
+	})
+
+	t.Run("fails a User pair with unread memberships", func(t *testing.T) {
+		m := New(new(mocks.MockSnykClient))
+		up := &UserPlan{
+			SourceIdentifier:  "user1@source.com",
+			DestinationUserID: "dst-1",
+			Unread:            []string{UnreadDestinationGroupMemberships},
+		}
+
+		filePath := filepath.Join(t.TempDir(), "journal.jsonl")
+		journal, err := OpenJournal(filePath, "group-id", false)
+		assert.NoError(t, err)
+		ur, err := m.applyUserPlan(up, SyncOptions{}, journal, &logger)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusFailed, ur.Status)
+		assert.Equal(t, "failed to read destination GroupMemberships", ur.Error)
+		assert.False(t, journal.Completed("user1@source.com"))
+		journal.Close()
+		assert.Equal(t, []string{JournalStatusPending, JournalStatusFailed}, readStatuses(t, filePath))
+	})
+}
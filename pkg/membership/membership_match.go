@@ -0,0 +1,237 @@
+package membership
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+)
+
+// UserMapping pairs a pre-migrated User with its provisioned User by their identifiers, an email or a username.
+type UserMapping struct {
+	SourceIdentifier      string
+	DestinationIdentifier string
+}
+
+// MatchOptions controls how the pre-migrated Users are paired with their provisioned Users.
+type MatchOptions struct {
+	// Domains are the domains of the pre-migrated Users.
+	Domains []string
+	// SSODomain is the domain of the provisioned Users, matched by the local part of the pre-migrated User.
+	SSODomain string
+	// DomainMap is the domain of the provisioned Users of every listed domain of the pre-migrated Users, overriding SSODomain.
+	DomainMap map[string]string
+	// MatchByUserName identifies the pre-migrated Users by their username instead of their email.
+	MatchByUserName bool
+	// MatchToLocalPart matches the local part of the pre-migrated User to the username of the provisioned User.
+	MatchToLocalPart bool
+	// Transforms rewrite the local part of the pre-migrated User before looking up its provisioned User, if not nil.
+	Transforms *Transforms
+	// Mapping pairs the Users exactly as listed, bypassing the matching by Domain and SSODomain if not nil.
+	Mapping []UserMapping
+	// Overrides pair the listed pre-migrated Users as listed, resolving their conflicts, the other Users are matched by Domain and SSODomain.
+	Overrides []UserMapping
+}
+
+// usesMapping checks whether the Users are paired by an explicit mapping.
+func (o MatchOptions) usesMapping() bool {
+	return o.Mapping != nil
+}
+
+// sourceDomain returns the domain of Domains the identifier of a pre-migrated User is on.
+func (o MatchOptions) sourceDomain(identifier string) (string, bool) {
+	for _, d := range o.Domains {
+		if strings.HasSuffix(identifier, "@"+d) {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+// ssoDomainOf returns the domain of the provisioned Users of the pre-migrated Users on a domain.
+func (o MatchOptions) ssoDomainOf(domain string) string {
+	if ssoDomain, ok := o.DomainMap[domain]; ok {
+		return ssoDomain
+	}
+	return o.SSODomain
+}
+
+// IdentifierDomain returns the domain of an email identifier, empty for a username without a domain.
+func IdentifierDomain(identifier string) string {
+	if i := strings.LastIndex(identifier, "@"); i >= 0 {
+		return identifier[i+1:]
+	}
+	return ""
+}
+
+// IdentifierLocalPart returns the local part of an email identifier matched by the sync, the whole identifier without a domain.
+func IdentifierLocalPart(identifier string) string {
+	return strings.Split(identifier, "@")[0]
+}
+
+// userPair is a pre-migrated User and its provisioned User resolved from a mapping.
+type userPair struct {
+	mapping     UserMapping
+	source      sso.User
+	destination sso.User
+}
+
+// findUsersByIdentifier returns the Users whose email or username is the case-insensitive identifier.
+func findUsersByIdentifier(users sso.Users, identifier string) []sso.User {
+	var found []sso.User
+	for _, u := range users.Data {
+		if u.ID == nil || u.Attributes == nil {
+			continue
+		}
+		if (u.Attributes.Email != nil && strings.EqualFold(*u.Attributes.Email, identifier)) ||
+			(u.Attributes.UserName != nil && strings.EqualFold(*u.Attributes.UserName, identifier)) {
+			found = append(found, u)
+		}
+	}
+	return found
+}
+
+// resolveMappingUser looks up the single User of an identifier of the mapping.
+func resolveMappingUser(users sso.Users, identifier string) (sso.User, error) {
+	found := findUsersByIdentifier(users, identifier)
+	switch len(found) {
+	case 0:
+		return sso.User{}, fmt.Errorf("%s not found", identifier)
+	case 1:
+		return found[0], nil
+	}
+	return sso.User{}, fmt.Errorf("%s matches %d Users", identifier, len(found))
+}
+
+// resolveMapping looks up the pre-migrated User of every mapping in the source Users and its provisioned User in the destination Users,
+// it returns the resolved pairs and an error per invalid mapping.
+func resolveMapping(sourceUsers, destinationUsers sso.Users, mapping []UserMapping) ([]userPair, []error) {
+	var pairs []userPair
+	var errs []error
+	sources := make(map[string]bool)
+	for _, um := range mapping {
+		key := strings.ToLower(um.SourceIdentifier)
+		if sources[key] {
+			errs = append(errs, fmt.Errorf("%s is mapped more than once", um.SourceIdentifier))
+			continue
+		}
+		sources[key] = true
+
+		source, err := resolveMappingUser(sourceUsers, um.SourceIdentifier)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("source %w", err))
+			continue
+		}
+		destination, err := resolveMappingUser(destinationUsers, um.DestinationIdentifier)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("destination %w", err))
+			continue
+		}
+		if *source.ID == *destination.ID {
+			errs = append(errs, fmt.Errorf("%s is mapped to itself", um.SourceIdentifier))
+			continue
+		}
+		pairs = append(pairs, userPair{mapping: um, source: source, destination: destination})
+	}
+	return pairs, errs
+}
+
+// ValidateMapping checks both Users of every mapping exist exactly once on the SSO connection.
+// It returns an error listing every invalid mapping.
+func ValidateMapping(users sso.Users, mapping []UserMapping) error {
+	return validateUserMappings(users, users, mapping, "mapping")
+}
+
+// ValidateOverrides checks both Users of every override exist exactly once on the SSO connection.
+// It returns an error listing every invalid override.
+func ValidateOverrides(users sso.Users, overrides []UserMapping) error {
+	return validateUserMappings(users, users, overrides, "overrides")
+}
+
+// ValidateMigrationMapping checks the pre-migrated User of every mapping exists exactly once in the source Users
+// and its provisioned User exactly once in the destination Users. It returns an error listing every invalid mapping.
+func ValidateMigrationMapping(sourceUsers, destinationUsers sso.Users, mapping []UserMapping) error {
+	return validateUserMappings(sourceUsers, destinationUsers, mapping, "mapping")
+}
+
+// ValidateMigrationOverrides checks the pre-migrated User of every override exists exactly once in the source Users
+// and its provisioned User exactly once in the destination Users. It returns an error listing every invalid override.
+func ValidateMigrationOverrides(sourceUsers, destinationUsers sso.Users, overrides []UserMapping) error {
+	return validateUserMappings(sourceUsers, destinationUsers, overrides, "overrides")
+}
+
+// validateUserMappings checks both Users of every mapping exist exactly once, naming the mapping in the error listing every invalid one.
+func validateUserMappings(sourceUsers, destinationUsers sso.Users, mapping []UserMapping, name string) error {
+	_, errs := resolveMapping(sourceUsers, destinationUsers, mapping)
+	if len(errs) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Errorf("%s has %d invalid Users: %s", name, len(errs), strings.Join(messages, "; "))
+}
+
+// pairKeyIdentifier returns the identifier of the pre-migrated User of a pair, falling back to its mapping identifier.
+func pairKeyIdentifier(pair userPair, matchByUserName bool) string {
+	if prevKeyID := userKeyIdentifier(pair.source, matchByUserName); prevKeyID != "" {
+		return prevKeyID
+	}
+	return pair.mapping.SourceIdentifier
+}
+
+// skipCompletedMapping removes the mappings of the pre-migrated Users whose memberships were completely synchronized according to the journal.
+func skipCompletedMapping(sourceUsers, destinationUsers sso.Users, mapping []UserMapping, journal *Journal, matchByUserName bool) []UserMapping {
+	remaining := []UserMapping{}
+	pairs, _ := resolveMapping(sourceUsers, destinationUsers, mapping)
+	completed := make(map[string]bool)
+	for _, pair := range pairs {
+		if journal.Completed(pairKeyIdentifier(pair, matchByUserName)) {
+			completed[pair.mapping.SourceIdentifier] = true
+		}
+	}
+	for _, um := range mapping {
+		if !completed[um.SourceIdentifier] {
+			remaining = append(remaining, um)
+		}
+	}
+	return remaining
+}
+
+// mapMappedUsersAttributes builds the map of the pre-migrated Users of the mapping to their provisioned User containing its Memberships.
+// The memberships of up to concurrency Users are fetched in parallel.
+// Several pre-migrated Users mapped to the same provisioned User are not mapped, they are returned as conflicts.
+func (m *Client) mapMappedUsersAttributes(scope syncScope, match MatchOptions, concurrency int, logger *zerolog.Logger) (int32, *map[string]provisionedUserAttributes, []Conflict) {
+	provisionedUserAttributesMap := make(map[string]provisionedUserAttributes)
+
+	pairs, errs := resolveMapping(scope.sourceUsers, scope.destinationUsers, match.Mapping)
+	for _, err := range errs {
+		logger.Warn().Msg(fmt.Sprintf("Skipping invalid mapping: %s", err.Error()))
+	}
+	pairs, conflicts := refuseManyToOne(pairs, nil, match.MatchToLocalPart)
+	for _, c := range conflicts {
+		logger.Warn().Msg(fmt.Sprintf("Skipping Users of %s", c.String()))
+	}
+
+	prevKeyIDs := make([]string, len(pairs))
+	attributes := make([]provisionedUserAttributes, len(pairs))
+	forEachOrdered(len(pairs), concurrency, logger, func(i int, logger *zerolog.Logger) {
+		pair := pairs[i]
+		_, uAttributes := m.mapSourceUserAttributes(scope.sourceGroupID, pair.source, match.MatchByUserName, logger)
+		prevKeyIDs[i] = pairKeyIdentifier(pair, match.MatchByUserName)
+		logger.Info().Msg(fmt.Sprintf("Mapped %s -> User: %s", prevKeyIDs[i], pair.mapping.DestinationIdentifier))
+
+		var provisionedEmail string
+		if pair.destination.Attributes.Email != nil {
+			provisionedEmail = *pair.destination.Attributes.Email
+		}
+		attributes[i] = m.fetchProvisionedUserAttributes(scope.destinationGroupID, uAttributes, pair.destination, provisionedEmail, logger)
+	})
+
+	for i, prevKeyID := range prevKeyIDs {
+		provisionedUserAttributesMap[prevKeyID] = attributes[i]
+	}
+	return int32(len(pairs)), &provisionedUserAttributesMap, conflicts
+}
@@ -0,0 +1,121 @@
+package membership
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateMapping(t *testing.T) {
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "jdoe@old.com", "jdoe@old.com"),
+		makeUser("dst-1", "jane.smith@new.com", "jane.smith"),
+		makeUser("dup-1", "dup@new.com", "dup"),
+		makeUser("dup-2", "other@new.com", "dup@new.com"),
+	}}
+
+	t.Run("valid mapping", func(t *testing.T) {
+		assert.NoError(t, ValidateMapping(users, []UserMapping{
+			{SourceIdentifier: "JDoe@old.com", DestinationIdentifier: "jane.smith"},
+		}))
+	})
+
+	t.Run("invalid mapping", func(t *testing.T) {
+		err := ValidateMapping(users, []UserMapping{
+			{SourceIdentifier: "jdoe@old.com", DestinationIdentifier: "jane.smith@new.com"},
+			{SourceIdentifier: "jdoe@old.com", DestinationIdentifier: "jane.smith@new.com"},
+			{SourceIdentifier: "missing@old.com", DestinationIdentifier: "jane.smith@new.com"},
+			{SourceIdentifier: "jane.smith", DestinationIdentifier: "dup@new.com"},
+			{SourceIdentifier: "dup", DestinationIdentifier: "dup"},
+		})
+		assert.EqualError(t, err, "mapping has 4 invalid Users: jdoe@old.com is mapped more than once; "+
+			"source missing@old.com not found; destination dup@new.com matches 2 Users; dup is mapped to itself")
+	})
+}
+
+func TestPlanMemberships_Mapping(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "group-id"
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "jdoe@old.com", "jdoe@old.com"),
+		makeUser("dst-1", "jane.smith@new.com", "jane.smith"),
+		// same local part as the source User, never matched when mapping
+		makeUser("dst-2", "jdoe@new.com", "jdoe"),
+	}}
+	for _, userID := range []string{"src-1", "dst-1"} {
+		orgMemberships := mockMembershipsResponse()
+		if userID == "src-1" {
+			orgMemberships = mockMembershipsResponse(makeOrgMembership("om-1", "org-1", "Org One", "role-admin", "Org Admin"))
+		}
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=%s", groupID, userID)).
+			Return(mockMembershipsResponse(makeGroupMembership("gm-"+userID, groupID, "Group", "role-member", "Group Member")), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=%s", groupID, userID)).
+			Return(orgMemberships, nil)
+	}
+
+	match := MatchOptions{Mapping: []UserMapping{{SourceIdentifier: "jdoe@old.com", DestinationIdentifier: "jane.smith@new.com"}}}
+	plan := m.PlanMemberships(groupID, users, match, SyncOptions{}, &logger)
+
+	assert.Len(t, plan.Users, 1)
+	assert.Equal(t, "jdoe@old.com", plan.Users[0].SourceIdentifier)
+	assert.Equal(t, "jane.smith", plan.Users[0].DestinationIdentifier)
+	assert.Equal(t, "dst-1", plan.Users[0].DestinationUserID)
+	assert.Len(t, plan.Users[0].Operations, 1)
+	assert.Equal(t, ActionCreateOrgMembership, plan.Users[0].Operations[0].Action)
+	mockClient.AssertExpectations(t)
+}
+
+func TestPlanMemberships_MappingConflicts(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "group-id"
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "jdoe@old.com", "jdoe@old.com"),
+		makeUser("src-2", "john.doe@old.com", "john.doe@old.com"),
+		makeUser("dst-1", "jane.smith@new.com", "jane.smith"),
+	}}
+
+	match := MatchOptions{Mapping: []UserMapping{
+		{SourceIdentifier: "jdoe@old.com", DestinationIdentifier: "jane.smith@new.com"},
+		{SourceIdentifier: "john.doe@old.com", DestinationIdentifier: "jane.smith"},
+	}}
+	plan := m.PlanMemberships(groupID, users, match, SyncOptions{}, &logger)
+
+	assert.Empty(t, plan.Users)
+	assert.Equal(t, []Conflict{{
+		Kind:                   ConflictManyToOne,
+		SourceIdentifiers:      []string{"jdoe@old.com", "john.doe@old.com"},
+		DestinationIdentifiers: []string{"jane.smith@new.com"},
+		DestinationUserIDs:     []string{"dst-1"},
+	}}, plan.Conflicts)
+	// the memberships of the conflicting Users are never fetched
+	mockClient.AssertNotCalled(t, "Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=dst-1", groupID))
+}
+
+func TestSkipCompletedMapping(t *testing.T) {
+	journal, err := OpenJournal(filepath.Join(t.TempDir(), "journal.jsonl"), "group-id", false)
+	assert.NoError(t, err)
+	defer journal.Close()
+	assert.NoError(t, journal.Record(&UserPlan{SourceIdentifier: "jdoe@old.com"}, JournalStatusOrgsSynced, nil))
+
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "jdoe@old.com", "jdoe@old.com"),
+		makeUser("dst-1", "jane.smith@new.com", "jane.smith"),
+	}}
+	mapping := []UserMapping{{SourceIdentifier: "jdoe@old.com", DestinationIdentifier: "jane.smith"}}
+
+	remaining := skipCompletedMapping(users, users, mapping, journal, false)
+	assert.NotNil(t, remaining)
+	assert.Empty(t, remaining)
+	assert.True(t, MatchOptions{Mapping: remaining}.usesMapping())
+}
@@ -0,0 +1,192 @@
+package membership
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+)
+
+// Org is an Org of a Group, referred to by its ID, slug or name in an org mapping.
+type Org struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// OrgMapping pairs an Org of the source Group with an Org of the destination Group, each by its ID, slug or name.
+type OrgMapping struct {
+	SourceOrg      string
+	DestinationOrg string
+}
+
+// GroupMigration migrates the memberships of the pre-migrated Users of a source Group to their provisioned Users of a destination Group.
+type GroupMigration struct {
+	SourceGroupID      string
+	DestinationGroupID string
+	// Orgs maps the ID of every migrated Org of the source Group to its Org of the destination Group,
+	// the memberships of the other Orgs are not migrated
+	Orgs map[string]Org
+}
+
+// groupOrgsResponse is a page of the Orgs of a Group.
+type groupOrgsResponse struct {
+	Data []struct {
+		ID         *string `json:"id"`
+		Attributes *struct {
+			Name *string `json:"name"`
+			Slug *string `json:"slug"`
+		} `json:"attributes"`
+	} `json:"data"`
+	Links *struct {
+		Next *string `json:"next"`
+	} `json:"links"`
+}
+
+// GetGroupOrgs fetches every Org of a Group.
+func (m *Client) GetGroupOrgs(groupID string) ([]Org, error) {
+	orgs := []Org{}
+	requestPath := fmt.Sprintf("/rest/groups/%s/orgs?limit=100", groupID)
+	for requestPath != "" {
+		respBody, err := m.client.Get(requestPath)
+		if err != nil {
+			return nil, err
+		}
+		var resp groupOrgsResponse
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			return nil, err
+		}
+		for _, o := range resp.Data {
+			if o.ID == nil {
+				continue
+			}
+			org := Org{ID: *o.ID}
+			if o.Attributes != nil {
+				org.Name = attribute(o.Attributes.Name)
+				org.Slug = attribute(o.Attributes.Slug)
+			}
+			orgs = append(orgs, org)
+		}
+
+		requestPath = ""
+		if resp.Links != nil && resp.Links.Next != nil && *resp.Links.Next != "" {
+			requestPath = "/rest" + *resp.Links.Next
+		}
+	}
+	return orgs, nil
+}
+
+// findOrg looks up the single Org whose ID or slug is the reference, or whose name is the case-insensitive reference.
+func findOrg(orgs []Org, ref string) (Org, error) {
+	var found []Org
+	for _, o := range orgs {
+		if o.ID == ref || o.Slug == ref || strings.EqualFold(o.Name, ref) {
+			found = append(found, o)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Org{}, fmt.Errorf("%s not found", ref)
+	case 1:
+		return found[0], nil
+	}
+	return Org{}, fmt.Errorf("%s matches %d Orgs", ref, len(found))
+}
+
+// ResolveOrgMapping looks up the source Org of every mapping in the Orgs of the source Group and its destination Org
+// in the Orgs of the destination Group. It returns the destination Org of every source Org ID,
+// or an error listing every invalid mapping.
+func ResolveOrgMapping(sourceOrgs, destinationOrgs []Org, mapping []OrgMapping) (map[string]Org, error) {
+	orgs := make(map[string]Org)
+	var messages []string
+	for _, om := range mapping {
+		source, err := findOrg(sourceOrgs, om.SourceOrg)
+		if err != nil {
+			messages = append(messages, "source "+err.Error())
+			continue
+		}
+		destination, err := findOrg(destinationOrgs, om.DestinationOrg)
+		if err != nil {
+			messages = append(messages, "destination "+err.Error())
+			continue
+		}
+		if _, ok := orgs[source.ID]; ok {
+			messages = append(messages, fmt.Sprintf("%s is mapped more than once", om.SourceOrg))
+			continue
+		}
+		orgs[source.ID] = destination
+	}
+	if len(messages) > 0 {
+		return nil, fmt.Errorf("org mapping has %d invalid Orgs: %s", len(messages), strings.Join(messages, "; "))
+	}
+	return orgs, nil
+}
+
+// translate rewrites the memberships of a pre-migrated User of the source Group into memberships of the destination Group.
+// Its Group membership is moved to the destination Group and its Org memberships to the mapped Orgs,
+// the memberships of the Orgs without a mapping are skipped and listed in its skippedMemberships.
+func (g *GroupMigration) translate(prevKeyID string, uAttributes provisionedUserAttributes, logger *zerolog.Logger) provisionedUserAttributes {
+	if uAttributes.groupMemberships != nil {
+		groupMemberships := &UserGroupMemberships{}
+		for _, gm := range uAttributes.groupMemberships.Data {
+			translated := withRelationship(gm)
+			translated.Relationship.Group = relationshipData(g.DestinationGroupID, TypeGroup, "")
+			groupMemberships.Data = append(groupMemberships.Data, translated)
+		}
+		uAttributes.groupMemberships = groupMemberships
+	}
+
+	if uAttributes.orgMemberships != nil {
+		orgMemberships := &UserOrgMemberships{}
+		for _, om := range uAttributes.orgMemberships.Data {
+			state := toMembershipState(om)
+			org, ok := g.Orgs[state.OrgID]
+			if !ok {
+				logger.Warn().Msg(fmt.Sprintf("Skipping OrgMembership of %s, Org: %s is not mapped", prevKeyID, state.OrgName))
+				uAttributes.skippedMemberships = append(uAttributes.skippedMemberships, SkippedMembership{MembershipState: state, Reason: "org is not mapped"})
+				continue
+			}
+			translated := withRelationship(om)
+			translated.Relationship.Org = relationshipData(org.ID, TypeOrg, org.Name)
+			orgMemberships.Data = append(orgMemberships.Data, translated)
+		}
+		uAttributes.orgMemberships = orgMemberships
+	}
+	return uAttributes
+}
+
+// withRelationship returns a copy of a membership whose relationships can be modified without modifying the membership.
+func withRelationship(mbr Membership) Membership {
+	relationship := MemberRelationship{}
+	if mbr.Relationship != nil {
+		relationship = *mbr.Relationship
+	}
+	mbr.Relationship = &relationship
+	return mbr
+}
+
+// scope returns the scope of the migration of the source Users to the destination Users.
+func (g *GroupMigration) scope(sourceUsers, destinationUsers sso.Users) syncScope {
+	return syncScope{
+		sourceGroupID:      g.SourceGroupID,
+		destinationGroupID: g.DestinationGroupID,
+		sourceUsers:        sourceUsers,
+		destinationUsers:   destinationUsers,
+		migration:          g,
+	}
+}
+
+// PlanMigration computes the membership changes required to reproduce the memberships of the pre-migrated Users
+// of the source Group on their provisioned Users of the destination Group, without issuing any mutating request.
+func (m *Client) PlanMigration(migration GroupMigration, sourceUsers, destinationUsers sso.Users, match MatchOptions, opts SyncOptions, logger *zerolog.Logger) *Plan {
+	return m.planScope(migration.scope(sourceUsers, destinationUsers), match, opts, logger)
+}
+
+// MigrateMemberships reproduces the Group membership and the memberships of the mapped Orgs of the pre-migrated Users of the source Group
+// on their provisioned Users of the destination Group, recording the progress in the journal of the destination Group.
+// It returns the outcome of every User pair, the error is only returned if the migration could not run to completion.
+func (m *Client) MigrateMemberships(migration GroupMigration, sourceUsers, destinationUsers sso.Users, match MatchOptions, opts SyncOptions, logger *zerolog.Logger) (*Report, error) {
+	return m.synchronize(migration.scope(sourceUsers, destinationUsers), match, opts, logger)
+}
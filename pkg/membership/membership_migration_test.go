@@ -0,0 +1,147 @@
+package membership
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetGroupOrgs(t *testing.T) {
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	mockClient.On("Get", "/rest/groups/group-id/orgs?limit=100").Return([]byte(`{
+		"data": [{"id": "org-1", "type": "org", "attributes": {"name": "Org One", "slug": "org-one"}}],
+		"links": {"next": "/groups/group-id/orgs?limit=100&starting_after=org-1"}
+	}`), nil)
+	mockClient.On("Get", "/rest/groups/group-id/orgs?limit=100&starting_after=org-1").Return([]byte(`{
+		"data": [{"id": "org-2", "type": "org", "attributes": {"name": "Org Two", "slug": "org-two"}}],
+		"links": {}
+	}`), nil)
+
+	orgs, err := m.GetGroupOrgs("group-id")
+	assert.NoError(t, err)
+	assert.Equal(t, []Org{
+		{ID: "org-1", Name: "Org One", Slug: "org-one"},
+		{ID: "org-2", Name: "Org Two", Slug: "org-two"},
+	}, orgs)
+	mockClient.AssertExpectations(t)
+}
+
+func TestResolveOrgMapping(t *testing.T) {
+	sourceOrgs := []Org{
+		{ID: "src-org-1", Name: "Payments", Slug: "payments"},
+		{ID: "src-org-2", Name: "Billing", Slug: "billing"},
+		{ID: "src-org-3", Name: "Shared", Slug: "shared-a"},
+		{ID: "src-org-4", Name: "Shared", Slug: "shared-b"},
+	}
+	destinationOrgs := []Org{
+		{ID: "dst-org-1", Name: "Payments", Slug: "payments-new"},
+		{ID: "dst-org-2", Name: "Billing", Slug: "billing-new"},
+	}
+
+	t.Run("by ID, slug or name", func(t *testing.T) {
+		orgs, err := ResolveOrgMapping(sourceOrgs, destinationOrgs, []OrgMapping{
+			{SourceOrg: "src-org-1", DestinationOrg: "payments-new"},
+			{SourceOrg: "billing", DestinationOrg: "BILLING"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]Org{
+			"src-org-1": destinationOrgs[0],
+			"src-org-2": destinationOrgs[1],
+		}, orgs)
+	})
+
+	t.Run("invalid mappings", func(t *testing.T) {
+		_, err := ResolveOrgMapping(sourceOrgs, destinationOrgs, []OrgMapping{
+			{SourceOrg: "Shared", DestinationOrg: "dst-org-1"},
+			{SourceOrg: "payments", DestinationOrg: "missing"},
+			{SourceOrg: "src-org-2", DestinationOrg: "dst-org-2"},
+			{SourceOrg: "Billing", DestinationOrg: "dst-org-1"},
+		})
+		assert.EqualError(t, err, "org mapping has 3 invalid Orgs: source Shared matches 2 Orgs; destination missing not found; Billing is mapped more than once")
+	})
+}
+
+func TestPlanMigration(t *testing.T) {
+	logger := zerolog.Nop()
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	// the pre-migrated and the provisioned User share the same email on different Groups
+	sourceUsers := sso.Users{Data: []sso.User{makeUser("src-1", "alice@old.com", "alice@old.com")}}
+	destinationUsers := sso.Users{Data: []sso.User{makeUser("dst-1", "alice@new.com", "alice")}}
+
+	mockClient.On("Get", "/rest/groups/src-group/memberships?limit=100&user_id=src-1").
+		Return(mockMembershipsResponse(makeGroupMembership("gm-src-1", "src-group", "Source Group", "role-member", "Group Member")), nil)
+	mockClient.On("Get", "/rest/groups/src-group/org_memberships?limit=100&user_id=src-1").
+		Return(mockMembershipsResponse(
+			makeOrgMembership("om-src-1", "src-org-1", "Payments", "role-admin", "Org Admin"),
+			makeOrgMembership("om-src-2", "src-org-2", "Legacy", "role-collaborator", "Org Collaborator"),
+		), nil)
+	mockClient.On("Get", "/rest/groups/dst-group/memberships?limit=100&user_id=dst-1").
+		Return(mockMembershipsResponse(), nil)
+	mockClient.On("Get", "/rest/groups/dst-group/org_memberships?limit=100&user_id=dst-1").
+		Return(mockMembershipsResponse(), nil)
+
+	migration := GroupMigration{
+		SourceGroupID:      "src-group",
+		DestinationGroupID: "dst-group",
+		Orgs:               map[string]Org{"src-org-1": {ID: "dst-org-1", Name: "Payments EU", Slug: "payments-eu"}},
+	}
+	match := MatchOptions{Domains: []string{"old.com"}, SSODomain: "new.com"}
+	plan := m.PlanMigration(migration, sourceUsers, destinationUsers, match, SyncOptions{}, &logger)
+
+	assert.Equal(t, "dst-group", plan.GroupID)
+	assert.Equal(t, "src-group", plan.SourceGroupID)
+	assert.Len(t, plan.Users, 1)
+	up := plan.Users[0]
+	assert.Equal(t, "alice@old.com", up.SourceIdentifier)
+	assert.Equal(t, "dst-1", up.DestinationUserID)
+	// the Org membership of the unmapped Legacy Org is not migrated
+	assert.Len(t, up.Operations, 2)
+	assert.Equal(t, ActionCreateGroupMembership, up.Operations[0].Action)
+	assert.Equal(t, "/rest/groups/dst-group/memberships", up.Operations[0].Path)
+	assert.Equal(t, "role-member", up.Operations[0].RoleID)
+	assert.Equal(t, "create GroupMembership, Group: dst-group, Role: Group Member", up.Operations[0].Description())
+	assert.Equal(t, ActionCreateOrgMembership, up.Operations[1].Action)
+	assert.Equal(t, "/rest/orgs/dst-org-1/memberships", up.Operations[1].Path)
+	assert.Equal(t, "Payments EU", up.Operations[1].OrgName)
+	assert.Equal(t, "role-admin", up.Operations[1].RoleID)
+	assert.Equal(t, []SkippedMembership{{
+		MembershipState: MembershipState{ID: "om-src-2", Type: OrgMembershipType, OrgID: "src-org-2", OrgName: "Legacy", RoleID: "role-collaborator", RoleName: "Org Collaborator"},
+		Reason:          "org is not mapped",
+	}}, up.SkippedMemberships)
+	mockClient.AssertExpectations(t)
+}
+
+func TestPlanMigration_Mapping(t *testing.T) {
+	logger := zerolog.Nop()
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	// the same email is a source User in the source Group and a destination User in the destination Group
+	sourceUsers := sso.Users{Data: []sso.User{makeUser("src-1", "bob@corp.com", "bob@corp.com")}}
+	destinationUsers := sso.Users{Data: []sso.User{makeUser("dst-1", "bob@corp.com", "bob@corp.com")}}
+	for _, ids := range [][2]string{{"src-group", "src-1"}, {"dst-group", "dst-1"}} {
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=%s", ids[0], ids[1])).
+			Return(mockMembershipsResponse(makeGroupMembership("gm-"+ids[1], ids[0], "Group", "role-member", "Group Member")), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=%s", ids[0], ids[1])).
+			Return(mockMembershipsResponse(), nil)
+	}
+
+	mapping := []UserMapping{{SourceIdentifier: "bob@corp.com", DestinationIdentifier: "bob@corp.com"}}
+	assert.NoError(t, ValidateMigrationMapping(sourceUsers, destinationUsers, mapping))
+	assert.EqualError(t, ValidateMapping(sourceUsers, mapping), "mapping has 1 invalid Users: bob@corp.com is mapped to itself")
+
+	migration := GroupMigration{SourceGroupID: "src-group", DestinationGroupID: "dst-group"}
+	plan := m.PlanMigration(migration, sourceUsers, destinationUsers, MatchOptions{Mapping: mapping}, SyncOptions{}, &logger)
+	assert.Len(t, plan.Users, 1)
+	assert.Equal(t, "dst-1", plan.Users[0].DestinationUserID)
+	// the Group membership already has the role of the pre-migrated User
+	assert.Empty(t, plan.Users[0].Operations)
+}
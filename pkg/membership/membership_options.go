@@ -0,0 +1,83 @@
+package membership
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// ModeMirror makes the provisioned User memberships an exact mirror of the pre-migrated User memberships.
+	ModeMirror = "mirror"
+	// ModeMerge only adds missing memberships and upgrades roles, it never downgrades nor deletes a membership.
+	ModeMerge = "merge"
+)
+
+// DefaultRolePrecedence orders the Snyk predefined roles from the highest to the lowest privilege.
+var DefaultRolePrecedence = []string{"Group Admin", "Group Viewer", "Group Member", "Org Admin", "Org Collaborator"}
+
+// SyncOptions controls how the memberships of a provisioned User are reconciled.
+type SyncOptions struct {
+	Mode string
+	// RolePrecedence lists role names or role IDs from the highest to the lowest privilege.
+	// It is used in ModeMerge to decide whether a role change is an upgrade.
+	RolePrecedence []string
+	// SnapshotPath is the file the memberships of the affected provisioned Users are persisted to before any modification.
+	SnapshotPath string
+	// JournalPath is the file the synchronization progress of every User pair is recorded to.
+	JournalPath string
+	// Concurrency is the number of User pairs processed in parallel, sharing the rate limit of the client.
+	// Values below 2 process the User pairs sequentially.
+	Concurrency int
+	// Resume skips the User pairs recorded as completed in the journal of JournalPath instead of starting a new journal.
+	Resume bool
+	// Roles translates the roles of the memberships recreated for the provisioned Users, nil keeps the roles unchanged.
+	Roles *RoleMapping
+	// Orgs restricts the Org memberships recreated and deleted to the allowed Orgs, nil synchronizes every Org.
+	Orgs *OrgFilter
+	// RetireSource retires the pre-migrated User of every fully synchronized User pair by RetireRemoveMemberships
+	// or RetireDeleteUser, empty retires none. Their memberships are snapshotted to the SourceSnapshotPath of SnapshotPath first.
+	RetireSource string
+	// SourceConnection selects the SSO connection of the pre-migrated Users deleted by RetireDeleteUser by its ID or name,
+	// empty for the only SSO connection of the source Group.
+	SourceConnection string
+}
+
+// ValidateMode checks the synchronization mode is supported, an empty mode defaults to ModeMirror.
+func ValidateMode(mode string) error {
+	switch mode {
+	case "", ModeMirror, ModeMerge:
+		return nil
+	}
+	return fmt.Errorf("mode must be one of %s or %s: %s", ModeMirror, ModeMerge, mode)
+}
+
+func (o SyncOptions) isMerge() bool {
+	return o.Mode == ModeMerge
+}
+
+// roleRank returns the precedence rank of a role by its ID or its case-insensitive name.
+// The lower the rank, the higher the privilege. It returns -1 for a role absent of the precedence.
+func (o SyncOptions) roleRank(roleID, roleName string) int {
+	for i, role := range o.RolePrecedence {
+		role = strings.TrimSpace(role)
+		if role == roleID || (roleName != "" && strings.EqualFold(role, roleName)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// allowsRoleChange checks whether a role can be changed from the current role to the target role.
+// In ModeMirror any role change is allowed, in ModeMerge only upgrades between roles ranked by RolePrecedence are allowed.
+func (o SyncOptions) allowsRoleChange(currentRoleID, currentRoleName, targetRoleID, targetRoleName string) bool {
+	if !o.isMerge() {
+		return true
+	}
+	currentRank := o.roleRank(currentRoleID, currentRoleName)
+	targetRank := o.roleRank(targetRoleID, targetRoleName)
+	if currentRank < 0 || targetRank < 0 {
+		// roles of unknown precedence are never changed
+		return false
+	}
+	return targetRank < currentRank
+}
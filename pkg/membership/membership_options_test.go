@@ -0,0 +1,88 @@
+package membership
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateMode(t *testing.T) {
+	assert.NoError(t, ValidateMode(""))
+	assert.NoError(t, ValidateMode(ModeMirror))
+	assert.NoError(t, ValidateMode(ModeMerge))
+	assert.EqualError(t, ValidateMode("replace"), "mode must be one of mirror or merge: replace")
+}
+
+func TestAllowsRoleChange(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           SyncOptions
+		currentRoleID  string
+		currentRole    string
+		targetRoleID   string
+		targetRole     string
+		expectedResult bool
+	}{
+		{
+			name:           "mirror allows downgrade",
+			opts:           SyncOptions{Mode: ModeMirror, RolePrecedence: DefaultRolePrecedence},
+			currentRoleID:  "admin-id",
+			currentRole:    "Org Admin",
+			targetRoleID:   "collaborator-id",
+			targetRole:     "Org Collaborator",
+			expectedResult: true,
+		},
+		{
+			name:           "merge allows upgrade",
+			opts:           SyncOptions{Mode: ModeMerge, RolePrecedence: DefaultRolePrecedence},
+			currentRoleID:  "collaborator-id",
+			currentRole:    "Org Collaborator",
+			targetRoleID:   "admin-id",
+			targetRole:     "Org Admin",
+			expectedResult: true,
+		},
+		{
+			name:           "merge refuses downgrade",
+			opts:           SyncOptions{Mode: ModeMerge, RolePrecedence: DefaultRolePrecedence},
+			currentRoleID:  "admin-id",
+			currentRole:    "Org Admin",
+			targetRoleID:   "collaborator-id",
+			targetRole:     "Org Collaborator",
+			expectedResult: false,
+		},
+		{
+			name:           "merge matches role names case-insensitively",
+			opts:           SyncOptions{Mode: ModeMerge, RolePrecedence: []string{"org admin", "org collaborator"}},
+			currentRoleID:  "collaborator-id",
+			currentRole:    "Org Collaborator",
+			targetRoleID:   "admin-id",
+			targetRole:     "Org Admin",
+			expectedResult: true,
+		},
+		{
+			name:           "merge matches role IDs",
+			opts:           SyncOptions{Mode: ModeMerge, RolePrecedence: []string{"custom-lead-id", "Org Collaborator"}},
+			currentRoleID:  "collaborator-id",
+			currentRole:    "Org Collaborator",
+			targetRoleID:   "custom-lead-id",
+			targetRole:     "Custom Lead",
+			expectedResult: true,
+		},
+		{
+			name:           "merge refuses role of unknown precedence",
+			opts:           SyncOptions{Mode: ModeMerge, RolePrecedence: DefaultRolePrecedence},
+			currentRoleID:  "collaborator-id",
+			currentRole:    "Org Collaborator",
+			targetRoleID:   "custom-id",
+			targetRole:     "Custom Role",
+			expectedResult: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.opts.allowsRoleChange(tt.currentRoleID, tt.currentRole, tt.targetRoleID, tt.targetRole)
+			assert.Equal(t, tt.expectedResult, result)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package membership
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// OrgFilter restricts the Org memberships a synchronization recreates and deletes to the Orgs matching its patterns.
+// A pattern is a case-insensitive glob matched against the ID, the slug and the name of an Org.
+type OrgFilter struct {
+	include []string
+	exclude []string
+	// orgs looks up the slug and name of an Org by its ID
+	orgs map[string]Org
+}
+
+// NewOrgFilter validates the include and exclude patterns. An Org is allowed if it matches an include pattern,
+// or if there are none, and matches no exclude pattern.
+// The Orgs are used to look up the slug of the Org of a membership, which only holds the Org ID and name.
+func NewOrgFilter(include, exclude []string, orgs []Org) (*OrgFilter, error) {
+	f := &OrgFilter{orgs: make(map[string]Org, len(orgs))}
+	var err error
+	if f.include, err = orgPatterns(include, "includeOrgs"); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = orgPatterns(exclude, "excludeOrgs"); err != nil {
+		return nil, err
+	}
+	for _, o := range orgs {
+		f.orgs[o.ID] = o
+	}
+	return f, nil
+}
+
+// orgPatterns trims and lower-cases the patterns, checking they are valid globs.
+func orgPatterns(patterns []string, name string) ([]string, error) {
+	var valid []string
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("%s has an invalid pattern: %s", name, p)
+		}
+		valid = append(valid, p)
+	}
+	return valid, nil
+}
+
+// ReadOrgPatterns reads the Org patterns of a file, one per line. Blank lines and lines starting with # are ignored.
+func ReadOrgPatterns(r io.Reader) ([]string, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// matchesAny checks whether any pattern matches the ID, slug or name of the Org.
+func matchesAny(patterns []string, org Org) bool {
+	for _, p := range patterns {
+		for _, value := range []string{org.ID, org.Slug, org.Name} {
+			if value == "" {
+				continue
+			}
+			if ok, _ := path.Match(p, strings.ToLower(value)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allows checks whether the Org of a membership state may be synchronized.
+// Group memberships are always allowed, as is every membership with a nil OrgFilter.
+func (f *OrgFilter) allows(state MembershipState) bool {
+	if f == nil || state.Type != OrgMembershipType {
+		return true
+	}
+	org, ok := f.orgs[state.OrgID]
+	if !ok {
+		org = Org{ID: state.OrgID, Name: state.OrgName}
+	}
+	if len(f.include) > 0 && !matchesAny(f.include, org) {
+		return false
+	}
+	return !matchesAny(f.exclude, org)
+}
+
+// filterOrgs removes the memberships of the Orgs the OrgFilter does not allow.
+// The desired memberships removed are returned as skipped, the current memberships removed are left untouched.
+func filterOrgs(desired, current []MembershipState, f *OrgFilter) ([]MembershipState, []MembershipState, []SkippedMembership) {
+	if f == nil {
+		return desired, current, nil
+	}
+	var skipped []SkippedMembership
+	allowedDesired := make([]MembershipState, 0, len(desired))
+	for _, d := range desired {
+		if !f.allows(d) {
+			skipped = append(skipped, SkippedMembership{MembershipState: d, Reason: "org is excluded"})
+			continue
+		}
+		allowedDesired = append(allowedDesired, d)
+	}
+	allowedCurrent := make([]MembershipState, 0, len(current))
+	for _, c := range current {
+		if f.allows(c) {
+			allowedCurrent = append(allowedCurrent, c)
+		}
+	}
+	return allowedDesired, allowedCurrent, skipped
+}
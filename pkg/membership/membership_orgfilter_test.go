@@ -0,0 +1,81 @@
+package membership
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrgFilter(t *testing.T) {
+	orgs := []Org{
+		{ID: "org-1", Name: "Payments EU", Slug: "payments-eu"},
+		{ID: "org-2", Name: "Payments Sandbox", Slug: "payments-sandbox"},
+		{ID: "org-3", Name: "Legacy", Slug: "legacy"},
+	}
+	orgState := func(orgID, orgName string) MembershipState {
+		return toMembershipState(makeOrgMembership("om-"+orgID, orgID, orgName, "role-admin", "Org Admin"))
+	}
+
+	t.Run("include and exclude by slug, name or ID", func(t *testing.T) {
+		f, err := NewOrgFilter([]string{"payments-*", "org-3"}, []string{"*SANDBOX*"}, orgs)
+		assert.NoError(t, err)
+		assert.True(t, f.allows(orgState("org-1", "Payments EU")))
+		assert.False(t, f.allows(orgState("org-2", "Payments Sandbox")))
+		assert.True(t, f.allows(orgState("org-3", "Legacy")))
+		// an Org unknown to the filter is matched by its ID and name
+		assert.False(t, f.allows(orgState("org-4", "Billing")))
+		assert.True(t, f.allows(toMembershipState(makeGroupMembership("gm-1", "group-id", "Group", "role-member", "Group Member"))))
+	})
+
+	t.Run("exclude only", func(t *testing.T) {
+		f, err := NewOrgFilter(nil, []string{"legacy"}, orgs)
+		assert.NoError(t, err)
+		assert.True(t, f.allows(orgState("org-1", "Payments EU")))
+		assert.False(t, f.allows(orgState("org-3", "Legacy")))
+	})
+
+	t.Run("nil filter allows every Org", func(t *testing.T) {
+		var f *OrgFilter
+		assert.True(t, f.allows(orgState("org-3", "Legacy")))
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := NewOrgFilter(nil, []string{"[legacy"}, orgs)
+		assert.EqualError(t, err, "excludeOrgs has an invalid pattern: [legacy")
+	})
+}
+
+func TestReadOrgPatterns(t *testing.T) {
+	patterns, err := ReadOrgPatterns(strings.NewReader("# sandboxes\n*-sandbox\n\n  legacy  \n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"*-sandbox", "legacy"}, patterns)
+}
+
+func TestPlanOrgMemberships_OrgFilter(t *testing.T) {
+	uAttributes := provisionedUserAttributes{
+		orgMemberships: &UserOrgMemberships{Data: []Membership{
+			makeOrgMembership("om-src-1", "org-1", "Payments EU", "role-admin", "Org Admin"),
+			makeOrgMembership("om-src-2", "org-2", "Payments Sandbox", "role-admin", "Org Admin"),
+		}},
+		provisionedOrgMemberships: &UserOrgMemberships{Data: []Membership{
+			makeOrgMembership("om-dst-3", "org-3", "Legacy", "role-collaborator", "Org Collaborator"),
+			makeOrgMembership("om-dst-4", "org-4", "Billing", "role-collaborator", "Org Collaborator"),
+		}},
+	}
+	f, err := NewOrgFilter(nil, []string{"*-sandbox", "legacy"}, []Org{{ID: "org-2", Name: "Payments Sandbox", Slug: "payments-sandbox"}})
+	assert.NoError(t, err)
+
+	ops, skipped := planOrgMemberships(&uAttributes, SyncOptions{Mode: ModeMirror, Orgs: f})
+
+	// the sandbox membership is not recreated and the legacy membership is not deleted
+	var actions, orgIDs []string
+	for _, op := range ops {
+		actions = append(actions, op.Action)
+		orgIDs = append(orgIDs, op.OrgID)
+	}
+	assert.Equal(t, []string{ActionCreateOrgMembership, ActionDeleteOrgMembership}, actions)
+	assert.Equal(t, []string{"org-1", "org-4"}, orgIDs)
+	assert.Len(t, skipped, 1)
+	assert.Equal(t, "OrgMembership, Org: Payments Sandbox, Role: Org Admin (org is excluded)", skipped[0].Description())
+}
@@ -0,0 +1,521 @@
+package membership
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+)
+
+const (
+	ActionCreateGroupMembership     = "create_group_membership"
+	ActionUpdateGroupMembershipRole = "update_group_membership_role"
+	ActionDeleteGroupMembership     = "delete_group_membership"
+	ActionCreateOrgMembership       = "create_org_membership"
+	ActionUpdateOrgMembershipRole   = "update_org_membership_role"
+	ActionDeleteOrgMembership       = "delete_org_membership"
+)
+
+const (
+	TypeGroup = "group"
+	TypeOrg   = "org"
+	TypeRole  = "role"
+)
+
+// Operation is a single mutating membership request planned for a provisioned User.
+type Operation struct {
+	Action       string `json:"action"`
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	GroupID      string `json:"groupId,omitempty"`
+	GroupName    string `json:"groupName,omitempty"`
+	OrgID        string `json:"orgId,omitempty"`
+	OrgName      string `json:"orgName,omitempty"`
+	MembershipID string `json:"membershipId,omitempty"`
+	RoleID       string `json:"roleId,omitempty"`
+	RoleName     string `json:"roleName,omitempty"`
+}
+
+// UserPlan holds the Operations that synchronize the memberships of a provisioned User with its pre-migrated User.
+type UserPlan struct {
+	SourceIdentifier      string      `json:"sourceIdentifier"`
+	SourceUserID          string      `json:"sourceUserId"`
+	DestinationIdentifier string      `json:"destinationIdentifier"`
+	DestinationUserID     string      `json:"destinationUserId"`
+	Operations            []Operation `json:"operations"`
+	// DestinationMemberships is the state of the provisioned User memberships the Operations were planned against
+	DestinationMemberships []MembershipState `json:"destinationMemberships"`
+	// SkippedMemberships lists the memberships of the pre-migrated User that are not recreated for the provisioned User
+	SkippedMemberships []SkippedMembership `json:"skippedMemberships,omitempty"`
+	// Unread lists the memberships of the User pair that failed to be fetched, no Operation is planned from them
+	// and none at all if the memberships of the provisioned User are unread
+	Unread []string `json:"unread,omitempty"`
+}
+
+// Plan is the complete set of membership changes of a synchronization, ordered by source identifier.
+type Plan struct {
+	GroupID string     `json:"groupId"`
+	Users   []UserPlan `json:"users"`
+	// SourceGroupID is the Group the memberships are migrated from, empty if it is the Group of the plan
+	SourceGroupID string `json:"sourceGroupId,omitempty"`
+	// Unmatched lists the source identifiers of the pre-migrated Users without a matching provisioned User
+	Unmatched []string `json:"unmatched,omitempty"`
+	// Conflicts lists the ambiguous pairings of pre-migrated Users, none of their Users is synchronized
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+}
+
+// isGroupMembership checks whether the Operation modifies a Group membership.
+func (op Operation) isGroupMembership() bool {
+	switch op.Action {
+	case ActionCreateGroupMembership, ActionUpdateGroupMembershipRole, ActionDeleteGroupMembership:
+		return true
+	}
+	return false
+}
+
+// Description returns a human readable summary of the Operation.
+func (op Operation) Description() string {
+	switch op.Action {
+	case ActionUpdateGroupMembershipRole:
+		return fmt.Sprintf("update GroupMembership role, Group: %s, Role: %s", nameOrID(op.GroupName, op.GroupID), nameOrID(op.RoleName, op.RoleID))
+	case ActionCreateGroupMembership:
+		return fmt.Sprintf("create GroupMembership, Group: %s, Role: %s", nameOrID(op.GroupName, op.GroupID), nameOrID(op.RoleName, op.RoleID))
+	case ActionDeleteGroupMembership:
+		return fmt.Sprintf("delete GroupMembership, Group: %s, Role: %s", nameOrID(op.GroupName, op.GroupID), nameOrID(op.RoleName, op.RoleID))
+	case ActionDeleteOrgMembership:
+		return fmt.Sprintf("delete OrgMembership, Org: %s, Role: %s", nameOrID(op.OrgName, op.OrgID), nameOrID(op.RoleName, op.RoleID))
+	case ActionCreateOrgMembership:
+		return fmt.Sprintf("create OrgMembership, Org: %s, Role: %s", nameOrID(op.OrgName, op.OrgID), nameOrID(op.RoleName, op.RoleID))
+	case ActionUpdateOrgMembershipRole:
+		return fmt.Sprintf("update OrgMembership role, Org: %s, Role: %s", nameOrID(op.OrgName, op.OrgID), nameOrID(op.RoleName, op.RoleID))
+	}
+	return op.Action
+}
+
+// nameOrID returns the name of a Group, Org or Role, or its ID if its name is unknown.
+func nameOrID(name, id string) string {
+	if name == "" {
+		return id
+	}
+	return name
+}
+
+// Write prints the Plan as a reviewable list of requests per User pair followed by a summary of all Operations.
+func (p *Plan) Write(w io.Writer) error {
+	actionCount := make(map[string]int)
+	var b strings.Builder
+	if p.SourceGroupID != "" {
+		fmt.Fprintf(&b, "Migration: Group %s -> Group %s\n", p.SourceGroupID, p.GroupID)
+	}
+	for _, up := range p.Users {
+		fmt.Fprintf(&b, "User: %s (%s) -> %s (%s)\n", up.SourceIdentifier, up.SourceUserID, up.DestinationIdentifier, up.DestinationUserID)
+		if len(up.Operations) == 0 {
+			b.WriteString("  no changes\n")
+		}
+		for _, op := range up.Operations {
+			fmt.Fprintf(&b, "  %-6s %s\n", op.Method, op.Path)
+			fmt.Fprintf(&b, "         %s\n", op.Description())
+			actionCount[op.Action]++
+		}
+		for _, sm := range up.SkippedMemberships {
+			fmt.Fprintf(&b, "  %-6s %s\n", "SKIP", sm.Description())
+		}
+	}
+	for _, c := range p.Conflicts {
+		fmt.Fprintf(&b, "Skipped %s\n", c.String())
+	}
+	if domains := p.domainCounts(); len(domains) > 1 {
+		for _, dc := range domains {
+			fmt.Fprintf(&b, "Domain %s: %d Users, %d unmatched, %d conflicting\n", dc.domain, dc.users, dc.unmatched, dc.conflicting)
+		}
+	}
+	fmt.Fprintf(&b, "Plan: %d Users, %d GroupMembership creations, %d GroupMembership role updates, %d GroupMembership deletions, "+
+		"%d OrgMembership creations, %d OrgMembership role updates, %d OrgMembership deletions\n",
+		len(p.Users),
+		actionCount[ActionCreateGroupMembership],
+		actionCount[ActionUpdateGroupMembershipRole],
+		actionCount[ActionDeleteGroupMembership],
+		actionCount[ActionCreateOrgMembership],
+		actionCount[ActionUpdateOrgMembershipRole],
+		actionCount[ActionDeleteOrgMembership])
+
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
+
+// domainCount counts the pre-migrated Users of a domain of a Plan.
+type domainCount struct {
+	domain      string
+	users       int
+	unmatched   int
+	conflicting int
+}
+
+// domainCounts counts the User pairs, the unmatched and the conflicting pre-migrated Users per domain of their source identifier, ordered by domain.
+func (p *Plan) domainCounts() []domainCount {
+	countsByDomain := make(map[string]*domainCount)
+	count := func(identifier string) *domainCount {
+		domain := IdentifierDomain(identifier)
+		if _, ok := countsByDomain[domain]; !ok {
+			countsByDomain[domain] = &domainCount{domain: domain}
+		}
+		return countsByDomain[domain]
+	}
+	for _, up := range p.Users {
+		count(up.SourceIdentifier).users++
+	}
+	for _, sourceIdentifier := range p.Unmatched {
+		count(sourceIdentifier).unmatched++
+	}
+	for _, c := range p.Conflicts {
+		for _, sourceIdentifier := range c.SourceIdentifiers {
+			count(sourceIdentifier).conflicting++
+		}
+	}
+
+	counts := make([]domainCount, 0, len(countsByDomain))
+	for _, dc := range countsByDomain {
+		counts = append(counts, *dc)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].domain < counts[j].domain
+	})
+	return counts
+}
+
+// relationshipData builds the relationship data of a membership from its identifier, type and name.
+func relationshipData(id, idType, name string) *struct {
+	Data *TypeIdentifierAttributes `json:"data"`
+} {
+	return &struct {
+		Data *TypeIdentifierAttributes `json:"data"`
+	}{
+		Data: &TypeIdentifierAttributes{
+			ID:         &id,
+			Type:       &idType,
+			Attributes: &AttributesName{Name: &name},
+		},
+	}
+}
+
+// relationshipName safely extracts the name attribute of a membership relationship.
+func relationshipName(data *TypeIdentifierAttributes) string {
+	if data == nil || data.Attributes == nil || data.Attributes.Name == nil {
+		return ""
+	}
+	return *data.Attributes.Name
+}
+
+// membershipOperation builds an Operation on the Group or Org of the given membership state.
+// The membership identifier is only set for Operations on an existing membership.
+func membershipOperation(action string, state MembershipState, membershipID string) Operation {
+	op := Operation{
+		Action:       action,
+		GroupID:      state.GroupID,
+		GroupName:    state.GroupName,
+		OrgID:        state.OrgID,
+		OrgName:      state.OrgName,
+		MembershipID: membershipID,
+		RoleID:       state.RoleID,
+		RoleName:     state.RoleName,
+	}
+
+	switch action {
+	case ActionCreateGroupMembership:
+		op.Method = http.MethodPost
+		op.Path = fmt.Sprintf("/rest/groups/%s/memberships", state.GroupID)
+	case ActionUpdateGroupMembershipRole:
+		op.Method = http.MethodPatch
+		op.Path = fmt.Sprintf("/rest/groups/%s/memberships/%s", state.GroupID, membershipID)
+	case ActionDeleteGroupMembership:
+		op.Method = http.MethodDelete
+		op.Path = fmt.Sprintf("/rest/groups/%s/memberships/%s", state.GroupID, membershipID)
+	case ActionCreateOrgMembership:
+		op.Method = http.MethodPost
+		op.Path = fmt.Sprintf("/rest/orgs/%s/memberships", state.OrgID)
+	case ActionUpdateOrgMembershipRole:
+		op.Method = http.MethodPatch
+		op.Path = fmt.Sprintf("/rest/orgs/%s/memberships/%s", state.OrgID, membershipID)
+	case ActionDeleteOrgMembership:
+		op.Method = http.MethodDelete
+		op.Path = fmt.Sprintf("/rest/orgs/%s/memberships/%s", state.OrgID, membershipID)
+	}
+	return op
+}
+
+// membershipActions returns the create, role update and delete actions of a membership type.
+func membershipActions(mbrshipType string) (createAction, updateAction, deleteAction string) {
+	if mbrshipType == GroupMembershipType {
+		return ActionCreateGroupMembership, ActionUpdateGroupMembershipRole, ActionDeleteGroupMembership
+	}
+	return ActionCreateOrgMembership, ActionUpdateOrgMembershipRole, ActionDeleteOrgMembership
+}
+
+// membershipKey identifies the Group or Org a membership state grants access to.
+func membershipKey(state MembershipState) string {
+	if state.Type == GroupMembershipType {
+		return GroupMembershipType + "/" + state.GroupID
+	}
+	return OrgMembershipType + "/" + state.OrgID
+}
+
+// diffMemberships plans the delta between the desired and the current memberships of a User.
+// Memberships missing on the User are created, existing ones with a different role are updated
+// and memberships the User should not have are deleted.
+// A User may hold several memberships of different roles on the same Group: the current memberships of a desired role
+// are kept first, the remaining desired memberships then update the role of the remaining current memberships
+// of their Group and are created once there are none left.
+// A single membership per Org is expected, any other membership on the same Org is deleted.
+// Creations and updates are planned before deletions so that the User never loses access in between.
+// In ModeMerge, roles are only upgraded and no membership is deleted.
+func diffMemberships(desired, current []MembershipState, opts SyncOptions) []Operation {
+	var ops []Operation
+
+	// current memberships matching a desired Group or Org and role are kept unchanged
+	keptMembershipIDs := make(map[string]bool)
+	pending := make([]MembershipState, 0, len(desired))
+	planned := make(map[string]bool)
+	for _, d := range desired {
+		roleKey := membershipKey(d) + "/" + d.RoleID
+		if planned[roleKey] {
+			// prevent a duplicate creation should several desired memberships be identical
+			continue
+		}
+		planned[roleKey] = true
+		kept := false
+		for _, c := range current {
+			if !keptMembershipIDs[c.ID] && membershipKey(c) == membershipKey(d) && c.RoleID == d.RoleID {
+				keptMembershipIDs[c.ID] = true
+				kept = true
+				break
+			}
+		}
+		if !kept {
+			pending = append(pending, d)
+		}
+	}
+
+	// the remaining desired memberships update a remaining current membership of their Group or Org, or are created
+	plannedOrgKeys := make(map[string]bool)
+	for _, c := range current {
+		if c.Type != GroupMembershipType && keptMembershipIDs[c.ID] {
+			plannedOrgKeys[membershipKey(c)] = true
+		}
+	}
+	for _, d := range pending {
+		createAction, updateAction, _ := membershipActions(d.Type)
+		key := membershipKey(d)
+		if d.Type != GroupMembershipType && plannedOrgKeys[key] {
+			// a single membership per Org
+			continue
+		}
+		plannedOrgKeys[key] = d.Type != GroupMembershipType
+		var c *MembershipState
+		for i := range current {
+			if !keptMembershipIDs[current[i].ID] && membershipKey(current[i]) == key {
+				c = &current[i]
+				break
+			}
+		}
+		if c == nil {
+			ops = append(ops, membershipOperation(createAction, d, ""))
+			continue
+		}
+		keptMembershipIDs[c.ID] = true
+		if opts.allowsRoleChange(c.RoleID, c.RoleName, d.RoleID, d.RoleName) {
+			ops = append(ops, membershipOperation(updateAction, d, c.ID))
+		}
+	}
+
+	if opts.isMerge() {
+		return ops
+	}
+	for _, c := range current {
+		if !keptMembershipIDs[c.ID] {
+			_, _, deleteAction := membershipActions(c.Type)
+			ops = append(ops, membershipOperation(deleteAction, c, c.ID))
+		}
+	}
+	return ops
+}
+
+// planGroupMemberships plans the delta between the Group memberships of the pre-migrated User and the provisioned User.
+// A pre-migrated User without Group membership has the Group memberships of its provisioned User deleted in ModeMirror,
+// while nothing is planned when its Group memberships failed to be fetched.
+// It returns the Group memberships of the pre-migrated User skipped because their role is rejected by the role mapping.
+func planGroupMemberships(uAttributes *provisionedUserAttributes, opts SyncOptions) ([]Operation, []SkippedMembership) {
+	if uAttributes.groupMemberships == nil {
+		return nil, nil
+	}
+	desired, current, skipped := mapRoles(toMembershipStates(uAttributes.groupMemberships, nil),
+		toMembershipStates(uAttributes.provisionedGroupMemberships, nil), opts.Roles)
+	return diffMemberships(desired, current, opts), skipped
+}
+
+// planOrgMemberships plans the delta between the Org memberships of the pre-migrated User and the provisioned User
+// on the Orgs allowed by the Org filter.
+// Nothing is planned when the Org memberships of the pre-migrated User failed to be fetched.
+// It returns the Org memberships of the pre-migrated User skipped because their Org is excluded
+// or their role is rejected by the role mapping.
+func planOrgMemberships(uAttributes *provisionedUserAttributes, opts SyncOptions) ([]Operation, []SkippedMembership) {
+	if uAttributes.orgMemberships == nil {
+		return nil, nil
+	}
+	desired, current, excluded := filterOrgs(toMembershipStates(nil, uAttributes.orgMemberships),
+		toMembershipStates(nil, uAttributes.provisionedOrgMemberships), opts.Orgs)
+	desired, current, rejected := mapRoles(desired, current, opts.Roles)
+	return diffMemberships(desired, current, opts), append(excluded, rejected...)
+}
+
+// buildPlan computes the Operations of every User pair with a matched provisioned User.
+func buildPlan(groupID string, provisionedUserAttributesMap map[string]provisionedUserAttributes, opts SyncOptions) *Plan {
+	plan := &Plan{GroupID: groupID}
+
+	prevKeyIDs := make([]string, 0, len(provisionedUserAttributesMap))
+	for prevKeyID := range provisionedUserAttributesMap {
+		prevKeyIDs = append(prevKeyIDs, prevKeyID)
+	}
+	sort.Strings(prevKeyIDs)
+
+	for _, prevKeyID := range prevKeyIDs {
+		uAttributes := provisionedUserAttributesMap[prevKeyID]
+		if uAttributes.provisionedID == nil {
+			plan.Unmatched = append(plan.Unmatched, prevKeyID)
+			continue
+		}
+
+		var groupOps, orgOps []Operation
+		var groupSkipped, orgSkipped []SkippedMembership
+		// the current memberships of the provisioned User must be known to plan any change
+		if uAttributes.provisionedGroupMemberships != nil && uAttributes.provisionedOrgMemberships != nil {
+			groupOps, groupSkipped = planGroupMemberships(&uAttributes, opts)
+			orgOps, orgSkipped = planOrgMemberships(&uAttributes, opts)
+		}
+		plan.Users = append(plan.Users, UserPlan{
+			SourceIdentifier:      prevKeyID,
+			SourceUserID:          *uAttributes.id,
+			DestinationIdentifier: *uAttributes.provisionedUserName,
+			DestinationUserID:     *uAttributes.provisionedID,
+			Operations:            append(groupOps, orgOps...),
+			DestinationMemberships: toMembershipStates(uAttributes.provisionedGroupMemberships,
+				uAttributes.provisionedOrgMemberships),
+			SkippedMemberships: slices.Concat(uAttributes.skippedMemberships, groupSkipped, orgSkipped),
+			Unread:             unreadMemberships(&uAttributes),
+		})
+	}
+	return plan
+}
+
+// Memberships of a User pair listed by UserPlan.Unread when they failed to be fetched.
+const (
+	UnreadSourceGroupMemberships      = "source GroupMemberships"
+	UnreadSourceOrgMemberships        = "source OrgMemberships"
+	UnreadDestinationGroupMemberships = "destination GroupMemberships"
+	UnreadDestinationOrgMemberships   = "destination OrgMemberships"
+)
+
+// unreadMemberships lists the memberships of a User pair that failed to be fetched.
+func unreadMemberships(uAttributes *provisionedUserAttributes) []string {
+	var unread []string
+	if uAttributes.groupMemberships == nil {
+		unread = append(unread, UnreadSourceGroupMemberships)
+	}
+	if uAttributes.orgMemberships == nil {
+		unread = append(unread, UnreadSourceOrgMemberships)
+	}
+	if uAttributes.provisionedGroupMemberships == nil {
+		unread = append(unread, UnreadDestinationGroupMemberships)
+	}
+	if uAttributes.provisionedOrgMemberships == nil {
+		unread = append(unread, UnreadDestinationOrgMemberships)
+	}
+	return unread
+}
+
+// destinationUnread checks whether the memberships of the provisioned User failed to be fetched,
+// no Operation is then planned for the User pair as its current memberships are unknown.
+func (up *UserPlan) destinationUnread() bool {
+	return slices.Contains(up.Unread, UnreadDestinationGroupMemberships) || slices.Contains(up.Unread, UnreadDestinationOrgMemberships)
+}
+
+// unreadError describes the memberships of the User pair that failed to be fetched, nil if every membership was read.
+func (up *UserPlan) unreadError() error {
+	if len(up.Unread) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to read %s", strings.Join(up.Unread, ", "))
+}
+
+// PlanMemberships computes the membership changes required to synchronize provisioned users with the corresponding SSO users
+// without issuing any mutating request.
+func (m *Client) PlanMemberships(groupID string, users sso.Users, match MatchOptions, opts SyncOptions, logger *zerolog.Logger) *Plan {
+	return m.planScope(groupScope(groupID, users), match, opts, logger)
+}
+
+// PlanConnections computes the membership changes required to synchronize the provisioned Users of an SSO connection of the Group
+// with their pre-migrated Users of another SSO connection of the same Group, without issuing any mutating request.
+func (m *Client) PlanConnections(groupID string, sourceUsers, destinationUsers sso.Users, match MatchOptions, opts SyncOptions, logger *zerolog.Logger) *Plan {
+	return m.planScope(connectionsScope(groupID, sourceUsers, destinationUsers), match, opts, logger)
+}
+
+// planScope computes the membership changes of the User pairs of the scope on the destination Group.
+func (m *Client) planScope(scope syncScope, match MatchOptions, opts SyncOptions, logger *zerolog.Logger) *Plan {
+	_, provisionedUserAttributesMap, conflicts := m.mapProvisionedUsersAttributes(scope, match, opts.Concurrency, logger)
+	if scope.migration != nil {
+		for prevKeyID, uAttributes := range *provisionedUserAttributesMap {
+			(*provisionedUserAttributesMap)[prevKeyID] = scope.migration.translate(prevKeyID, uAttributes, logger)
+		}
+	}
+	plan := buildPlan(scope.destinationGroupID, *provisionedUserAttributesMap, opts)
+	if scope.migration != nil {
+		plan.SourceGroupID = scope.sourceGroupID
+	}
+	plan.Conflicts = conflicts
+	return plan
+}
+
+// applyOperation issues the request of a planned Operation for the provisioned User.
+func (m *Client) applyOperation(op Operation, userID string) error {
+	switch op.Action {
+	case ActionUpdateGroupMembershipRole:
+		mbr := Membership{
+			Relationship: &MemberRelationship{
+				Role: relationshipData(op.RoleID, TypeRole, op.RoleName),
+			},
+		}
+		return m.updateRoleAtUserGroupMembership(op.GroupID, op.MembershipID, mbr)
+	case ActionCreateGroupMembership:
+		groupMbrRelationship := MemberRelationship{
+			Group: relationshipData(op.GroupID, TypeGroup, op.GroupName),
+			Role:  relationshipData(op.RoleID, TypeRole, op.RoleName),
+			User:  relationshipData(userID, sso.TypeUser, ""),
+		}
+		_, err := m.createUserGroupMembership(op.GroupID, groupMbrRelationship)
+		return err
+	case ActionDeleteGroupMembership:
+		return m.deleteGroupMembership(op.GroupID, op.MembershipID)
+	case ActionUpdateOrgMembershipRole:
+		mbr := Membership{
+			Relationship: &MemberRelationship{
+				Role: relationshipData(op.RoleID, TypeRole, op.RoleName),
+			},
+		}
+		return m.updateRoleAtUserOrgMembership(op.OrgID, op.MembershipID, mbr)
+	case ActionDeleteOrgMembership:
+		return m.deleteOrgMembership(op.OrgID, op.MembershipID)
+	case ActionCreateOrgMembership:
+		orgMbrRelationship := MemberRelationship{
+			Org:  relationshipData(op.OrgID, TypeOrg, op.OrgName),
+			Role: relationshipData(op.RoleID, TypeRole, op.RoleName),
+			User: relationshipData(userID, sso.TypeUser, ""),
+		}
+		_, err := m.createUserOrgMembership(op.OrgID, orgMbrRelationship)
+		return err
+	}
+	return fmt.Errorf("unsupported operation: %s", op.Action)
+}
@@ -0,0 +1,492 @@
+package membership
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// makeOrgMembership is a helper to create an org Membership for tests
+func makeOrgMembership(id, orgID, orgName, roleID, roleName string) Membership {
+	return Membership{
+		ID:   stringPtr(id),
+		Type: stringPtr(OrgMembershipType),
+		Relationship: &MemberRelationship{
+			Org:  relationshipData(orgID, TypeOrg, orgName),
+			Role: relationshipData(roleID, TypeRole, roleName),
+		},
+	}
+}
+
+// makeGroupMembership is a helper to create a group Membership for tests
+func makeGroupMembership(id, groupID, groupName, roleID, roleName string) Membership {
+	return Membership{
+		ID:   stringPtr(id),
+		Type: stringPtr(GroupMembershipType),
+		Relationship: &MemberRelationship{
+			Group: relationshipData(groupID, TypeGroup, groupName),
+			Role:  relationshipData(roleID, TypeRole, roleName),
+		},
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	groupID := "group-id"
+	provisionedUserAttributesMap := map[string]provisionedUserAttributes{
+		"user2@source.com": {
+			id:       stringPtr("src-2"),
+			userName: stringPtr("user2@source.com"),
+		},
+		"user1@source.com": {
+			id:       stringPtr("src-1"),
+			userName: stringPtr("user1@source.com"),
+			groupMemberships: &UserGroupMemberships{Data: []Membership{
+				makeGroupMembership("gm-src-1", groupID, "Group", "role-member", "Group Member"),
+			}},
+			orgMemberships: &UserOrgMemberships{Data: []Membership{
+				makeOrgMembership("om-src-1", "org-1", "Org One", "role-admin", "Org Admin"),
+			}},
+			provisionedID:       stringPtr("dst-1"),
+			provisionedUserName: stringPtr("user1"),
+			provisionedGroupMemberships: &UserGroupMemberships{Data: []Membership{
+				makeGroupMembership("gm-dst-1", groupID, "Group", "role-viewer", "Group Viewer"),
+			}},
+			provisionedOrgMemberships: &UserOrgMemberships{Data: []Membership{
+				makeOrgMembership("om-dst-1", "org-2", "Org Two", "role-collaborator", "Org Collaborator"),
+			}},
+		},
+		"user3@source.com": {
+			id:       stringPtr("src-3"),
+			userName: stringPtr("user3@source.com"),
+			groupMemberships: &UserGroupMemberships{Data: []Membership{
+				makeGroupMembership("gm-src-3", groupID, "Group", "role-admin", "Group Admin"),
+			}},
+			provisionedID:               stringPtr("dst-3"),
+			provisionedUserName:         stringPtr("user3"),
+			provisionedGroupMemberships: &UserGroupMemberships{},
+			provisionedOrgMemberships:   &UserOrgMemberships{},
+		},
+	}
+
+	plan := buildPlan(groupID, provisionedUserAttributesMap, SyncOptions{Mode: ModeMirror})
+
+	assert.Equal(t, groupID, plan.GroupID)
+	// unmatched users are not planned and users are ordered by source identifier
+	assert.Len(t, plan.Users, 2)
+	assert.Equal(t, "user1@source.com", plan.Users[0].SourceIdentifier)
+	assert.Equal(t, "user3@source.com", plan.Users[1].SourceIdentifier)
+	assert.Equal(t, []string{"user2@source.com"}, plan.Unmatched)
+
+	assert.Equal(t, []Operation{
+		{
+			Action:       ActionUpdateGroupMembershipRole,
+			Method:       http.MethodPatch,
+			Path:         fmt.Sprintf("/rest/groups/%s/memberships/gm-dst-1", groupID),
+			GroupID:      groupID,
+			GroupName:    "Group",
+			MembershipID: "gm-dst-1",
+			RoleID:       "role-member",
+			RoleName:     "Group Member",
+		},
+		{
+			Action:   ActionCreateOrgMembership,
+			Method:   http.MethodPost,
+			Path:     "/rest/orgs/org-1/memberships",
+			OrgID:    "org-1",
+			OrgName:  "Org One",
+			RoleID:   "role-admin",
+			RoleName: "Org Admin",
+		},
+		{
+			Action:       ActionDeleteOrgMembership,
+			Method:       http.MethodDelete,
+			Path:         "/rest/orgs/org-2/memberships/om-dst-1",
+			OrgID:        "org-2",
+			OrgName:      "Org Two",
+			MembershipID: "om-dst-1",
+			RoleID:       "role-collaborator",
+			RoleName:     "Org Collaborator",
+		},
+	}, plan.Users[0].Operations)
+
+	assert.Equal(t, []Operation{
+		{
+			Action:    ActionCreateGroupMembership,
+			Method:    http.MethodPost,
+			Path:      fmt.Sprintf("/rest/groups/%s/memberships", groupID),
+			GroupID:   groupID,
+			GroupName: "Group",
+			RoleID:    "role-admin",
+			RoleName:  "Group Admin",
+		},
+	}, plan.Users[1].Operations)
+}
+
+func TestBuildPlan_UnreadDestination(t *testing.T) {
+	groupID := "group-id"
+	provisionedUserAttributesMap := map[string]provisionedUserAttributes{
+		"user1@source.com": {
+			id:       stringPtr("src-1"),
+			userName: stringPtr("user1@source.com"),
+			groupMemberships: &UserGroupMemberships{Data: []Membership{
+				makeGroupMembership("gm-src-1", groupID, "Group", "role-member", "Group Member"),
+			}},
+			orgMemberships: &UserOrgMemberships{Data: []Membership{
+				makeOrgMembership("om-src-1", "org-1", "Org One", "role-admin", "Org Admin"),
+			}},
+			provisionedID:               stringPtr("dst-1"),
+			provisionedUserName:         stringPtr("user1"),
+			provisionedGroupMemberships: &UserGroupMemberships{},
+		},
+	}
+
+	plan := buildPlan(groupID, provisionedUserAttributesMap, SyncOptions{Mode: ModeMirror})
+
+	// the current memberships of the provisioned User are unknown, nothing is planned for the pair
+	assert.Len(t, plan.Users, 1)
+	assert.Empty(t, plan.Users[0].Operations)
+	assert.Equal(t, []string{UnreadDestinationOrgMemberships}, plan.Users[0].Unread)
+	assert.Empty(t, plan.Snapshot().Users)
+}
+
+func TestPlanOrgMemberships(t *testing.T) {
+	tests := []struct {
+		name                      string
+		orgMemberships            []Membership
+		provisionedOrgMemberships []Membership
+		expectedActions           []string
+		expectedMembershipIDs     []string
+	}{
+		{
+			name: "no changes when memberships and roles are equal",
+			orgMemberships: []Membership{
+				makeOrgMembership("om-src-1", "org-1", "Org One", "role-admin", "Org Admin"),
+			},
+			provisionedOrgMemberships: []Membership{
+				makeOrgMembership("om-dst-1", "org-1", "Org One", "role-admin", "Org Admin"),
+			},
+			expectedActions:       nil,
+			expectedMembershipIDs: nil,
+		},
+		{
+			name: "add, update and remove",
+			orgMemberships: []Membership{
+				makeOrgMembership("om-src-1", "org-1", "Org One", "role-admin", "Org Admin"),
+				makeOrgMembership("om-src-2", "org-2", "Org Two", "role-collaborator", "Org Collaborator"),
+			},
+			provisionedOrgMemberships: []Membership{
+				makeOrgMembership("om-dst-3", "org-3", "Org Three", "role-admin", "Org Admin"),
+				makeOrgMembership("om-dst-2", "org-2", "Org Two", "role-admin", "Org Admin"),
+			},
+			expectedActions:       []string{ActionCreateOrgMembership, ActionUpdateOrgMembershipRole, ActionDeleteOrgMembership},
+			expectedMembershipIDs: []string{"", "om-dst-2", "om-dst-3"},
+		},
+		{
+			name: "duplicate memberships on the same Org",
+			orgMemberships: []Membership{
+				makeOrgMembership("om-src-1", "org-1", "Org One", "role-admin", "Org Admin"),
+				makeOrgMembership("om-src-2", "org-1", "Org One", "role-admin", "Org Admin"),
+			},
+			provisionedOrgMemberships: []Membership{
+				makeOrgMembership("om-dst-1", "org-1", "Org One", "role-admin", "Org Admin"),
+				makeOrgMembership("om-dst-2", "org-1", "Org One", "role-collaborator", "Org Collaborator"),
+			},
+			expectedActions:       []string{ActionDeleteOrgMembership},
+			expectedMembershipIDs: []string{"om-dst-2"},
+		},
+		{
+			name:                      "provisioned User without memberships",
+			orgMemberships:            []Membership{makeOrgMembership("om-src-1", "org-1", "Org One", "role-admin", "Org Admin")},
+			provisionedOrgMemberships: nil,
+			expectedActions:           []string{ActionCreateOrgMembership},
+			expectedMembershipIDs:     []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uAttributes := provisionedUserAttributes{
+				orgMemberships:            &UserOrgMemberships{Data: tt.orgMemberships},
+				provisionedOrgMemberships: &UserOrgMemberships{Data: tt.provisionedOrgMemberships},
+			}
+			ops, _ := planOrgMemberships(&uAttributes, SyncOptions{Mode: ModeMirror})
+
+			var actions, membershipIDs []string
+			for _, op := range ops {
+				actions = append(actions, op.Action)
+				membershipIDs = append(membershipIDs, op.MembershipID)
+			}
+			assert.Equal(t, tt.expectedActions, actions)
+			assert.Equal(t, tt.expectedMembershipIDs, membershipIDs)
+		})
+	}
+}
+
+func TestPlanGroupMemberships(t *testing.T) {
+	groupID := "group-id"
+	tests := []struct {
+		name                        string
+		groupMemberships            *UserGroupMemberships
+		provisionedGroupMemberships []Membership
+		expectedActions             []string
+		expectedMembershipIDs       []string
+		expectedRoleIDs             []string
+	}{
+		{
+			name: "every Group membership role is reconciled",
+			groupMemberships: &UserGroupMemberships{Data: []Membership{
+				makeGroupMembership("gm-src-1", groupID, "Group", "role-member", "Group Member"),
+				makeGroupMembership("gm-src-2", groupID, "Group", "role-viewer", "Group Viewer"),
+				makeGroupMembership("gm-src-3", groupID, "Group", "role-custom", "Custom"),
+			}},
+			provisionedGroupMemberships: []Membership{
+				makeGroupMembership("gm-dst-1", groupID, "Group", "role-admin", "Group Admin"),
+				makeGroupMembership("gm-dst-2", groupID, "Group", "role-viewer", "Group Viewer"),
+			},
+			expectedActions:       []string{ActionUpdateGroupMembershipRole, ActionCreateGroupMembership},
+			expectedMembershipIDs: []string{"gm-dst-1", ""},
+			expectedRoleIDs:       []string{"role-member", "role-custom"},
+		},
+		{
+			name: "extra Group memberships are deleted",
+			groupMemberships: &UserGroupMemberships{Data: []Membership{
+				makeGroupMembership("gm-src-1", groupID, "Group", "role-member", "Group Member"),
+			}},
+			provisionedGroupMemberships: []Membership{
+				makeGroupMembership("gm-dst-1", groupID, "Group", "role-admin", "Group Admin"),
+				makeGroupMembership("gm-dst-2", groupID, "Group", "role-member", "Group Member"),
+			},
+			expectedActions:       []string{ActionDeleteGroupMembership},
+			expectedMembershipIDs: []string{"gm-dst-1"},
+			expectedRoleIDs:       []string{"role-admin"},
+		},
+		{
+			name:             "pre-migrated User without Group membership in mirror mode",
+			groupMemberships: &UserGroupMemberships{},
+			provisionedGroupMemberships: []Membership{
+				makeGroupMembership("gm-dst-1", groupID, "Group", "role-member", "Group Member"),
+			},
+			expectedActions:       []string{ActionDeleteGroupMembership},
+			expectedMembershipIDs: []string{"gm-dst-1"},
+			expectedRoleIDs:       []string{"role-member"},
+		},
+		{
+			name:             "Group memberships failed to be fetched",
+			groupMemberships: nil,
+			provisionedGroupMemberships: []Membership{
+				makeGroupMembership("gm-dst-1", groupID, "Group", "role-member", "Group Member"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uAttributes := provisionedUserAttributes{
+				groupMemberships:            tt.groupMemberships,
+				provisionedGroupMemberships: &UserGroupMemberships{Data: tt.provisionedGroupMemberships},
+			}
+			ops, _ := planGroupMemberships(&uAttributes, SyncOptions{Mode: ModeMirror})
+
+			var actions, membershipIDs, roleIDs []string
+			for _, op := range ops {
+				actions = append(actions, op.Action)
+				membershipIDs = append(membershipIDs, op.MembershipID)
+				roleIDs = append(roleIDs, op.RoleID)
+			}
+			assert.Equal(t, tt.expectedActions, actions)
+			assert.Equal(t, tt.expectedMembershipIDs, membershipIDs)
+			assert.Equal(t, tt.expectedRoleIDs, roleIDs)
+		})
+	}
+
+	t.Run("pre-migrated User without Group membership in merge mode", func(t *testing.T) {
+		uAttributes := provisionedUserAttributes{
+			groupMemberships: &UserGroupMemberships{},
+			provisionedGroupMemberships: &UserGroupMemberships{Data: []Membership{
+				makeGroupMembership("gm-dst-1", groupID, "Group", "role-member", "Group Member"),
+			}},
+		}
+		ops, _ := planGroupMemberships(&uAttributes, SyncOptions{Mode: ModeMerge, RolePrecedence: DefaultRolePrecedence})
+		assert.Empty(t, ops)
+	})
+}
+
+func TestPlanMemberships_MergeMode(t *testing.T) {
+	groupID := "group-id"
+	opts := SyncOptions{Mode: ModeMerge, RolePrecedence: DefaultRolePrecedence}
+	uAttributes := provisionedUserAttributes{
+		groupMemberships: &UserGroupMemberships{Data: []Membership{
+			makeGroupMembership("gm-src-1", groupID, "Group", "role-member", "Group Member"),
+		}},
+		orgMemberships: &UserOrgMemberships{Data: []Membership{
+			makeOrgMembership("om-src-1", "org-1", "Org One", "role-admin", "Org Admin"),
+			makeOrgMembership("om-src-2", "org-2", "Org Two", "role-collaborator", "Org Collaborator"),
+			makeOrgMembership("om-src-4", "org-4", "Org Four", "role-admin", "Org Admin"),
+		}},
+		provisionedGroupMemberships: &UserGroupMemberships{Data: []Membership{
+			makeGroupMembership("gm-dst-1", groupID, "Group", "role-group-admin", "Group Admin"),
+		}},
+		provisionedOrgMemberships: &UserOrgMemberships{Data: []Membership{
+			makeOrgMembership("om-dst-1", "org-1", "Org One", "role-collaborator", "Org Collaborator"),
+			makeOrgMembership("om-dst-2", "org-2", "Org Two", "role-admin", "Org Admin"),
+			makeOrgMembership("om-dst-3", "org-3", "Org Three", "role-admin", "Org Admin"),
+		}},
+	}
+
+	// the Group Admin role is never downgraded
+	groupOps, _ := planGroupMemberships(&uAttributes, opts)
+	assert.Empty(t, groupOps)
+
+	ops, _ := planOrgMemberships(&uAttributes, opts)
+	assert.Len(t, ops, 2)
+	// Org One is upgraded to Org Admin
+	assert.Equal(t, ActionUpdateOrgMembershipRole, ops[0].Action)
+	assert.Equal(t, "om-dst-1", ops[0].MembershipID)
+	assert.Equal(t, "role-admin", ops[0].RoleID)
+	// Org Four is added, Org Two is not downgraded and Org Three is not removed
+	assert.Equal(t, ActionCreateOrgMembership, ops[1].Action)
+	assert.Equal(t, "org-4", ops[1].OrgID)
+}
+
+func TestPlanWrite(t *testing.T) {
+	plan := Plan{
+		GroupID: "group-id",
+		Users: []UserPlan{
+			{
+				SourceIdentifier:      "user1@source.com",
+				SourceUserID:          "src-1",
+				DestinationIdentifier: "user1",
+				DestinationUserID:     "dst-1",
+				Operations: []Operation{
+					{
+						Action:   ActionCreateOrgMembership,
+						Method:   http.MethodPost,
+						Path:     "/rest/orgs/org-1/memberships",
+						OrgID:    "org-1",
+						OrgName:  "Org One",
+						RoleID:   "role-admin",
+						RoleName: "Org Admin",
+					},
+				},
+			},
+			{
+				SourceIdentifier:      "user2@source.com",
+				SourceUserID:          "src-2",
+				DestinationIdentifier: "user2",
+				DestinationUserID:     "dst-2",
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := plan.Write(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "User: user1@source.com (src-1) -> user1 (dst-1)\n"+
+		"  POST   /rest/orgs/org-1/memberships\n"+
+		"         create OrgMembership, Org: Org One, Role: Org Admin\n"+
+		"User: user2@source.com (src-2) -> user2 (dst-2)\n"+
+		"  no changes\n"+
+		"Plan: 2 Users, 0 GroupMembership creations, 0 GroupMembership role updates, 0 GroupMembership deletions, "+
+		"1 OrgMembership creations, 0 OrgMembership role updates, 0 OrgMembership deletions\n",
+		buf.String())
+}
+
+func TestPlanWrite_Domains(t *testing.T) {
+	plan := Plan{
+		GroupID: "group-id",
+		Users: []UserPlan{
+			{SourceIdentifier: "alice@a.com", SourceUserID: "src-1", DestinationIdentifier: "alice", DestinationUserID: "dst-1"},
+			{SourceIdentifier: "bob@b.com", SourceUserID: "src-2", DestinationIdentifier: "bob", DestinationUserID: "dst-2"},
+		},
+		Unmatched: []string{"carol@b.com"},
+		Conflicts: []Conflict{{Kind: ConflictDuplicateSource, SourceIdentifiers: []string{"dave@a.com"}, DestinationIdentifiers: []string{}}},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, plan.Write(&buf))
+	assert.Contains(t, buf.String(), "Skipped duplicate-source conflict: dave@a.com -> \n"+
+		"Domain a.com: 1 Users, 0 unmatched, 1 conflicting\n"+
+		"Domain b.com: 1 Users, 1 unmatched, 0 conflicting\n"+
+		"Plan: 2 Users, ")
+}
+
+func TestApplyOperation(t *testing.T) {
+	userID := "dst-1"
+
+	t.Run("update group membership role", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		op := Operation{Action: ActionUpdateGroupMembershipRole, GroupID: "group-id", MembershipID: "gm-1", RoleID: "role-1"}
+
+		mockClient.On("Patch", "/rest/groups/group-id/memberships/gm-1", mock.MatchedBy(func(buf *bytes.Buffer) bool {
+			var reqBody RoleRequestBody
+			if err := json.Unmarshal(buf.Bytes(), &reqBody); err != nil {
+				return false
+			}
+			return reqBody.Data.ID == "gm-1" && *reqBody.Data.Relationships.Role.Data.ID == "role-1"
+		})).Return([]byte{}, nil)
+
+		assert.NoError(t, m.applyOperation(op, userID))
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("create org membership", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		op := Operation{Action: ActionCreateOrgMembership, OrgID: "org-1", RoleID: "role-1"}
+
+		mockClient.On("Post", "/rest/orgs/org-1/memberships", mock.MatchedBy(func(buf *bytes.Buffer) bool {
+			var reqBody RequestBody
+			if err := json.Unmarshal(buf.Bytes(), &reqBody); err != nil {
+				return false
+			}
+			return *reqBody.Data.Relationships.User.Data.ID == userID && *reqBody.Data.Relationships.Org.Data.ID == "org-1"
+		})).Return([]byte(`{"data":{"id":"om-1"}}`), nil)
+
+		assert.NoError(t, m.applyOperation(op, userID))
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("update org membership role", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		op := Operation{Action: ActionUpdateOrgMembershipRole, OrgID: "org-1", MembershipID: "om-1", RoleID: "role-1"}
+
+		mockClient.On("Patch", "/rest/orgs/org-1/memberships/om-1", mock.MatchedBy(func(buf *bytes.Buffer) bool {
+			var reqBody RoleRequestBody
+			if err := json.Unmarshal(buf.Bytes(), &reqBody); err != nil {
+				return false
+			}
+			return reqBody.Data.Type == OrgMembershipType && *reqBody.Data.Relationships.Role.Data.ID == "role-1"
+		})).Return([]byte{}, nil)
+
+		assert.NoError(t, m.applyOperation(op, userID))
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("delete org membership error", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		op := Operation{Action: ActionDeleteOrgMembership, OrgID: "org-1", MembershipID: "om-1"}
+
+		mockClient.On("Delete", "/rest/orgs/org-1/memberships/om-1").Return([]byte{}, errors.New("delete error"))
+
+		assert.EqualError(t, m.applyOperation(op, userID), "delete error")
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("unsupported operation", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+
+		assert.Error(t, m.applyOperation(Operation{Action: "unknown"}, userID))
+		mockClient.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,219 @@
+package membership
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// StatusSucceeded is the status of a membership request that succeeded.
+	StatusSucceeded = "succeeded"
+	// StatusUnchanged is the status of a membership request that was not needed, e.g. the membership already exists.
+	StatusUnchanged = "unchanged"
+	// StatusFailed is the status of a membership request that failed.
+	StatusFailed = "failed"
+	// StatusSkipped is the status of a User pair that was not synchronized.
+	StatusSkipped = "skipped"
+)
+
+// OperationResult is the outcome of a planned Operation.
+type OperationResult struct {
+	Operation
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// UserReport is the outcome of the synchronization of a User pair.
+type UserReport struct {
+	SourceIdentifier      string            `json:"sourceIdentifier"`
+	SourceUserID          string            `json:"sourceUserId,omitempty"`
+	DestinationIdentifier string            `json:"destinationIdentifier,omitempty"`
+	Matched               bool              `json:"matched"`
+	Status                string            `json:"status"`
+	GroupMemberships      []OperationResult `json:"groupMemberships"`
+	OrgMemberships        []OperationResult `json:"orgMemberships"`
+	Error                 string            `json:"error,omitempty"`
+	// SkippedMemberships lists the memberships of the pre-migrated User that were not recreated for the provisioned User
+	SkippedMemberships []SkippedMembership `json:"skippedMemberships,omitempty"`
+	// Unread lists the memberships of the User pair that failed to be fetched
+	Unread []string `json:"unread,omitempty"`
+	// Retirement is the outcome of the retirement of the pre-migrated User, nil if it was not retired
+	Retirement *Retirement `json:"retirement,omitempty"`
+}
+
+// Report is the outcome of a synchronization of every User pair, ordered by source identifier.
+type Report struct {
+	GroupID string       `json:"groupId"`
+	Users   []UserReport `json:"users"`
+	// Conflicts lists the ambiguous pairings of pre-migrated Users that were not synchronized
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+	// Domains counts the outcome of the User pairs of every domain of the pre-migrated Users
+	Domains []DomainStats `json:"domains,omitempty"`
+}
+
+// DomainStats counts the outcome of the synchronization of the pre-migrated Users of a domain.
+type DomainStats struct {
+	Domain    string `json:"domain"`
+	Users     int    `json:"users"`
+	Matched   int    `json:"matched"`
+	Succeeded int    `json:"succeeded"`
+	Unchanged int    `json:"unchanged"`
+	Failed    int    `json:"failed"`
+	Skipped   int    `json:"skipped"`
+}
+
+// String describes the DomainStats.
+func (ds DomainStats) String() string {
+	return fmt.Sprintf("Domain %s: %d Users, %d matched, %d succeeded, %d unchanged, %d failed, %d skipped",
+		ds.Domain, ds.Users, ds.Matched, ds.Succeeded, ds.Unchanged, ds.Failed, ds.Skipped)
+}
+
+// domainStats counts the outcome of the User pairs per domain of their source identifier, ordered by domain.
+func domainStats(userReports []UserReport) []DomainStats {
+	statsByDomain := make(map[string]*DomainStats)
+	for _, ur := range userReports {
+		domain := IdentifierDomain(ur.SourceIdentifier)
+		ds, ok := statsByDomain[domain]
+		if !ok {
+			ds = &DomainStats{Domain: domain}
+			statsByDomain[domain] = ds
+		}
+		ds.Users++
+		if ur.Matched {
+			ds.Matched++
+		}
+		switch ur.Status {
+		case StatusSucceeded:
+			ds.Succeeded++
+		case StatusUnchanged:
+			ds.Unchanged++
+		case StatusFailed:
+			ds.Failed++
+		case StatusSkipped:
+			ds.Skipped++
+		}
+	}
+
+	stats := make([]DomainStats, 0, len(statsByDomain))
+	for _, ds := range statsByDomain {
+		stats = append(stats, *ds)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Domain < stats[j].Domain
+	})
+	return stats
+}
+
+// newUserReport returns the report of a User pair whose memberships are not synchronized yet.
+func newUserReport(up *UserPlan) UserReport {
+	return UserReport{
+		SourceIdentifier:      up.SourceIdentifier,
+		SourceUserID:          up.SourceUserID,
+		DestinationIdentifier: up.DestinationIdentifier,
+		Matched:               true,
+		Status:                StatusSkipped,
+		GroupMemberships:      []OperationResult{},
+		OrgMemberships:        []OperationResult{},
+		SkippedMemberships:    up.SkippedMemberships,
+		Unread:                up.Unread,
+	}
+}
+
+// newUnmatchedUserReport returns the report of a pre-migrated User without a matching provisioned User.
+func newUnmatchedUserReport(sourceIdentifier string) UserReport {
+	return UserReport{
+		SourceIdentifier: sourceIdentifier,
+		Status:           StatusSkipped,
+		GroupMemberships: []OperationResult{},
+		OrgMemberships:   []OperationResult{},
+		Error:            "no matching provisioned User",
+	}
+}
+
+// newConflictUserReport returns the report of a pre-migrated User not synchronized because of a Conflict.
+func newConflictUserReport(sourceIdentifier string, c Conflict) UserReport {
+	return UserReport{
+		SourceIdentifier: sourceIdentifier,
+		Status:           StatusSkipped,
+		GroupMemberships: []OperationResult{},
+		OrgMemberships:   []OperationResult{},
+		Error:            c.String(),
+	}
+}
+
+// FailedUsers returns the source identifiers of the User pairs with a failed membership request.
+func (r *Report) FailedUsers() []string {
+	var failed []string
+	for _, ur := range r.Users {
+		if ur.Status == StatusFailed {
+			failed = append(failed, ur.SourceIdentifier)
+		}
+	}
+	return failed
+}
+
+// FailedRetirements returns the source identifiers of the User pairs whose pre-migrated User failed to be retired.
+func (r *Report) FailedRetirements() []string {
+	var failed []string
+	for _, ur := range r.Users {
+		if ur.Retirement != nil && ur.Retirement.Status == StatusFailed {
+			failed = append(failed, ur.SourceIdentifier)
+		}
+	}
+	return failed
+}
+
+// Err returns an error listing the User pairs with a failed membership request, or else the User pairs whose
+// pre-migrated User failed to be retired, nil if none failed.
+func (r *Report) Err() error {
+	if failed := r.FailedUsers(); len(failed) > 0 {
+		return fmt.Errorf("memberships of %d Users failed to synchronize: %s", len(failed), strings.Join(failed, ", "))
+	}
+	if failed := r.FailedRetirements(); len(failed) > 0 {
+		return fmt.Errorf("source of %d Users failed to retire: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// WriteJSON writes the machine-readable Report.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes the Report with a line per membership request, or a single line for a User pair without any.
+func (r *Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{"sourceIdentifier", "destinationIdentifier", "matched", "userStatus", "action",
+		"groupId", "groupName", "orgId", "orgName", "roleId", "roleName", "status", "error", "retirement", "retirementStatus"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, ur := range r.Users {
+		retirement, retirementStatus := "", ""
+		if ur.Retirement != nil {
+			retirement, retirementStatus = ur.Retirement.Action, ur.Retirement.Status
+		}
+		results := append(append([]OperationResult{}, ur.GroupMemberships...), ur.OrgMemberships...)
+		if len(results) == 0 {
+			results = []OperationResult{{Status: ur.Status, Error: ur.Error}}
+		}
+		for _, result := range results {
+			record := []string{ur.SourceIdentifier, ur.DestinationIdentifier, strconv.FormatBool(ur.Matched), ur.Status, result.Action,
+				result.GroupID, result.GroupName, result.OrgID, result.OrgName, result.RoleID, result.RoleName, result.Status, result.Error,
+				retirement, retirementStatus}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
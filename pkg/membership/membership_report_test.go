@@ -0,0 +1,133 @@
+package membership
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/client"
+	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestApplyPlan_Report(t *testing.T) {
+	logger := zerolog.Nop()
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	plan := &Plan{
+		GroupID: "group-id",
+		Users: []UserPlan{
+			{
+				SourceIdentifier:      "user1@source.com",
+				DestinationIdentifier: "user1",
+				DestinationUserID:     "dst-1",
+				Operations: []Operation{
+					{Action: ActionUpdateGroupMembershipRole, GroupID: "group-id", MembershipID: "gm-1", RoleID: "role-1"},
+					{Action: ActionCreateOrgMembership, OrgID: "org-1", RoleID: "role-admin"},
+					{Action: ActionDeleteOrgMembership, OrgID: "org-2", MembershipID: "om-2"},
+				},
+			},
+			{
+				SourceIdentifier:      "user3@source.com",
+				DestinationIdentifier: "user3",
+				DestinationUserID:     "dst-3",
+			},
+		},
+		Unmatched: []string{"user2@source.com"},
+	}
+
+	mockClient.On("Patch", "/rest/groups/group-id/memberships/gm-1", mock.Anything).Return([]byte{}, nil)
+	mockClient.On("Post", "/rest/orgs/org-1/memberships", mock.Anything).Return([]byte{}, &client.APIError{StatusCode: http.StatusConflict})
+	mockClient.On("Get", "/rest/orgs/org-1/memberships?limit=100&user_id=dst-1").Return(mockMembershipsResponse(
+		makeOrgMembership("om-1", "org-1", "Org One", "role-admin", "Org Admin"),
+	), nil)
+	mockClient.On("Delete", "/rest/orgs/org-2/memberships/om-2").Return([]byte{}, errors.New("status code 500"))
+
+	report, err := m.applyPlan(plan, SyncOptions{Concurrency: 1}, nil, &logger)
+	assert.NoError(t, err)
+	assert.Len(t, report.Users, 3)
+
+	user1 := report.Users[0]
+	assert.Equal(t, "user1@source.com", user1.SourceIdentifier)
+	assert.True(t, user1.Matched)
+	assert.Equal(t, StatusFailed, user1.Status)
+	assert.Len(t, user1.GroupMemberships, 1)
+	assert.Equal(t, StatusSucceeded, user1.GroupMemberships[0].Status)
+	assert.Len(t, user1.OrgMemberships, 2)
+	assert.Equal(t, StatusUnchanged, user1.OrgMemberships[0].Status)
+	assert.Equal(t, StatusFailed, user1.OrgMemberships[1].Status)
+	assert.Equal(t, "status code 500", user1.OrgMemberships[1].Error)
+
+	user2 := report.Users[1]
+	assert.Equal(t, "user2@source.com", user2.SourceIdentifier)
+	assert.False(t, user2.Matched)
+	assert.Equal(t, StatusSkipped, user2.Status)
+
+	user3 := report.Users[2]
+	assert.True(t, user3.Matched)
+	assert.Equal(t, StatusUnchanged, user3.Status)
+
+	assert.Equal(t, []DomainStats{
+		{Domain: "source.com", Users: 3, Matched: 2, Failed: 1, Unchanged: 1, Skipped: 1},
+	}, report.Domains)
+
+	assert.EqualError(t, report.Err(), "memberships of 1 Users failed to synchronize: user1@source.com")
+}
+
+func TestReportWrite(t *testing.T) {
+	report := &Report{
+		GroupID: "group-id",
+		Users: []UserReport{
+			{
+				SourceIdentifier:      "user1@source.com",
+				DestinationIdentifier: "user1",
+				Matched:               true,
+				Status:                StatusFailed,
+				GroupMemberships:      []OperationResult{},
+				OrgMemberships: []OperationResult{
+					{Operation: Operation{Action: ActionDeleteOrgMembership, OrgID: "org-2", OrgName: "Org Two"}, Status: StatusFailed, Error: "status code 500"},
+				},
+			},
+			newUnmatchedUserReport("user2@source.com"),
+		},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, report.WriteJSON(&buf))
+
+		var readReport Report
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &readReport))
+		assert.Equal(t, *report, readReport)
+		assert.Contains(t, buf.String(), `"action": "delete_org_membership"`)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, report.WriteCSV(&buf))
+		expected := "sourceIdentifier,destinationIdentifier,matched,userStatus,action,groupId,groupName,orgId,orgName,roleId,roleName,status,error,retirement,retirementStatus\n" +
+			"user1@source.com,user1,true,failed,delete_org_membership,,,org-2,Org Two,,,failed,status code 500,,\n" +
+			"user2@source.com,,false,skipped,,,,,,,,skipped,no matching provisioned User,,\n"
+		assert.Equal(t, expected, buf.String())
+	})
+}
+
+func TestDomainStats(t *testing.T) {
+	stats := domainStats([]UserReport{
+		{SourceIdentifier: "bob@b.com", Matched: true, Status: StatusSucceeded},
+		{SourceIdentifier: "alice@a.com", Matched: true, Status: StatusUnchanged},
+		{SourceIdentifier: "carol@b.com", Status: StatusSkipped},
+		{SourceIdentifier: "dave", Matched: true, Status: StatusFailed},
+	})
+	assert.Equal(t, []DomainStats{
+		{Domain: "", Users: 1, Matched: 1, Failed: 1},
+		{Domain: "a.com", Users: 1, Matched: 1, Unchanged: 1},
+		{Domain: "b.com", Users: 2, Matched: 1, Succeeded: 1, Skipped: 1},
+	}, stats)
+	assert.Equal(t, "Domain b.com: 2 Users, 1 matched, 1 succeeded, 0 unchanged, 0 failed, 1 skipped", stats[2].String())
+}
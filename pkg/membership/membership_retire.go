@@ -0,0 +1,170 @@
+package membership
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+)
+
+const (
+	// RetireRemoveMemberships removes the Group and Org memberships of the pre-migrated User once its memberships are synchronized.
+	RetireRemoveMemberships = "remove-memberships"
+	// RetireDeleteUser deletes the pre-migrated SSO User once its memberships are synchronized.
+	RetireDeleteUser = "delete-user"
+)
+
+// Retirement is the outcome of the retirement of the pre-migrated User of a User pair.
+type Retirement struct {
+	Action string `json:"action"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// GroupMemberships lists the removal of every Group membership of the pre-migrated User by RetireRemoveMemberships
+	GroupMemberships []OperationResult `json:"groupMemberships,omitempty"`
+	// OrgMemberships lists the removal of every Org membership of the pre-migrated User by RetireRemoveMemberships
+	OrgMemberships []OperationResult `json:"orgMemberships,omitempty"`
+}
+
+// ValidateRetireSource checks the retirement of the pre-migrated Users is supported, an empty value retires none.
+// The memberships of the retired Users must be snapshotted next to the snapshot of the provisioned Users.
+func ValidateRetireSource(retire, snapshotPath string) error {
+	switch retire {
+	case "":
+		return nil
+	case RetireRemoveMemberships, RetireDeleteUser:
+		if snapshotPath == "" {
+			return fmt.Errorf("retireSource requires a snapshotFile")
+		}
+		return nil
+	}
+	return fmt.Errorf("retireSource must be one of %s or %s: %s", RetireRemoveMemberships, RetireDeleteUser, retire)
+}
+
+// SourceSnapshotPath returns the path of the snapshot of the memberships of the retired pre-migrated Users,
+// the snapshot path of the provisioned Users suffixed with _source.
+func SourceSnapshotPath(snapshotPath string) string {
+	ext := filepath.Ext(snapshotPath)
+	return strings.TrimSuffix(snapshotPath, ext) + "_source" + ext
+}
+
+// retirable checks whether the pre-migrated User of a User pair may be retired: all its memberships were read and synchronized
+// and none of them was skipped.
+func retirable(ur *UserReport) bool {
+	return ur.Matched && (ur.Status == StatusSucceeded || ur.Status == StatusUnchanged) &&
+		len(ur.SkippedMemberships) == 0 && len(ur.Unread) == 0
+}
+
+// retiree is a pre-migrated User to retire and its memberships in the source Group.
+type retiree struct {
+	report      *UserReport
+	user        sso.User
+	memberships []MembershipState
+}
+
+// retireSources retires the pre-migrated User of every User pair of the report whose memberships were fully synchronized,
+// recording the outcome in the report of the User pair. The pre-migrated Users belong to the SSO connection of the source Group.
+// The memberships of the pre-migrated Users are snapshotted to the SourceSnapshotPath of snapshotPath before retiring any,
+// the error is only returned if the snapshot cannot be written or the SSO connection of the deleted Users cannot be selected,
+// no User is retired then.
+func (m *Client) retireSources(report *Report, sourceGroupID string, sourceUsers sso.Users, retire, connection, snapshotPath string, logger *zerolog.Logger) error {
+	usersByID := make(map[string]sso.User, len(sourceUsers.Data))
+	for _, u := range sourceUsers.Data {
+		if u.ID != nil {
+			usersByID[*u.ID] = u
+		}
+	}
+
+	var retirees []retiree
+	snapshot := &Snapshot{GroupID: sourceGroupID, Users: []UserSnapshot{}}
+	for i := range report.Users {
+		ur := &report.Users[i]
+		if !retirable(ur) {
+			continue
+		}
+		u, ok := usersByID[ur.SourceUserID]
+		if !ok {
+			continue
+		}
+		memberships, err := m.getUserMembershipStates(sourceGroupID, ur.SourceUserID)
+		if err != nil {
+			logger.Error().Msg(fmt.Sprintf("Failed to get memberships of source User: %s, %s", ur.SourceIdentifier, err.Error()))
+			ur.Retirement = &Retirement{Action: retire, Status: StatusFailed, Error: err.Error()}
+			continue
+		}
+		retirees = append(retirees, retiree{report: ur, user: u, memberships: memberships})
+		snapshot.Users = append(snapshot.Users, UserSnapshot{Identifier: ur.SourceIdentifier, UserID: ur.SourceUserID, Memberships: memberships})
+	}
+	if len(retirees) == 0 {
+		logger.Info().Msg(fmt.Sprintf("Retired 0 source Users: %s", retire))
+		return nil
+	}
+	// the SSO connection is selected once so that every deleted User belongs to the same connection
+	var connectionID string
+	if retire == RetireDeleteUser {
+		ssoConnection, err := sso.New(m.client).WithConnection(connection).SelectConnection(sourceGroupID)
+		if err != nil {
+			logger.Error().Msg(err.Error())
+			return err
+		}
+		connectionID = *ssoConnection.ID
+	}
+	if err := snapshot.writeFile(SourceSnapshotPath(snapshotPath), logger); err != nil {
+		return err
+	}
+
+	retired := 0
+	for _, r := range retirees {
+		switch retire {
+		case RetireRemoveMemberships:
+			r.report.Retirement = m.removeSourceMemberships(r.report, r.memberships, logger)
+		case RetireDeleteUser:
+			r.report.Retirement = m.deleteSourceUser(sourceGroupID, connectionID, r.user, logger)
+		}
+		if r.report.Retirement != nil && r.report.Retirement.Status != StatusFailed {
+			retired++
+		}
+	}
+	logger.Info().Msg(fmt.Sprintf("Retired %d source Users: %s", retired, retire))
+	return nil
+}
+
+// removeSourceMemberships removes every Group and Org membership of the pre-migrated User of a User pair in the source Group.
+func (m *Client) removeSourceMemberships(ur *UserReport, memberships []MembershipState, logger *zerolog.Logger) *Retirement {
+	retirement := &Retirement{Action: RetireRemoveMemberships, Status: StatusUnchanged}
+	for _, state := range memberships {
+		_, _, deleteAction := membershipActions(state.Type)
+		result := OperationResult{Operation: membershipOperation(deleteAction, state, state.ID), Status: StatusSucceeded}
+		var err error
+		if state.Type == GroupMembershipType {
+			err = m.deleteGroupMembership(state.GroupID, state.ID)
+		} else {
+			err = m.deleteOrgMembership(state.OrgID, state.ID)
+		}
+		if err != nil {
+			logger.Error().Msg(fmt.Sprintf("Failed to %s of source User: %s, %s", result.Description(), ur.SourceIdentifier, err.Error()))
+			result.Status = StatusFailed
+			result.Error = err.Error()
+			retirement.Status = StatusFailed
+		} else if retirement.Status != StatusFailed {
+			retirement.Status = StatusSucceeded
+		}
+		if state.Type == GroupMembershipType {
+			retirement.GroupMemberships = append(retirement.GroupMemberships, result)
+		} else {
+			retirement.OrgMemberships = append(retirement.OrgMemberships, result)
+		}
+	}
+	return retirement
+}
+
+// deleteSourceUser deletes the pre-migrated SSO User of a User pair from the SSO connection connectionID of the source Group.
+func (m *Client) deleteSourceUser(sourceGroupID, connectionID string, u sso.User, logger *zerolog.Logger) *Retirement {
+	retirement := &Retirement{Action: RetireDeleteUser, Status: StatusSucceeded}
+	if err := sso.New(m.client).DeleteUser(sourceGroupID, connectionID, u, logger); err != nil {
+		retirement.Status = StatusFailed
+		retirement.Error = err.Error()
+	}
+	return retirement
+}
@@ -0,0 +1,154 @@
+package membership
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestValidateRetireSource(t *testing.T) {
+	assert.NoError(t, ValidateRetireSource("", ""))
+	assert.NoError(t, ValidateRetireSource(RetireRemoveMemberships, "snapshot.json"))
+	assert.NoError(t, ValidateRetireSource(RetireDeleteUser, "snapshot.json"))
+	assert.EqualError(t, ValidateRetireSource(RetireDeleteUser, ""), "retireSource requires a snapshotFile")
+	assert.EqualError(t, ValidateRetireSource("disable", "snapshot.json"), "retireSource must be one of remove-memberships or delete-user: disable")
+}
+
+func TestSourceSnapshotPath(t *testing.T) {
+	assert.Equal(t, "out/snapshot_source.json", SourceSnapshotPath("out/snapshot.json"))
+	assert.Equal(t, "snapshot_source", SourceSnapshotPath("snapshot"))
+}
+
+func TestRetireSources(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "group-id"
+	sourceUsers := sso.Users{Data: []sso.User{
+		makeUser("src-1", "user1@source.com", "user1@source.com"),
+		makeUser("src-2", "user2@source.com", "user2@source.com"),
+		makeUser("src-3", "user3@source.com", "user3@source.com"),
+		makeUser("src-4", "user4@source.com", "user4@source.com"),
+		makeUser("src-5", "user5@source.com", "user5@source.com"),
+	}}
+	newReport := func() *Report {
+		return &Report{GroupID: groupID, Users: []UserReport{
+			{SourceIdentifier: "user1@source.com", SourceUserID: "src-1", Matched: true, Status: StatusSucceeded},
+			{SourceIdentifier: "user2@source.com", SourceUserID: "src-2", Matched: true, Status: StatusFailed},
+			{SourceIdentifier: "user3@source.com", SourceUserID: "src-3", Matched: true, Status: StatusUnchanged,
+				SkippedMemberships: []SkippedMembership{{Reason: "org is excluded"}}},
+			{SourceIdentifier: "user4@source.com", Status: StatusSkipped},
+			{SourceIdentifier: "user5@source.com", SourceUserID: "src-5", Matched: true, Status: StatusUnchanged,
+				Unread: []string{UnreadSourceOrgMemberships}},
+		}}
+	}
+
+	mockSourceMemberships := func(mockClient *mocks.MockSnykClient) {
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=src-1", groupID)).Return(mockMembershipsResponse(
+			makeGroupMembership("gm-1", groupID, "Group", "role-member", "Group Member"),
+		), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=src-1", groupID)).Return(mockMembershipsResponse(
+			makeOrgMembership("om-1", "org-1", "Org One", "role-admin", "Org Admin"),
+			makeOrgMembership("om-2", "org-2", "Org Two", "role-admin", "Org Admin"),
+		), nil)
+	}
+
+	t.Run("remove memberships of fully synchronized Users", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		mockSourceMemberships(mockClient)
+		mockClient.On("Delete", fmt.Sprintf("/rest/groups/%s/memberships/gm-1", groupID)).Return([]byte{}, nil)
+		mockClient.On("Delete", "/rest/orgs/org-1/memberships/om-1").Return([]byte{}, nil)
+		mockClient.On("Delete", "/rest/orgs/org-2/memberships/om-2").Return([]byte{}, errors.New("status code 500"))
+
+		report := newReport()
+		snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+		assert.NoError(t, m.retireSources(report, groupID, sourceUsers, RetireRemoveMemberships, "", snapshotPath, &logger))
+
+		retirement := report.Users[0].Retirement
+		assert.NotNil(t, retirement)
+		assert.Equal(t, RetireRemoveMemberships, retirement.Action)
+		assert.Equal(t, StatusFailed, retirement.Status)
+		assert.Len(t, retirement.GroupMemberships, 1)
+		assert.Equal(t, ActionDeleteGroupMembership, retirement.GroupMemberships[0].Action)
+		assert.Equal(t, StatusSucceeded, retirement.GroupMemberships[0].Status)
+		assert.Len(t, retirement.OrgMemberships, 2)
+		assert.Equal(t, StatusSucceeded, retirement.OrgMemberships[0].Status)
+		assert.Equal(t, "status code 500", retirement.OrgMemberships[1].Error)
+		// failed, skipped or unread memberships and unmatched Users are not retired
+		assert.Nil(t, report.Users[1].Retirement)
+		assert.Nil(t, report.Users[2].Retirement)
+		assert.Nil(t, report.Users[3].Retirement)
+		assert.Nil(t, report.Users[4].Retirement)
+		assert.Equal(t, []string{"user1@source.com"}, report.FailedRetirements())
+
+		// the memberships of the retired Users are snapshotted before their removal
+		file, err := os.Open(SourceSnapshotPath(snapshotPath))
+		assert.NoError(t, err)
+		defer file.Close()
+		snapshot, err := ReadSnapshot(file)
+		assert.NoError(t, err)
+		assert.Equal(t, groupID, snapshot.GroupID)
+		assert.Len(t, snapshot.Users, 1)
+		assert.Equal(t, "src-1", snapshot.Users[0].UserID)
+		assert.Len(t, snapshot.Users[0].Memberships, 3)
+	})
+
+	t.Run("retire no User if the snapshot cannot be written", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		mockSourceMemberships(mockClient)
+
+		report := newReport()
+		snapshotPath := filepath.Join(t.TempDir(), "missing", "snapshot.json")
+		assert.Error(t, m.retireSources(report, groupID, sourceUsers, RetireRemoveMemberships, "", snapshotPath, &logger))
+		assert.Nil(t, report.Users[0].Retirement)
+		mockClient.AssertNotCalled(t, "Delete", mock.Anything)
+	})
+
+	t.Run("delete fully synchronized Users", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		// the SSO connection is selected once for every deleted User
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections", groupID)).
+			Return([]byte(`{"data": [{"id": "connection-id", "type": "sso_connection", "attributes": {"name": "SSO"}}]}`), nil).Once()
+		mockClient.On("Delete", fmt.Sprintf("/rest/groups/%s/sso_connections/connection-id/users/src-1", groupID)).Return([]byte{}, nil)
+		mockClient.On("Delete", fmt.Sprintf("/rest/groups/%s/sso_connections/connection-id/users/src-2", groupID)).Return([]byte{}, nil)
+		mockSourceMemberships(mockClient)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=src-2", groupID)).Return(mockMembershipsResponse(), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=src-2", groupID)).Return(mockMembershipsResponse(), nil)
+
+		report := newReport()
+		report.Users[1].Status = StatusSucceeded
+		snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+		assert.NoError(t, m.retireSources(report, groupID, sourceUsers, RetireDeleteUser, "", snapshotPath, &logger))
+
+		assert.Equal(t, &Retirement{Action: RetireDeleteUser, Status: StatusSucceeded}, report.Users[0].Retirement)
+		assert.Equal(t, &Retirement{Action: RetireDeleteUser, Status: StatusSucceeded}, report.Users[1].Retirement)
+		assert.Nil(t, report.Users[2].Retirement)
+		assert.Empty(t, report.FailedRetirements())
+		mockClient.AssertNumberOfCalls(t, "Delete", 2)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("delete no User if the SSO connection cannot be selected", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections", groupID)).
+			Return([]byte(`{"data": [{"id": "connection-id", "type": "sso_connection", "attributes": {"name": "SSO"}}]}`), nil)
+		mockSourceMemberships(mockClient)
+
+		report := newReport()
+		snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+		assert.EqualError(t, m.retireSources(report, groupID, sourceUsers, RetireDeleteUser, "Other SSO", snapshotPath, &logger),
+			"SSO connection Other SSO not found on group: group-id")
+		assert.Nil(t, report.Users[0].Retirement)
+		mockClient.AssertNotCalled(t, "Delete", mock.Anything)
+	})
+}
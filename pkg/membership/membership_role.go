@@ -0,0 +1,167 @@
+package membership
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// UnmappedRolesPassthrough recreates the memberships of a role without a rule with the role of the pre-migrated User.
+	UnmappedRolesPassthrough = "passthrough"
+	// UnmappedRolesReject skips the memberships of a role without a rule, leaving the memberships of the provisioned User
+	// on the same Group or Org untouched.
+	UnmappedRolesReject = "reject"
+)
+
+// RoleRule translates a role of the pre-migrated User into the role given to the provisioned User.
+type RoleRule struct {
+	// Role is the ID or the case-insensitive name of the role of the pre-migrated User
+	Role string `json:"role"`
+	// Org restricts the rule to the Org memberships of an Org by its ID or case-insensitive name.
+	// The rules without Org apply to both Group and Org memberships.
+	Org string `json:"org,omitempty"`
+	// RoleID is the ID of the role of the provisioned User
+	RoleID string `json:"roleId"`
+	// RoleName is the name of the role of the provisioned User, only used for display
+	RoleName string `json:"roleName,omitempty"`
+}
+
+// RoleConfig is the content of a role mapping file.
+type RoleConfig struct {
+	Rules []RoleRule `json:"rules"`
+}
+
+// RoleMapping is a validated set of rules translating the roles of the memberships recreated for the provisioned Users.
+type RoleMapping struct {
+	rules          []RoleRule
+	rejectUnmapped bool
+}
+
+// SkippedMembership is a membership of a pre-migrated User that is not recreated for its provisioned User.
+type SkippedMembership struct {
+	MembershipState
+	Reason string `json:"reason"`
+}
+
+// Description returns a human readable summary of the skipped membership.
+func (s SkippedMembership) Description() string {
+	if s.Type == GroupMembershipType {
+		return fmt.Sprintf("GroupMembership, Group: %s, Role: %s (%s)", nameOrID(s.GroupName, s.GroupID), nameOrID(s.RoleName, s.RoleID), s.Reason)
+	}
+	return fmt.Sprintf("OrgMembership, Org: %s, Role: %s (%s)", nameOrID(s.OrgName, s.OrgID), nameOrID(s.RoleName, s.RoleID), s.Reason)
+}
+
+// ValidateUnmappedRoles checks the handling of the roles without a rule is supported, an empty value defaults to
+// UnmappedRolesPassthrough.
+func ValidateUnmappedRoles(unmapped string) error {
+	switch unmapped {
+	case "", UnmappedRolesPassthrough, UnmappedRolesReject:
+		return nil
+	}
+	return fmt.Errorf("unmappedRoles must be one of %s or %s: %s", UnmappedRolesPassthrough, UnmappedRolesReject, unmapped)
+}
+
+// NewRoleMapping validates the rules and the handling of the roles without a rule.
+func NewRoleMapping(rules []RoleRule, unmapped string) (*RoleMapping, error) {
+	if err := ValidateUnmappedRoles(unmapped); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]int)
+	for i, rule := range rules {
+		if strings.TrimSpace(rule.Role) == "" {
+			return nil, fmt.Errorf("role rule %d must have a role", i+1)
+		}
+		if strings.TrimSpace(rule.RoleID) == "" {
+			return nil, fmt.Errorf("role rule %d must have a roleId", i+1)
+		}
+		key := strings.ToLower(strings.TrimSpace(rule.Org)) + "/" + strings.ToLower(strings.TrimSpace(rule.Role))
+		if j, ok := seen[key]; ok {
+			return nil, fmt.Errorf("role rule %d duplicates role rule %d", i+1, j)
+		}
+		seen[key] = i + 1
+	}
+	return &RoleMapping{rules: rules, rejectUnmapped: unmapped == UnmappedRolesReject}, nil
+}
+
+// ReadRoleMapping reads and validates the role rules of a JSON role mapping file.
+func ReadRoleMapping(r io.Reader, unmapped string) (*RoleMapping, error) {
+	var config RoleConfig
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, err
+	}
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("role mapping file has no rules")
+	}
+	return NewRoleMapping(config.Rules, unmapped)
+}
+
+// matches checks whether the rule applies to the role of the membership state.
+// A rule scoped to an Org never applies to a Group membership.
+func (r RoleRule) matches(state MembershipState, scoped bool) bool {
+	role := strings.TrimSpace(r.Role)
+	if role != state.RoleID && (state.RoleName == "" || !strings.EqualFold(role, state.RoleName)) {
+		return false
+	}
+	if !scoped {
+		return strings.TrimSpace(r.Org) == ""
+	}
+	org := strings.TrimSpace(r.Org)
+	if org == "" || state.Type != OrgMembershipType {
+		return false
+	}
+	return org == state.OrgID || (state.OrgName != "" && strings.EqualFold(org, state.OrgName))
+}
+
+// translate returns the membership state with the role of the first rule scoped to its Org, or else of the first unscoped rule.
+// It returns false if the role has no rule and the RoleMapping rejects unmapped roles.
+// A nil RoleMapping leaves the membership state unchanged.
+func (rm *RoleMapping) translate(state MembershipState) (MembershipState, bool) {
+	if rm == nil {
+		return state, true
+	}
+	for _, scoped := range []bool{true, false} {
+		for _, rule := range rm.rules {
+			if rule.matches(state, scoped) {
+				state.RoleID = strings.TrimSpace(rule.RoleID)
+				state.RoleName = strings.TrimSpace(rule.RoleName)
+				return state, true
+			}
+		}
+	}
+	return state, !rm.rejectUnmapped
+}
+
+// mapRoles translates the roles of the desired memberships of a User.
+// The desired memberships of a rejected role are skipped, and the current memberships on their Group or Org are removed
+// from the current memberships so that they are neither updated nor deleted.
+func mapRoles(desired, current []MembershipState, rm *RoleMapping) ([]MembershipState, []MembershipState, []SkippedMembership) {
+	if rm == nil {
+		return desired, current, nil
+	}
+	var skipped []SkippedMembership
+	rejectedKeys := make(map[string]bool)
+	mapped := make([]MembershipState, 0, len(desired))
+	for _, d := range desired {
+		state, ok := rm.translate(d)
+		if !ok {
+			skipped = append(skipped, SkippedMembership{MembershipState: d, Reason: "role is not mapped"})
+			rejectedKeys[membershipKey(d)] = true
+			continue
+		}
+		mapped = append(mapped, state)
+	}
+	if len(rejectedKeys) == 0 {
+		return mapped, current, nil
+	}
+	untouched := make([]MembershipState, 0, len(current))
+	for _, c := range current {
+		if !rejectedKeys[membershipKey(c)] {
+			untouched = append(untouched, c)
+		}
+	}
+	return mapped, untouched, skipped
+}
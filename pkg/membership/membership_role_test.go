@@ -0,0 +1,122 @@
+package membership
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleMapping(t *testing.T) {
+	rules := []RoleRule{
+		{Role: "Org Admin", RoleID: "role-collaborator", RoleName: "Org Collaborator"},
+		{Role: "role-admin", Org: "Payments", RoleID: "role-viewer", RoleName: "Org Viewer"},
+		{Role: "role-group-admin", RoleID: "role-group-member", RoleName: "Group Member"},
+	}
+
+	t.Run("translates roles by ID or name, Org scoped rules first", func(t *testing.T) {
+		rm, err := NewRoleMapping(rules, UnmappedRolesPassthrough)
+		assert.NoError(t, err)
+
+		state, ok := rm.translate(toMembershipState(makeOrgMembership("om-1", "org-1", "Org One", "role-admin", "org admin")))
+		assert.True(t, ok)
+		assert.Equal(t, "role-collaborator", state.RoleID)
+		assert.Equal(t, "Org Collaborator", state.RoleName)
+		assert.Equal(t, "org-1", state.OrgID)
+
+		state, ok = rm.translate(toMembershipState(makeOrgMembership("om-2", "org-2", "payments", "role-admin", "Org Admin")))
+		assert.True(t, ok)
+		assert.Equal(t, "role-viewer", state.RoleID)
+
+		state, ok = rm.translate(toMembershipState(makeGroupMembership("gm-1", "group-id", "Group", "role-group-admin", "Group Admin")))
+		assert.True(t, ok)
+		assert.Equal(t, "role-group-member", state.RoleID)
+
+		state, ok = rm.translate(toMembershipState(makeOrgMembership("om-3", "org-3", "Org Three", "role-custom", "Custom")))
+		assert.True(t, ok)
+		assert.Equal(t, "role-custom", state.RoleID)
+	})
+
+	t.Run("rejects unmapped roles", func(t *testing.T) {
+		rm, err := NewRoleMapping(rules, UnmappedRolesReject)
+		assert.NoError(t, err)
+
+		_, ok := rm.translate(toMembershipState(makeOrgMembership("om-3", "org-3", "Org Three", "role-custom", "Custom")))
+		assert.False(t, ok)
+	})
+
+	t.Run("nil role mapping leaves the role unchanged", func(t *testing.T) {
+		var rm *RoleMapping
+		state, ok := rm.translate(toMembershipState(makeOrgMembership("om-1", "org-1", "Org One", "role-admin", "Org Admin")))
+		assert.True(t, ok)
+		assert.Equal(t, "role-admin", state.RoleID)
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		_, err := NewRoleMapping([]RoleRule{{RoleID: "role-viewer"}}, "")
+		assert.EqualError(t, err, "role rule 1 must have a role")
+		_, err = NewRoleMapping([]RoleRule{{Role: "Org Admin"}}, "")
+		assert.EqualError(t, err, "role rule 1 must have a roleId")
+		_, err = NewRoleMapping([]RoleRule{{Role: "Org Admin", RoleID: "role-viewer"}, {Role: "org admin", RoleID: "role-collaborator"}}, "")
+		assert.EqualError(t, err, "role rule 2 duplicates role rule 1")
+		_, err = NewRoleMapping(rules, "drop")
+		assert.EqualError(t, err, "unmappedRoles must be one of passthrough or reject: drop")
+	})
+}
+
+func TestReadRoleMapping(t *testing.T) {
+	rm, err := ReadRoleMapping(strings.NewReader(`{"rules": [{"role": "Org Admin", "org": "org-1", "roleId": "role-viewer"}]}`), UnmappedRolesReject)
+	assert.NoError(t, err)
+	state, ok := rm.translate(toMembershipState(makeOrgMembership("om-1", "org-1", "Org One", "role-admin", "Org Admin")))
+	assert.True(t, ok)
+	assert.Equal(t, "role-viewer", state.RoleID)
+
+	_, err = ReadRoleMapping(strings.NewReader(`{"rules": []}`), "")
+	assert.EqualError(t, err, "role mapping file has no rules")
+
+	_, err = ReadRoleMapping(strings.NewReader(`{"rules": [{"role": "Org Admin", "destination": "x"}]}`), "")
+	assert.Error(t, err)
+}
+
+func TestPlanMemberships_RoleMapping(t *testing.T) {
+	groupID := "group-id"
+	uAttributes := provisionedUserAttributes{
+		id:                  stringPtr("src-user-id"),
+		provisionedID:       stringPtr("dst-user-id"),
+		provisionedUserName: stringPtr("john.doe@sso.example.com"),
+		groupMemberships: &UserGroupMemberships{Data: []Membership{
+			makeGroupMembership("gm-src-1", groupID, "Group", "role-group-admin", "Group Admin"),
+		}},
+		orgMemberships: &UserOrgMemberships{Data: []Membership{
+			makeOrgMembership("om-src-1", "org-1", "Org One", "role-admin", "Org Admin"),
+			makeOrgMembership("om-src-2", "org-2", "Org Two", "role-custom", "Custom"),
+		}},
+		provisionedGroupMemberships: &UserGroupMemberships{},
+		provisionedOrgMemberships: &UserOrgMemberships{Data: []Membership{
+			makeOrgMembership("om-dst-2", "org-2", "Org Two", "role-viewer", "Org Viewer"),
+		}},
+	}
+	rm, err := NewRoleMapping([]RoleRule{{Role: "Org Admin", RoleID: "role-collaborator", RoleName: "Org Collaborator"}}, UnmappedRolesReject)
+	assert.NoError(t, err)
+
+	plan := buildPlan(groupID, map[string]provisionedUserAttributes{"john.doe@example.com": uAttributes},
+		SyncOptions{Mode: ModeMirror, Roles: rm})
+
+	// the Group Admin and Custom roles are rejected, the Org Two membership of the provisioned User is left untouched
+	assert.Len(t, plan.Users, 1)
+	up := plan.Users[0]
+	assert.Len(t, up.Operations, 1)
+	assert.Equal(t, ActionCreateOrgMembership, up.Operations[0].Action)
+	assert.Equal(t, "org-1", up.Operations[0].OrgID)
+	assert.Equal(t, "role-collaborator", up.Operations[0].RoleID)
+	assert.Equal(t, []SkippedMembership{
+		{MembershipState: toMembershipState(uAttributes.groupMemberships.Data[0]), Reason: "role is not mapped"},
+		{MembershipState: toMembershipState(uAttributes.orgMemberships.Data[1]), Reason: "role is not mapped"},
+	}, up.SkippedMemberships)
+
+	var b bytes.Buffer
+	assert.NoError(t, plan.Write(&b))
+	assert.Contains(t, b.String(), "  SKIP   GroupMembership, Group: Group, Role: Group Admin (role is not mapped)\n")
+	assert.Contains(t, b.String(), "  SKIP   OrgMembership, Org: Org Two, Role: Custom (role is not mapped)\n")
+}
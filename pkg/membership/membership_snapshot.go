@@ -0,0 +1,122 @@
+package membership
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+// UserSnapshot holds the memberships of a provisioned User before they were modified.
+type UserSnapshot struct {
+	Identifier  string            `json:"identifier"`
+	UserID      string            `json:"userId"`
+	Memberships []MembershipState `json:"memberships"`
+}
+
+// Snapshot holds the memberships of every provisioned User affected by a synchronization, taken before any modification.
+type Snapshot struct {
+	GroupID string         `json:"groupId"`
+	Users   []UserSnapshot `json:"users"`
+}
+
+// Snapshot returns the memberships the plan was computed against of every provisioned User the plan modifies.
+// The provisioned Users whose memberships failed to be fetched are never snapshotted.
+func (p *Plan) Snapshot() *Snapshot {
+	snapshot := &Snapshot{GroupID: p.GroupID, Users: []UserSnapshot{}}
+	for _, up := range p.Users {
+		// unread memberships of the provisioned User would be restored as none
+		if len(up.Operations) == 0 || up.destinationUnread() {
+			continue
+		}
+		snapshot.Users = append(snapshot.Users, UserSnapshot{
+			Identifier:  up.DestinationIdentifier,
+			UserID:      up.DestinationUserID,
+			Memberships: up.DestinationMemberships,
+		})
+	}
+	return snapshot
+}
+
+// WriteJSON writes the machine-readable Snapshot.
+func (s *Snapshot) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// ReadSnapshot reads a machine-readable Snapshot.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.GroupID == "" {
+		return nil, fmt.Errorf("snapshot has no groupId")
+	}
+	return &snapshot, nil
+}
+
+// writeSnapshotFile persists the memberships of the provisioned Users the plan modifies.
+func writeSnapshotFile(filePath string, plan *Plan, logger *zerolog.Logger) error {
+	snapshot := plan.Snapshot()
+	if len(snapshot.Users) == 0 {
+		logger.Info().Msg("No memberships to modify, no snapshot written")
+		return nil
+	}
+	return snapshot.writeFile(filePath, logger)
+}
+
+// writeFile persists the Snapshot to a file.
+func (s *Snapshot) writeFile(filePath string, logger *zerolog.Logger) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		logger.Error().Err(err).Msg(fmt.Sprintf("Failed to create snapshot file: %s", filePath))
+		return err
+	}
+	defer file.Close()
+
+	if err := s.WriteJSON(file); err != nil {
+		logger.Error().Err(err).Msg(fmt.Sprintf("Failed to write snapshot file: %s", filePath))
+		return err
+	}
+	logger.Info().Msg(fmt.Sprintf("Wrote snapshot of memberships of %d Users to: %s", len(s.Users), filePath))
+	return nil
+}
+
+// PlanRollback computes the Operations restoring exactly the memberships of every User of the snapshot.
+func (m *Client) PlanRollback(snapshot *Snapshot, logger *zerolog.Logger) (*Plan, error) {
+	plan := &Plan{GroupID: snapshot.GroupID}
+	for _, us := range snapshot.Users {
+		live, err := m.getUserMembershipStates(snapshot.GroupID, us.UserID)
+		if err != nil {
+			logger.Error().Msg(fmt.Sprintf("Failed to get memberships of User: username: %s", us.Identifier))
+			return nil, err
+		}
+		plan.Users = append(plan.Users, UserPlan{
+			SourceIdentifier:       us.Identifier,
+			SourceUserID:           us.UserID,
+			DestinationIdentifier:  us.Identifier,
+			DestinationUserID:      us.UserID,
+			Operations:             diffMemberships(us.Memberships, live, SyncOptions{Mode: ModeMirror}),
+			DestinationMemberships: live,
+		})
+	}
+	return plan, nil
+}
+
+// RollbackMemberships restores the memberships of every User of the snapshot.
+// It returns an error if any membership failed to be restored.
+func (m *Client) RollbackMemberships(snapshot *Snapshot, logger *zerolog.Logger) error {
+	plan, err := m.PlanRollback(snapshot, logger)
+	if err != nil {
+		return err
+	}
+	report, err := m.applyPlan(plan, SyncOptions{Mode: ModeMirror, Concurrency: 1}, nil, logger)
+	if err != nil {
+		return err
+	}
+	return report.Err()
+}
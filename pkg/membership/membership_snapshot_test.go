@@ -0,0 +1,193 @@
+package membership
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPlanSnapshot(t *testing.T) {
+	memberships := []MembershipState{
+		{ID: "om-1", Type: OrgMembershipType, OrgID: "org-1", RoleID: "role-admin"},
+	}
+	plan := &Plan{
+		GroupID: "group-id",
+		Users: []UserPlan{
+			{
+				DestinationIdentifier:  "user1",
+				DestinationUserID:      "dst-1",
+				Operations:             []Operation{{Action: ActionDeleteOrgMembership, OrgID: "org-1", MembershipID: "om-1"}},
+				DestinationMemberships: memberships,
+			},
+			{
+				DestinationIdentifier:  "user2",
+				DestinationUserID:      "dst-2",
+				DestinationMemberships: []MembershipState{},
+			},
+			{
+				DestinationIdentifier: "user3",
+				DestinationUserID:     "dst-3",
+				Operations:            []Operation{{Action: ActionCreateOrgMembership, OrgID: "org-1", RoleID: "role-admin"}},
+				Unread:                []string{UnreadDestinationOrgMemberships},
+			},
+		},
+	}
+
+	snapshot := plan.Snapshot()
+	assert.Equal(t, &Snapshot{
+		GroupID: "group-id",
+		Users:   []UserSnapshot{{Identifier: "user1", UserID: "dst-1", Memberships: memberships}},
+	}, snapshot)
+
+	var buf bytes.Buffer
+	assert.NoError(t, snapshot.WriteJSON(&buf))
+	readSnapshot, err := ReadSnapshot(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, readSnapshot)
+}
+
+func TestReadSnapshot_Invalid(t *testing.T) {
+	_, err := ReadSnapshot(bytes.NewBufferString("invalid json"))
+	assert.Error(t, err)
+
+	_, err = ReadSnapshot(bytes.NewBufferString(`{"users":[]}`))
+	assert.EqualError(t, err, "snapshot has no groupId")
+}
+
+func TestWriteSnapshotFile(t *testing.T) {
+	logger := zerolog.Nop()
+	filePath := filepath.Join(t.TempDir(), "snapshot.json")
+
+	t.Run("no snapshot without modified User", func(t *testing.T) {
+		err := writeSnapshotFile(filePath, &Plan{GroupID: "group-id", Users: []UserPlan{{DestinationUserID: "dst-1"}}}, &logger)
+		assert.NoError(t, err)
+		_, err = os.Stat(filePath)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("snapshot of modified User", func(t *testing.T) {
+		plan := &Plan{GroupID: "group-id", Users: []UserPlan{{
+			DestinationUserID: "dst-1",
+			Operations:        []Operation{{Action: ActionCreateOrgMembership, OrgID: "org-1"}},
+		}}}
+		assert.NoError(t, writeSnapshotFile(filePath, plan, &logger))
+
+		file, err := os.Open(filePath)
+		assert.NoError(t, err)
+		defer file.Close()
+		snapshot, err := ReadSnapshot(file)
+		assert.NoError(t, err)
+		assert.Len(t, snapshot.Users, 1)
+	})
+}
+
+func TestPlanRollback(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "group-id"
+	userID := "dst-1"
+	groupMembershipsPath := fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=%s", groupID, userID)
+	orgMembershipsPath := fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=%s", groupID, userID)
+
+	snapshot := &Snapshot{
+		GroupID: groupID,
+		Users: []UserSnapshot{
+			{
+				Identifier: "user1",
+				UserID:     userID,
+				Memberships: []MembershipState{
+					{ID: "gm-1", Type: GroupMembershipType, GroupID: groupID, RoleID: "role-viewer"},
+					{ID: "om-1", Type: OrgMembershipType, OrgID: "org-1", RoleID: "role-admin"},
+				},
+			},
+		},
+	}
+
+	t.Run("restores deleted, changed and created memberships", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+
+		mockClient.On("Get", groupMembershipsPath).Return(mockMembershipsResponse(makeGroupMembership("gm-1", groupID, "Group", "role-member", "Group Member")), nil)
+		mockClient.On("Get", orgMembershipsPath).Return(mockMembershipsResponse(makeOrgMembership("om-2", "org-2", "Org Two", "role-admin", "Org Admin")), nil)
+
+		plan, err := m.PlanRollback(snapshot, &logger)
+		assert.NoError(t, err)
+		assert.Len(t, plan.Users, 1)
+
+		var actions []string
+		for _, op := range plan.Users[0].Operations {
+			actions = append(actions, op.Action+":"+op.GroupID+op.OrgID)
+		}
+		assert.Equal(t, []string{
+			ActionUpdateGroupMembershipRole + ":" + groupID,
+			ActionCreateOrgMembership + ":org-1",
+			ActionDeleteOrgMembership + ":org-2",
+		}, actions)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("restores the provisioned Group membership", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+
+		mockClient.On("Get", groupMembershipsPath).Return(mockMembershipsResponse(), nil)
+		mockClient.On("Get", orgMembershipsPath).Return(mockMembershipsResponse(makeOrgMembership("om-1", "org-1", "Org One", "role-admin", "Org Admin")), nil)
+
+		plan, err := m.PlanRollback(snapshot, &logger)
+		assert.NoError(t, err)
+		assert.Len(t, plan.Users[0].Operations, 1)
+		assert.Equal(t, ActionCreateGroupMembership, plan.Users[0].Operations[0].Action)
+	})
+
+	t.Run("deletes memberships absent of the snapshot", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+
+		mockClient.On("Get", groupMembershipsPath).Return(mockMembershipsResponse(makeGroupMembership("gm-2", groupID, "Group", "role-viewer", "Group Viewer")), nil)
+		mockClient.On("Get", orgMembershipsPath).Return(mockMembershipsResponse(), nil)
+
+		plan, err := m.PlanRollback(&Snapshot{GroupID: groupID, Users: []UserSnapshot{{Identifier: "user1", UserID: userID, Memberships: []MembershipState{}}}}, &logger)
+		assert.NoError(t, err)
+		assert.Equal(t, []Operation{
+			{Action: ActionDeleteGroupMembership, Method: "DELETE", Path: "/rest/groups/group-id/memberships/gm-2", GroupID: groupID, GroupName: "Group", MembershipID: "gm-2", RoleID: "role-viewer", RoleName: "Group Viewer"},
+		}, plan.Users[0].Operations)
+	})
+
+	t.Run("returns fetch error", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+
+		mockClient.On("Get", groupMembershipsPath).Return([]byte{}, errors.New("api error"))
+
+		_, err := m.PlanRollback(snapshot, &logger)
+		assert.EqualError(t, err, "api error")
+	})
+}
+
+func TestRollbackMemberships(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "group-id"
+	userID := "dst-1"
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=%s", groupID, userID)).Return(mockMembershipsResponse(), nil)
+	mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=%s", groupID, userID)).Return(mockMembershipsResponse(makeOrgMembership("om-2", "org-2", "Org Two", "role-admin", "Org Admin")), nil)
+	mockClient.On("Post", "/rest/orgs/org-1/memberships", mock.Anything).Return([]byte(`{"data":{"id":"om-3"}}`), nil)
+	mockClient.On("Delete", "/rest/orgs/org-2/memberships/om-2").Return([]byte{}, nil)
+
+	snapshot := &Snapshot{GroupID: groupID, Users: []UserSnapshot{{
+		Identifier:  "user1",
+		UserID:      userID,
+		Memberships: []MembershipState{{ID: "om-1", Type: OrgMembershipType, OrgID: "org-1", RoleID: "role-admin"}},
+	}}}
+	assert.NoError(t, m.RollbackMemberships(snapshot, &logger))
+	mockClient.AssertExpectations(t)
+}
@@ -6,19 +6,22 @@ import (
 	"strings"
 
 	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/client"
 	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
 )
 
 type provisionedUserAttributes struct {
-	id                           *string
-	userName                     *string
-	groupMembershipID            *string
-	groupMemberships             *UserGroupMemberships
-	orgMemberships               *UserOrgMemberships
-	provisionedID                *string
-	provisionedUserName          *string
-	provisionedEmail             *string
-	provisionedGroupMembershipID *string
+	id                          *string
+	userName                    *string
+	groupMemberships            *UserGroupMemberships
+	orgMemberships              *UserOrgMemberships
+	provisionedID               *string
+	provisionedUserName         *string
+	provisionedEmail            *string
+	provisionedGroupMemberships *UserGroupMemberships
+	provisionedOrgMemberships   *UserOrgMemberships
+	// skippedMemberships lists the memberships of the pre-migrated User dropped before planning, e.g. of an Org without a mapping
+	skippedMemberships []SkippedMembership
 }
 
 // matchToUserProperty checks user properties against the local part or provisioned email based on matchToLocalPart flag.
@@ -75,220 +78,460 @@ func matchSourceDomainUser(u sso.User, domain, ssoDomain string, matchByUserName
 	return true
 }
 
-// Builds a map of the previous domain email of User to its corresponding current provisioned ssoDomain User entity containing its Memberships
-func (m *Client) mapProvisionedUsersAttributes(groupID, domain, ssoDomain string, users sso.Users, matchByUserName, matchToLocalPart bool, logger *zerolog.Logger) (int32, *map[string]provisionedUserAttributes) {
-	provisionedUserAttributesMap := make(map[string]provisionedUserAttributes)
+// matchSourceUser checks if a user should be treated as a "source" user on any of the domains of the pre-migrated Users,
+// each domain being paired with the domain of its provisioned Users.
+func matchSourceUser(u sso.User, match MatchOptions) bool {
+	for _, domain := range match.Domains {
+		if matchSourceDomainUser(u, domain, match.ssoDomainOf(domain), match.MatchByUserName, match.MatchToLocalPart) {
+			return true
+		}
+	}
+	return false
+}
 
-	for _, u := range users.Data {
-		if matchSourceDomainUser(u, domain, ssoDomain, matchByUserName, matchToLocalPart) {
-			userID := *u.ID
-			groupMemberships, err := m.getUserGroupMemberships(groupID, userID)
-			if err != nil || groupMemberships == nil || len(groupMemberships.Data) == 0 {
-				logger.Info().Msg(fmt.Sprintf("No existent Group membership found for user: %s", *u.Attributes.Email))
-				logger.Warn().Msg(err.Error())
-			}
+// userKeyIdentifier returns the identifier of a pre-migrated User, its username if matchByUserName is true, otherwise its email.
+func userKeyIdentifier(u sso.User, matchByUserName bool) string {
+	if u.Attributes == nil {
+		return ""
+	}
+	if matchByUserName && u.Attributes.UserName != nil {
+		return *u.Attributes.UserName
+	} else if !matchByUserName && u.Attributes.Email != nil {
+		return *u.Attributes.Email
+	}
+	return ""
+}
 
-			orgMemberships, err := m.getUserOrgMembershipsOfGroup(groupID, userID)
-			if err != nil {
-				logger.Info().Msg(fmt.Sprintf("No existent Org membership found for user: %s", *u.Attributes.Email))
-				logger.Warn().Msg(err.Error())
-			}
+// mapSourceUserAttributes fetches the Group and Org memberships of a pre-migrated User, returning its key identifier.
+// The memberships failing to be fetched are left nil so that the memberships of its provisioned User are left untouched.
+func (m *Client) mapSourceUserAttributes(groupID string, u sso.User, matchByUserName bool, logger *zerolog.Logger) (string, provisionedUserAttributes) {
+	userID := *u.ID
+	prevKeyIdentifier := userKeyIdentifier(u, matchByUserName)
+	groupMembershipCount, orgMembershipCount := 0, 0
+	groupMemberships, err := m.getUserGroupMemberships(groupID, userID)
+	if err != nil {
+		logger.Warn().Msg(fmt.Sprintf("Failed to get Group memberships of User: %s, %s", prevKeyIdentifier, err.Error()))
+	} else if groupMembershipCount = len(groupMemberships.Data); groupMembershipCount == 0 {
+		logger.Info().Msg(fmt.Sprintf("No existent Group membership found for user: %s", prevKeyIdentifier))
+	}
 
-			var prevKeyIdentifier string
-			if matchByUserName {
-				prevKeyIdentifier = *u.Attributes.UserName
-			} else {
-				prevKeyIdentifier = *u.Attributes.Email
-			}
-			// logging some stats
-			logger.Info().Msg(fmt.Sprintf("Found UserKeyIdentifier: %s, GroupMemberships: %d, OrgMemberships: %d", prevKeyIdentifier, len(groupMemberships.Data), len(orgMemberships.Data)))
-
-			provisionedUserAttributesMap[prevKeyIdentifier] = provisionedUserAttributes{
-				id:                u.ID,
-				userName:          u.Attributes.UserName,
-				groupMembershipID: groupMemberships.Data[0].ID,
-				groupMemberships:  groupMemberships,
-				orgMemberships:    orgMemberships,
-			}
+	orgMemberships, err := m.getUserOrgMembershipsOfGroup(groupID, userID)
+	if err != nil {
+		logger.Warn().Msg(fmt.Sprintf("Failed to get Org memberships of User: %s, %s", prevKeyIdentifier, err.Error()))
+	} else if orgMembershipCount = len(orgMemberships.Data); orgMembershipCount == 0 {
+		logger.Info().Msg(fmt.Sprintf("No existent Org membership found for user: %s", prevKeyIdentifier))
+	}
+
+	// logging some stats
+	logger.Info().Msg(fmt.Sprintf("Found UserKeyIdentifier: %s, GroupMemberships: %d, OrgMemberships: %d", prevKeyIdentifier, groupMembershipCount, orgMembershipCount))
+
+	return prevKeyIdentifier, provisionedUserAttributes{
+		id:               u.ID,
+		userName:         u.Attributes.UserName,
+		groupMemberships: groupMemberships,
+		orgMemberships:   orgMemberships,
+	}
+}
+
+// fetchProvisionedUserAttributes fetches the memberships of the provisioned User of a pre-migrated User.
+func (m *Client) fetchProvisionedUserAttributes(groupID string, uAttributes provisionedUserAttributes, u sso.User, provisionedEmail string, logger *zerolog.Logger) provisionedUserAttributes {
+	// get the GroupMembership of provisioned User to update
+	pGroupMemberships, err := m.getUserGroupMemberships(groupID, *u.ID)
+	if err == nil {
+		uAttributes.provisionedGroupMemberships = pGroupMemberships
+	} else {
+		logger.Info().Msg(fmt.Sprintf("No existent Group membership found for User: username: %s", *u.Attributes.UserName))
+		logger.Warn().Msg(err.Error())
+	}
+
+	// get the OrgMemberships of provisioned User to be reconciled
+	pOrgMemberships, err := m.getUserOrgMembershipsOfGroup(groupID, *u.ID)
+	if err != nil {
+		logger.Info().Msg(fmt.Sprintf("Failed to get org memberships of User: username: %s", *u.Attributes.UserName))
+		logger.Error().Msg(err.Error())
+	}
+	uAttributes.provisionedOrgMemberships = pOrgMemberships
+	uAttributes.provisionedEmail = &provisionedEmail
+	uAttributes.provisionedUserName = u.Attributes.UserName
+	uAttributes.provisionedID = u.ID
+	return uAttributes
+}
+
+// provisionedIdentifiers derives the local part and the email on the ssoDomain of the provisioned User of a pre-migrated User,
+// the ssoDomain being the one mapped to the domain of the pre-migrated User if any.
+// It rewrites the local part through the transform rules and also returns the steps of the transform rules.
+func provisionedIdentifiers(prevKeyID string, match MatchOptions) (string, string, []TransformStep) {
+	localPart, steps := match.Transforms.Apply(IdentifierLocalPart(prevKeyID))
+	domain, _ := match.sourceDomain(prevKeyID)
+	return localPart, localPart + "@" + match.ssoDomainOf(domain), steps
+}
+
+// syncScope is the Groups and the SSO Users of the pre-migrated and of the provisioned Users,
+// both are the same unless the memberships are migrated from a Group to another.
+type syncScope struct {
+	sourceGroupID      string
+	destinationGroupID string
+	sourceUsers        sso.Users
+	destinationUsers   sso.Users
+	// migration translates the memberships of the source Group into the destination Group, nil within a single Group
+	migration *GroupMigration
+}
+
+// groupScope returns the scope of a synchronization of the Users of a single Group.
+func groupScope(groupID string, users sso.Users) syncScope {
+	return syncScope{
+		sourceGroupID:      groupID,
+		destinationGroupID: groupID,
+		sourceUsers:        users,
+		destinationUsers:   users,
+	}
+}
+
+// connectionsScope is the scope of the pre-migrated and the provisioned Users of two SSO connections of a single Group.
+func connectionsScope(groupID string, sourceUsers, destinationUsers sso.Users) syncScope {
+	return syncScope{
+		sourceGroupID:      groupID,
+		destinationGroupID: groupID,
+		sourceUsers:        sourceUsers,
+		destinationUsers:   destinationUsers,
+	}
+}
+
+// Builds a map of the previous domain email of User to its corresponding current provisioned ssoDomain User entity containing its Memberships
+// The memberships of up to concurrency Users are fetched in parallel.
+// Ambiguous User pairs are not mapped to a provisioned User, they are returned as conflicts.
+func (m *Client) mapProvisionedUsersAttributes(scope syncScope, match MatchOptions, concurrency int, logger *zerolog.Logger) (int32, *map[string]provisionedUserAttributes, []Conflict) {
+	if match.usesMapping() {
+		return m.mapMappedUsersAttributes(scope, match, concurrency, logger)
+	}
+
+	provisionedUserAttributesMap := make(map[string]provisionedUserAttributes)
+	matchByUserName, matchToLocalPart := match.MatchByUserName, match.MatchToLocalPart
+
+	var sourceUsers []sso.User
+	for _, u := range scope.sourceUsers.Data {
+		if matchSourceUser(u, match) {
+			sourceUsers = append(sourceUsers, u)
 		}
 	}
+	prevKeyIDs := make([]string, len(sourceUsers))
+	sourceAttributes := make([]provisionedUserAttributes, len(sourceUsers))
+	forEachOrdered(len(sourceUsers), concurrency, logger, func(i int, logger *zerolog.Logger) {
+		prevKeyIDs[i], sourceAttributes[i] = m.mapSourceUserAttributes(scope.sourceGroupID, sourceUsers[i], matchByUserName, logger)
+	})
+	for i, prevKeyID := range prevKeyIDs {
+		provisionedUserAttributesMap[prevKeyID] = sourceAttributes[i]
+	}
 
-	var count int32
-	// populate provisioned User ID, UserName and Email on the ssoDomain
-	for prevKeyID, uAttributes := range provisionedUserAttributesMap {
-		emailParts := strings.Split(prevKeyID, "@")
-		localPart := emailParts[0]
-		provisionedEmail := localPart + "@" + ssoDomain
-
-		for _, u := range users.Data {
-			if matchToUserProperty(u, localPart, provisionedEmail, matchToLocalPart) {
-				if matchToLocalPart && u.Attributes.UserName != nil {
-					logger.Info().Msg(fmt.Sprintf("Matched %s -> User: username: %s", prevKeyID, *u.Attributes.UserName))
-				} else {
-					logger.Info().Msg(fmt.Sprintf("Matched %s -> User: email:  %s", prevKeyID, provisionedEmail))
-				}
-
-				// get the GroupMembership of provisioned User to update
-				pGroupMemberships, err := m.getUserGroupMemberships(groupID, *u.ID)
-				if err == nil && pGroupMemberships != nil && len(pGroupMemberships.Data) > 0 {
-					uAttributes.provisionedGroupMembershipID = pGroupMemberships.Data[0].ID
-				} else if err != nil {
-					logger.Info().Msg(fmt.Sprintf("No existent Group membership found for User: username: %s", *u.Attributes.UserName))
-					logger.Warn().Msg(err.Error())
-				}
-				uAttributes.provisionedEmail = &provisionedEmail
-				uAttributes.provisionedUserName = u.Attributes.UserName
-				uAttributes.provisionedID = u.ID
-				provisionedUserAttributesMap[prevKeyID] = uAttributes
-				count++
-				break
-			}
+	// populate provisioned User ID, UserName and Email on the ssoDomain of the unambiguous User pairs only
+	pairs, conflicts := pairProvisionedUsers(sourceUsers, scope.destinationUsers, match)
+	for _, c := range conflicts {
+		logger.Warn().Msg(fmt.Sprintf("Skipping Users of %s", c.String()))
+		for _, prevKeyID := range c.SourceIdentifiers {
+			delete(provisionedUserAttributesMap, prevKeyID)
+		}
+	}
+	provisionedAttributes := make([]provisionedUserAttributes, len(pairs))
+	forEachOrdered(len(pairs), concurrency, logger, func(i int, logger *zerolog.Logger) {
+		pair := pairs[i]
+		prevKeyID := pair.mapping.SourceIdentifier
+		_, provisionedEmail, _ := provisionedIdentifiers(prevKeyID, match)
+		if isOverride(pair, match.Overrides) {
+			logger.Info().Msg(fmt.Sprintf("Overridden %s -> User: %s", prevKeyID, pair.mapping.DestinationIdentifier))
+			provisionedEmail = attribute(pair.destination.Attributes.Email)
+		} else if matchToLocalPart {
+			logger.Info().Msg(fmt.Sprintf("Matched %s -> User: username: %s", prevKeyID, pair.mapping.DestinationIdentifier))
+		} else {
+			logger.Info().Msg(fmt.Sprintf("Matched %s -> User: email:  %s", prevKeyID, pair.mapping.DestinationIdentifier))
 		}
+		provisionedAttributes[i] = m.fetchProvisionedUserAttributes(scope.destinationGroupID, provisionedUserAttributesMap[prevKeyID], pair.destination, provisionedEmail, logger)
+	})
+
+	for i, pair := range pairs {
+		provisionedUserAttributesMap[pair.mapping.SourceIdentifier] = provisionedAttributes[i]
 	}
 
-	return count, &provisionedUserAttributesMap
+	return int32(len(pairs)), &provisionedUserAttributesMap, conflicts
 }
 
-// A provisioned User is provisioned with a default Group membership based on the Login strategy at SSO settings
-// this function will update this provisioned membership to be similar to the pre-migration User
-func (m *Client) updateUserGroupMembership(uAttributes *provisionedUserAttributes, logger *zerolog.Logger) {
-	if uAttributes.groupMemberships != nil && len(uAttributes.groupMemberships.Data) > 0 && uAttributes.provisionedGroupMembershipID != nil {
-		gm := uAttributes.groupMemberships.Data[0]
-		groupID := gm.Relationship.Group.Data.ID
-		groupName := *gm.Relationship.Group.Data.Attributes.Name
-
-		err := m.updateRoleAtUserGroupMembership(*groupID, *uAttributes.provisionedGroupMembershipID, gm)
+// applyUserOperation issues a planned Operation of a User pair, logging its outcome.
+// A 409 Conflict of an already existing membership is not a failure: the Operation is unchanged if the membership
+// already has the planned role, otherwise the role of the existing membership is updated if the mode of opts allows it.
+func (m *Client) applyUserOperation(up *UserPlan, op Operation, opts SyncOptions, logger *zerolog.Logger) OperationResult {
+	err := m.applyOperation(op, up.DestinationUserID)
+	switch op.Action {
+	case ActionUpdateGroupMembershipRole:
+		if err != nil {
+			// make it idempotent by reconciling the role of the Group membership the User already holds
+			if client.IsConflict(err) {
+				return m.resolveMembershipConflict(up, op, err, opts, logger)
+			}
+			logger.Info().Msg(fmt.Sprintf("Failed to update GroupMembership of User: username: %s, Group: %s", up.DestinationIdentifier, op.GroupName))
+			logger.Error().Msg(err.Error())
+		} else {
+			logger.Info().Msg(fmt.Sprintf("Updated GroupMembership of User: username: %s, Group: %s", up.DestinationIdentifier, op.GroupName))
+		}
+	case ActionCreateGroupMembership:
+		if err != nil {
+			// make it idempotent by reconciling the role of the Group membership the User already holds
+			if client.IsConflict(err) {
+				return m.resolveMembershipConflict(up, op, err, opts, logger)
+			}
+			logger.Error().Msg(fmt.Sprintf("Failed to create GroupMembership of User: username: %s, Group: %s", up.DestinationIdentifier, op.GroupName))
+			logger.Error().Msg(err.Error())
+		} else {
+			logger.Info().Msg(fmt.Sprintf("Created GroupMembership of User: username: %s, Group: %s", up.DestinationIdentifier, op.GroupName))
+		}
+	case ActionDeleteGroupMembership:
+		if err != nil {
+			logger.Info().Msg(fmt.Sprintf("Failed to delete GroupMembership of User: username: %s, Group: %s", up.DestinationIdentifier, op.GroupName))
+			logger.Error().Msg(err.Error())
+		} else {
+			logger.Info().Msg(fmt.Sprintf("Deleted existing GroupMembership of User: username: %s, Group: %s", up.DestinationIdentifier, op.GroupName))
+		}
+	case ActionUpdateOrgMembershipRole:
 		if err != nil {
-			errorMessage := err.Error()
-			// make it idempotent by ignoring status code 409 Conflict - Membership already exists for the specified user error
-			if !strings.HasSuffix(errorMessage, "409") {
-				logger.Info().Msg(fmt.Sprintf("Failed to update GroupMembership of User: username: %s, Group: %s", *uAttributes.provisionedUserName, groupName))
-				logger.Error().Msg(errorMessage)
+			logger.Info().Msg(fmt.Sprintf("Failed to update OrgMembership of User: username: %s, Org: %s", up.DestinationIdentifier, op.OrgName))
+			logger.Error().Msg(err.Error())
+		} else {
+			logger.Info().Msg(fmt.Sprintf("Updated OrgMembership of User: username: %s, Org: %s", up.DestinationIdentifier, op.OrgName))
+		}
+	case ActionDeleteOrgMembership:
+		if err != nil {
+			logger.Info().Msg(fmt.Sprintf("Failed to delete OrgMembership of User: username: %s, Org: %s", up.DestinationIdentifier, op.OrgName))
+			logger.Error().Msg(err.Error())
+		} else {
+			logger.Info().Msg(fmt.Sprintf("Deleted existing OrgMembership of User: username: %s, Org: %s", up.DestinationIdentifier, op.OrgName))
+		}
+	case ActionCreateOrgMembership:
+		if err != nil {
+			// make it idempotent by reconciling the role of the Org membership the User already holds
+			if client.IsConflict(err) {
+				return m.resolveMembershipConflict(up, op, err, opts, logger)
 			}
+			logger.Error().Msg(fmt.Sprintf("Failed to create OrgMembership of User: username: %s, Org: %s", up.DestinationIdentifier, op.OrgName))
+			logger.Error().Msg(err.Error())
 		} else {
-			logger.Info().Msg(fmt.Sprintf("Updated GroupMembership of User: username: %s, Group: %s", *uAttributes.provisionedUserName, groupName))
+			logger.Info().Msg(fmt.Sprintf("Created OrgMembership of User: username: %s, Org: %s", up.DestinationIdentifier, op.OrgName))
+		}
+	default:
+		if err != nil {
+			logger.Error().Msg(err.Error())
 		}
 	}
-}
-
-// Deletes the current provisioned ssoDomain User org memberships
-func (m *Client) deleteUserOrgMembership(groupID, userID, userIdentifier string, logger *zerolog.Logger) error {
-	// get User org memberships
-	userOrgMemberships, err := m.getUserOrgMembershipsOfGroup(groupID, userID)
 	if err != nil {
-		logger.Info().Msg(fmt.Sprintf("Failed to get org memberships of User: username: %s", userIdentifier))
-		logger.Error().Msg(err.Error())
-		return err
+		return OperationResult{Operation: op, Status: StatusFailed, Error: err.Error()}
 	}
+	return OperationResult{Operation: op, Status: StatusSucceeded}
+}
 
-	// delete these memberships
-	for _, om := range userOrgMemberships.Data {
-		orgID := om.Relationship.Org.Data.ID
-		orgName := *om.Relationship.Org.Data.Attributes.Name
-		err := m.deleteOrgMembership(*orgID, *om.ID)
+// existingMemberships returns the memberships a provisioned User already holds on the Group or the Org of an Operation.
+func (m *Client) existingMemberships(op Operation, userID string) ([]MembershipState, error) {
+	if op.isGroupMembership() {
+		groupMemberships, err := m.getUserGroupMemberships(op.GroupID, userID)
 		if err != nil {
-			logger.Info().Msg(fmt.Sprintf("Failed to delete OrgMembership of User: username: %s, Org: %s", userIdentifier, orgName))
-			logger.Error().Msg(err.Error())
-		} else {
-			logger.Info().Msg(fmt.Sprintf("Deleted existing OrgMembership of User: username: %s, Org: %s", userIdentifier, orgName))
+			return nil, err
 		}
+		return toMembershipStates(groupMemberships, nil), nil
+	}
+	orgMemberships, err := m.getUserOrgMembershipsOfOrg(op.OrgID, userID)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return toMembershipStates(nil, orgMemberships), nil
 }
 
-// Synchronizes provisioned user Org memberships with corresponding Org Role of the pre-migrated user across all Orgs
-func (m *Client) syncUserOrgMemberships(groupID string, uAttributes *provisionedUserAttributes, logger *zerolog.Logger) {
-	// synchronizes by first scrubbing all provisioned user org memberships if existent
-	err := m.deleteUserOrgMembership(groupID, *uAttributes.provisionedID, *uAttributes.provisionedUserName, logger)
+// resolveMembershipConflict reconciles the Group or Org membership a provisioned User already holds although the planned Operation
+// conflicts with it: the Operation is unchanged if a membership has the planned role, or if the mode of opts does not allow
+// changing the role of the membership. Otherwise the role of the membership is updated and the update is returned as the Operation
+// applied, unless the Operation already was this update.
+func (m *Client) resolveMembershipConflict(up *UserPlan, op Operation, conflict error, opts SyncOptions, logger *zerolog.Logger) OperationResult {
+	mbrshipType, target := OrgMembershipType, nameOrID(op.OrgName, op.OrgID)
+	if op.isGroupMembership() {
+		mbrshipType, target = GroupMembershipType, nameOrID(op.GroupName, op.GroupID)
+	}
+	states, err := m.existingMemberships(op, up.DestinationUserID)
 	if err != nil {
-		logger.Warn().Msg(fmt.Sprintf("Failed to delete OrgMembership of User: username: %s", *uAttributes.provisionedUserName))
+		logger.Error().Msg(fmt.Sprintf("Failed to get the existing memberships of User: username: %s, %s, %s", up.DestinationIdentifier, target, err.Error()))
+		return OperationResult{Operation: op, Status: StatusFailed, Error: conflict.Error()}
+	}
+	if len(states) == 0 {
+		logger.Error().Msg(fmt.Sprintf("Failed to %s of User: username: %s", op.Description(), up.DestinationIdentifier))
+		logger.Error().Msg(conflict.Error())
+		return OperationResult{Operation: op, Status: StatusFailed, Error: conflict.Error()}
+	}
+	for _, state := range states {
+		if state.RoleID == op.RoleID {
+			return OperationResult{Operation: op, Status: StatusUnchanged}
+		}
+	}
+
+	existing := states[0]
+	if !opts.allowsRoleChange(existing.RoleID, existing.RoleName, op.RoleID, op.RoleName) {
+		logger.Info().Msg(fmt.Sprintf("Kept existing membership of User: username: %s, %s, Role: %s", up.DestinationIdentifier, target,
+			nameOrID(existing.RoleName, existing.RoleID)))
+		return OperationResult{Operation: op, Status: StatusUnchanged}
+	}
+	_, updateAction, _ := membershipActions(mbrshipType)
+	if op.Action == updateAction && op.MembershipID == existing.ID {
+		logger.Error().Msg(fmt.Sprintf("Failed to %s of User: username: %s", op.Description(), up.DestinationIdentifier))
+		logger.Error().Msg(conflict.Error())
+		return OperationResult{Operation: op, Status: StatusFailed, Error: conflict.Error()}
 	}
 
-	for _, om := range uAttributes.orgMemberships.Data {
-		userType := sso.TypeUser
-		pUserAttributes := &TypeIdentifierAttributes{
-			ID:   uAttributes.provisionedID,
-			Type: &userType,
+	update := membershipOperation(updateAction, MembershipState{Type: mbrshipType, GroupID: op.GroupID, GroupName: op.GroupName,
+		OrgID: op.OrgID, OrgName: op.OrgName, RoleID: op.RoleID, RoleName: op.RoleName}, existing.ID)
+	if err := m.applyOperation(update, up.DestinationUserID); err != nil {
+		logger.Info().Msg(fmt.Sprintf("Failed to update existing membership of User: username: %s, %s", up.DestinationIdentifier, target))
+		logger.Error().Msg(err.Error())
+		return OperationResult{Operation: update, Status: StatusFailed, Error: err.Error()}
+	}
+	logger.Info().Msg(fmt.Sprintf("Updated existing membership of User: username: %s, %s, Role: %s -> %s", up.DestinationIdentifier, target,
+		nameOrID(existing.RoleName, existing.RoleID), nameOrID(op.RoleName, op.RoleID)))
+	return OperationResult{Operation: update, Status: StatusSucceeded}
+}
+
+// applyUserOperations issues the planned Operations of a User pair of the Group or the Org memberships.
+// Every Operation is issued, it returns their results and the first failure.
+func (m *Client) applyUserOperations(up *UserPlan, groupMemberships bool, opts SyncOptions, logger *zerolog.Logger) ([]OperationResult, error) {
+	results := []OperationResult{}
+	var failure error
+	for _, op := range up.Operations {
+		if op.isGroupMembership() != groupMemberships {
+			continue
 		}
-		pUser := &struct {
-			Data *TypeIdentifierAttributes `json:"data"`
-		}{
-			Data: pUserAttributes,
+		result := m.applyUserOperation(up, op, opts, logger)
+		if result.Status == StatusFailed && failure == nil {
+			failure = fmt.Errorf("%s", result.Error)
 		}
+		results = append(results, result)
+	}
+	return results, failure
+}
 
-		orgMbrRelationship := MemberRelationship{
-			Org:  om.Relationship.Org,
-			Role: om.Relationship.Role,
-			User: pUser,
-		}
-		orgID := om.Relationship.Org.Data.ID
-		orgName := *om.Relationship.Org.Data.Attributes.Name
-		// recreate them again so they will match org memberships of the pre-migrated User
-		_, err = m.createUserOrgMembership(*orgID, orgMbrRelationship)
-		if err != nil {
-			errorMessage := err.Error()
-			// make it idempotent by ignoring Error status code 409 Conflict - Membership already exists for the specified user
-			if !strings.HasSuffix(errorMessage, "409") {
-				logger.Error().Msg(fmt.Sprintf("Failed to create OrgMembership of User: username: %s, Org: %s", *uAttributes.provisionedUserName, orgName))
-				logger.Error().Msg(errorMessage)
+// userStatus summarizes the results of the Operations of a User pair.
+func userStatus(ur *UserReport) string {
+	status := StatusUnchanged
+	for _, results := range [][]OperationResult{ur.GroupMemberships, ur.OrgMemberships} {
+		for _, result := range results {
+			if result.Status == StatusFailed {
+				return StatusFailed
+			}
+			if result.Status == StatusSucceeded {
+				status = StatusSucceeded
 			}
-		} else {
-			logger.Info().Msg(fmt.Sprintf("Created OrgMembership of User: username: %s, Org: %s", *uAttributes.provisionedUserName, orgName))
 		}
 	}
+	return status
 }
 
-func (m *Client) syncUserGroupMembership(uAttributes *provisionedUserAttributes, logger *zerolog.Logger) {
-	// update provisioned user group membership
-	if uAttributes.provisionedGroupMembershipID != nil {
-		m.updateUserGroupMembership(uAttributes, logger)
-	} else {
-		// otherwise recreate it again
-		userType := sso.TypeUser
-		pUserAttributes := &TypeIdentifierAttributes{
-			ID:   uAttributes.provisionedID,
-			Type: &userType,
-		}
-		pUser := &struct {
-			Data *TypeIdentifierAttributes `json:"data"`
-		}{
-			Data: pUserAttributes,
-		}
+// applyUserPlan issues the planned Operations of a User pair, the Group memberships first then the Org memberships,
+// and records the progress of the User pair in the journal.
+// It returns the report of the User pair, the error is only returned if the progress cannot be recorded.
+func (m *Client) applyUserPlan(up *UserPlan, opts SyncOptions, journal *Journal, logger *zerolog.Logger) (UserReport, error) {
+	ur := newUserReport(up)
+	if err := journal.Record(up, JournalStatusPending, nil); err != nil {
+		return ur, err
+	}
 
-		// extract the prev User groupmembership
-		gm := uAttributes.groupMemberships.Data[0]
-		groupMbrRelationship := MemberRelationship{
-			Group: gm.Relationship.Group,
-			Role:  gm.Relationship.Role,
-			User:  pUser,
+	var groupErr, orgErr error
+	ur.GroupMemberships, groupErr = m.applyUserOperations(up, true, opts, logger)
+	// the Group memberships of a User pair with unread memberships may not be synchronized
+	if groupErr == nil && len(up.Unread) == 0 {
+		if err := journal.Record(up, JournalStatusGroupSynced, nil); err != nil {
+			return ur, err
 		}
-		groupID := gm.Relationship.Group.Data.ID
-		groupName := *gm.Relationship.Group.Data.Attributes.Name
-		_, err := m.createUserGroupMembership(*groupID, groupMbrRelationship)
-		if err != nil {
-			logger.Error().Msg(fmt.Sprintf("Failed to create GroupMembership of User: username: %s, Group: %s", *uAttributes.provisionedUserName, groupName))
-		} else {
-			logger.Info().Msg(fmt.Sprintf("Created GroupMembership of User: username: %s, Group: %s", *uAttributes.provisionedUserName, groupName))
+	}
+	// the Org memberships Operations are issued even if a Group membership failed, the User pair is retried on resume
+	ur.OrgMemberships, orgErr = m.applyUserOperations(up, false, opts, logger)
+	ur.Status = userStatus(&ur)
+
+	// a User pair with unread memberships is not fully synchronized, it is retried on resume
+	if unreadErr := up.unreadError(); unreadErr != nil {
+		logger.Error().Msg(fmt.Sprintf("Failed to synchronize memberships of User: username: %s, %s", up.DestinationIdentifier, unreadErr.Error()))
+		ur.Status = StatusFailed
+		ur.Error = unreadErr.Error()
+		if groupErr == nil && orgErr == nil {
+			return ur, journal.Record(up, JournalStatusFailed, unreadErr)
 		}
 	}
+	if groupErr != nil {
+		return ur, journal.Record(up, JournalStatusFailed, groupErr)
+	}
+	if orgErr != nil {
+		return ur, journal.Record(up, JournalStatusFailed, orgErr)
+	}
+	return ur, journal.Record(up, JournalStatusOrgsSynced, nil)
 }
 
 // Synchronizes memberships of provisioned users with the corresponding SSO users
 // This will update the provisioned user Group and Org memberships to match the pre-migrated user memberships
 // It will also create the provisioned user Group and Org memberships if they do not exist
-func (m *Client) SyncMemberships(groupID, domain, ssoDomain string, users sso.Users, matchByUserName, matchToLocalPart bool, logger *zerolog.Logger) {
-	userCount, provisionedUserAttributesMap := m.mapProvisionedUsersAttributes(groupID, domain, ssoDomain, users, matchByUserName, matchToLocalPart, logger)
-	logger.Info().Msg(fmt.Sprintf("Found %d Users to synchronize", userCount))
-	var index int32
-
-	for _, uAttributes := range *provisionedUserAttributesMap {
-		if uAttributes.provisionedID != nil {
-			index++
-			logger.Info().Msg(fmt.Sprintf("Start synchronization of memberships %d/%d User: username: %s", index, userCount, *uAttributes.provisionedUserName))
-			m.syncUserGroupMembership(&uAttributes, logger)
-			m.syncUserOrgMemberships(groupID, &uAttributes, logger)
-		}
+// and, unless merging, delete the provisioned user Org memberships the pre-migrated user does not have
+// Progress is recorded per User pair in the journal of opts.JournalPath, resuming a journal skips the completed User pairs.
+// It returns the outcome of every User pair, the error is only returned if the synchronization could not run to completion.
+func (m *Client) SyncMemberships(groupID string, users sso.Users, match MatchOptions, opts SyncOptions, logger *zerolog.Logger) (*Report, error) {
+	return m.synchronize(groupScope(groupID, users), match, opts, logger)
+}
+
+// SyncConnections synchronizes the memberships of the provisioned Users of an SSO connection of the Group
+// with their pre-migrated Users of another SSO connection of the same Group.
+func (m *Client) SyncConnections(groupID string, sourceUsers, destinationUsers sso.Users, match MatchOptions, opts SyncOptions, logger *zerolog.Logger) (*Report, error) {
+	return m.synchronize(connectionsScope(groupID, sourceUsers, destinationUsers), match, opts, logger)
+}
+
+// synchronize plans and issues the membership changes of the User pairs of the scope, recording their progress in the journal
+// of the destination Group.
+func (m *Client) synchronize(scope syncScope, match MatchOptions, opts SyncOptions, logger *zerolog.Logger) (*Report, error) {
+	if err := ValidateRetireSource(opts.RetireSource, opts.SnapshotPath); err != nil {
+		logger.Error().Msg(err.Error())
+		return nil, err
+	}
+	journal, err := OpenJournal(opts.JournalPath, scope.destinationGroupID, opts.Resume)
+	if err != nil {
+		logger.Error().Err(err).Msg(fmt.Sprintf("Failed to open journal file: %s", opts.JournalPath))
+		return nil, err
+	}
+	defer journal.Close()
+
+	scope, match = resumeScope(scope, match, opts, journal, logger)
+	plan := m.planScope(scope, match, opts, logger)
+	report, err := m.executePlan(plan, opts, journal, logger)
+	if err != nil || opts.RetireSource == "" {
+		return report, err
+	}
+	// retire the pre-migrated Users only once their memberships are fully synchronized
+	return report, m.retireSources(report, scope.sourceGroupID, scope.sourceUsers, opts.RetireSource, opts.SourceConnection, opts.SnapshotPath, logger)
+}
+
+// resumeScope removes the User pairs recorded as completed in the journal from the scope when resuming.
+// When retiring the pre-migrated Users, the completed User pairs are kept so that a run interrupted before their retirement
+// retires them. Their memberships being already synchronized, only the changes made since are applied.
+func resumeScope(scope syncScope, match MatchOptions, opts SyncOptions, journal *Journal, logger *zerolog.Logger) (syncScope, MatchOptions) {
+	switch {
+	case !opts.Resume:
+	case opts.RetireSource != "":
+		logger.Info().Msg(fmt.Sprintf("Resuming journal: %s, checking the Users already synchronized for retirement", opts.JournalPath))
+	case match.usesMapping():
+		remaining := skipCompletedMapping(scope.sourceUsers, scope.destinationUsers, match.Mapping, journal, match.MatchByUserName)
+		logger.Info().Msg(fmt.Sprintf("Resuming journal: %s, skipping %d Users already synchronized", opts.JournalPath, len(match.Mapping)-len(remaining)))
+		match.Mapping = remaining
+	default:
+		remaining := skipCompletedUsers(scope.sourceUsers, journal, match.MatchByUserName)
+		logger.Info().Msg(fmt.Sprintf("Resuming journal: %s, skipping %d Users already synchronized", opts.JournalPath, len(scope.sourceUsers.Data)-len(remaining.Data)))
+		scope.sourceUsers = remaining
 	}
+	return scope, match
+}
 
-	logger.Info().Msg("End synchronization of memberships")
+// skipCompletedUsers removes the pre-migrated Users whose memberships were completely synchronized according to the journal.
+func skipCompletedUsers(users sso.Users, journal *Journal, matchByUserName bool) sso.Users {
+	remaining := sso.Users{}
+	for _, u := range users.Data {
+		if journal.Completed(userKeyIdentifier(u, matchByUserName)) {
+			continue
+		}
+		remaining.Data = append(remaining.Data, u)
+	}
+	return remaining
 }
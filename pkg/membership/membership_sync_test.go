@@ -1,10 +1,17 @@
 package membership
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
 	"testing"
 
+	"github.com/rs/zerolog"
+	"github.com/snyk-labs/snyk-sso-membership/pkg/client"
 	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 // Helper functions for pointers
@@ -264,3 +271,223 @@ func TestMatchSourceDomainUser(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchSourceUser_Domains(t *testing.T) {
+	match := MatchOptions{
+		Domains:   []string{"a.com", "b.com", "c.io"},
+		SSODomain: "new.com",
+		DomainMap: map[string]string{"c.io": "new.io"},
+	}
+
+	assert.True(t, matchSourceUser(makeUser("src-1", "alice@a.com", "alice@a.com"), match))
+	assert.True(t, matchSourceUser(makeUser("src-2", "bob@b.com", "bob@b.com"), match))
+	assert.True(t, matchSourceUser(makeUser("src-3", "carol@c.io", "carol@c.io"), match))
+	assert.False(t, matchSourceUser(makeUser("src-4", "dave@d.com", "dave@d.com"), match))
+	assert.False(t, matchSourceUser(makeUser("dst-1", "alice@new.com", "alice"), match))
+
+	_, provisionedEmail, _ := provisionedIdentifiers("bob@b.com", match)
+	assert.Equal(t, "bob@new.com", provisionedEmail)
+	_, provisionedEmail, _ = provisionedIdentifiers("carol@c.io", match)
+	assert.Equal(t, "carol@new.io", provisionedEmail)
+}
+
+func TestPlanMemberships_Domains(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "group-id"
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "alice@a.com", "alice@a.com"),
+		makeUser("src-2", "bob@b.com", "bob@b.com"),
+		makeUser("src-3", "carol@c.io", "carol@c.io"),
+		makeUser("dst-1", "alice@new.com", "alice"),
+		makeUser("dst-2", "bob@new.com", "bob"),
+		makeUser("dst-3", "carol@new.io", "carol"),
+		// not the provisioned User of carol@c.io, whose domain is mapped to new.io
+		makeUser("dst-4", "carol@new.com", "carol.new"),
+	}}
+	for _, id := range []string{"src-1", "src-2", "src-3", "dst-1", "dst-2", "dst-3"} {
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=%s", groupID, id)).
+			Return(mockMembershipsResponse(makeGroupMembership("gm-"+id, groupID, "Group", "role-member", "Group Member")), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=%s", groupID, id)).
+			Return(mockMembershipsResponse(), nil)
+	}
+
+	match := MatchOptions{
+		Domains:   []string{"a.com", "b.com", "c.io"},
+		SSODomain: "new.com",
+		DomainMap: map[string]string{"c.io": "new.io"},
+	}
+	plan := m.PlanMemberships(groupID, users, match, SyncOptions{}, &logger)
+	assert.Len(t, plan.Users, 3)
+	assert.Equal(t, "alice@a.com", plan.Users[0].SourceIdentifier)
+	assert.Equal(t, "dst-1", plan.Users[0].DestinationUserID)
+	assert.Equal(t, "bob@b.com", plan.Users[1].SourceIdentifier)
+	assert.Equal(t, "dst-2", plan.Users[1].DestinationUserID)
+	assert.Equal(t, "carol@c.io", plan.Users[2].SourceIdentifier)
+	assert.Equal(t, "dst-3", plan.Users[2].DestinationUserID)
+	assert.Empty(t, plan.Unmatched)
+	assert.Empty(t, plan.Conflicts)
+}
+
+func TestPlanMemberships_SourceUserWithoutMemberships(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "group-id"
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "alice@a.com", "alice@a.com"),
+		makeUser("src-2", "bob@a.com", "bob@a.com"),
+		makeUser("dst-1", "alice@new.com", "alice"),
+		makeUser("dst-2", "bob@new.com", "bob"),
+	}}
+	// alice has no membership, the memberships of bob fail to be fetched
+	mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=src-1", groupID)).Return(mockMembershipsResponse(), nil)
+	mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=src-1", groupID)).Return(mockMembershipsResponse(), nil)
+	mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=src-2", groupID)).Return([]byte{}, errors.New("api error"))
+	mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=src-2", groupID)).Return([]byte{}, errors.New("api error"))
+	for _, id := range []string{"dst-1", "dst-2"} {
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=%s", groupID, id)).
+			Return(mockMembershipsResponse(makeGroupMembership("gm-"+id, groupID, "Group", "role-member", "Group Member")), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=%s", groupID, id)).
+			Return(mockMembershipsResponse(makeOrgMembership("om-"+id, "org-1", "Org One", "role-admin", "Org Admin")), nil)
+	}
+
+	plan := m.PlanMemberships(groupID, users, MatchOptions{Domains: []string{"a.com"}, SSODomain: "new.com"}, SyncOptions{Mode: ModeMirror}, &logger)
+	assert.Len(t, plan.Users, 2)
+	// the memberships of alice are mirrored, none
+	assert.Equal(t, "alice@a.com", plan.Users[0].SourceIdentifier)
+	var actions []string
+	for _, op := range plan.Users[0].Operations {
+		actions = append(actions, op.Action)
+	}
+	assert.Equal(t, []string{ActionDeleteGroupMembership, ActionDeleteOrgMembership}, actions)
+	// the memberships of bob are left untouched
+	assert.Equal(t, "bob@a.com", plan.Users[1].SourceIdentifier)
+	assert.Empty(t, plan.Users[1].Operations)
+}
+
+func TestApplyUserOperation_Conflict(t *testing.T) {
+	logger := zerolog.Nop()
+	up := &UserPlan{SourceIdentifier: "user1@source.com", DestinationIdentifier: "user1", DestinationUserID: "dst-1"}
+	conflict := &client.APIError{Method: http.MethodPost, URL: "/rest/orgs/org-1/memberships", StatusCode: http.StatusConflict,
+		Errors: []client.APIErrorObject{{Detail: "Membership already exists for the specified user"}}}
+	createOp := membershipOperation(ActionCreateOrgMembership,
+		toMembershipState(makeOrgMembership("", "org-1", "Org One", "role-admin", "Org Admin")), "")
+
+	t.Run("existing membership with the same role is unchanged", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		mockClient.On("Post", "/rest/orgs/org-1/memberships", mock.Anything).Return([]byte{}, conflict)
+		mockClient.On("Get", "/rest/orgs/org-1/memberships?limit=100&user_id=dst-1").Return(mockMembershipsResponse(
+			makeOrgMembership("om-1", "org-1", "Org One", "role-admin", "Org Admin"),
+		), nil)
+
+		result := m.applyUserOperation(up, createOp, SyncOptions{}, &logger)
+		assert.Equal(t, OperationResult{Operation: createOp, Status: StatusUnchanged}, result)
+		mockClient.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("existing membership with a different role is updated", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		mockClient.On("Post", "/rest/orgs/org-1/memberships", mock.Anything).Return([]byte{}, conflict)
+		mockClient.On("Get", "/rest/orgs/org-1/memberships?limit=100&user_id=dst-1").Return(mockMembershipsResponse(
+			makeOrgMembership("om-1", "org-1", "Org One", "role-collaborator", "Org Collaborator"),
+		), nil)
+		mockClient.On("Patch", "/rest/orgs/org-1/memberships/om-1", mock.Anything).Return([]byte{}, nil)
+
+		result := m.applyUserOperation(up, createOp, SyncOptions{}, &logger)
+		assert.Equal(t, StatusSucceeded, result.Status)
+		assert.Equal(t, ActionUpdateOrgMembershipRole, result.Action)
+		assert.Equal(t, "om-1", result.MembershipID)
+		assert.Equal(t, "role-admin", result.RoleID)
+	})
+
+	t.Run("existing membership with a higher role is kept in merge mode", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		collaboratorOp := membershipOperation(ActionCreateOrgMembership,
+			toMembershipState(makeOrgMembership("", "org-1", "Org One", "role-collaborator", "Org Collaborator")), "")
+		mockClient.On("Post", "/rest/orgs/org-1/memberships", mock.Anything).Return([]byte{}, conflict)
+		mockClient.On("Get", "/rest/orgs/org-1/memberships?limit=100&user_id=dst-1").Return(mockMembershipsResponse(
+			makeOrgMembership("om-1", "org-1", "Org One", "role-admin", "Org Admin"),
+		), nil)
+
+		result := m.applyUserOperation(up, collaboratorOp, SyncOptions{Mode: ModeMerge, RolePrecedence: DefaultRolePrecedence}, &logger)
+		assert.Equal(t, OperationResult{Operation: collaboratorOp, Status: StatusUnchanged}, result)
+		mockClient.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("existing Group membership", func(t *testing.T) {
+		groupOp := membershipOperation(ActionCreateGroupMembership,
+			toMembershipState(makeGroupMembership("", "group-id", "Group", "role-member", "Group Member")), "")
+		newClient := func(roleID, roleName string) (*mocks.MockSnykClient, *Client) {
+			mockClient := new(mocks.MockSnykClient)
+			mockClient.On("Post", "/rest/groups/group-id/memberships", mock.Anything).Return([]byte{}, conflict)
+			mockClient.On("Get", "/rest/groups/group-id/memberships?limit=100&user_id=dst-1").Return(mockMembershipsResponse(
+				makeGroupMembership("gm-1", "group-id", "Group", roleID, roleName),
+			), nil)
+			mockClient.On("Patch", "/rest/groups/group-id/memberships/gm-1", mock.Anything).Return([]byte{}, nil)
+			return mockClient, New(mockClient)
+		}
+
+		// the same role is unchanged
+		mockClient, m := newClient("role-member", "Group Member")
+		assert.Equal(t, OperationResult{Operation: groupOp, Status: StatusUnchanged}, m.applyUserOperation(up, groupOp, SyncOptions{}, &logger))
+		mockClient.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything)
+
+		// a different role is updated in mirror mode
+		_, m = newClient("role-admin", "Group Admin")
+		result := m.applyUserOperation(up, groupOp, SyncOptions{Mode: ModeMirror}, &logger)
+		assert.Equal(t, StatusSucceeded, result.Status)
+		assert.Equal(t, ActionUpdateGroupMembershipRole, result.Action)
+		assert.Equal(t, "gm-1", result.MembershipID)
+
+		// a higher role is kept in merge mode
+		mockClient, m = newClient("role-admin", "Group Admin")
+		result = m.applyUserOperation(up, groupOp, SyncOptions{Mode: ModeMerge, RolePrecedence: DefaultRolePrecedence}, &logger)
+		assert.Equal(t, StatusUnchanged, result.Status)
+		mockClient.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("other errors fail", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		m := New(mockClient)
+		mockClient.On("Post", "/rest/orgs/org-1/memberships", mock.Anything).
+			Return([]byte{}, &client.APIError{Method: http.MethodPost, URL: "/rest/orgs/org-1/memberships", StatusCode: http.StatusForbidden})
+
+		result := m.applyUserOperation(up, createOp, SyncOptions{}, &logger)
+		assert.Equal(t, StatusFailed, result.Status)
+		assert.Equal(t, "failed to POST /rest/orgs/org-1/memberships: 403", result.Error)
+		mockClient.AssertNotCalled(t, "Get", mock.Anything)
+	})
+}
+
+func TestPlanConnections(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "group-id"
+	mockClient := new(mocks.MockSnykClient)
+	m := New(mockClient)
+
+	// the Users keep their email across the SSO connections of the Group
+	sourceUsers := sso.Users{Data: []sso.User{makeUser("src-1", "john.doe@example.com", "john.doe@example.com")}}
+	destinationUsers := sso.Users{Data: []sso.User{makeUser("dst-1", "john.doe@example.com", "john.doe")}}
+	mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=src-1", groupID)).
+		Return(mockMembershipsResponse(makeGroupMembership("gm-src-1", groupID, "Group", "role-admin", "Group Admin")), nil)
+	mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=src-1", groupID)).Return(mockMembershipsResponse(), nil)
+	mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/memberships?limit=100&user_id=dst-1", groupID)).Return(mockMembershipsResponse(), nil)
+	mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/org_memberships?limit=100&user_id=dst-1", groupID)).Return(mockMembershipsResponse(), nil)
+
+	match := MatchOptions{Domains: []string{"example.com"}, SSODomain: "example.com"}
+	plan := m.PlanConnections(groupID, sourceUsers, destinationUsers, match, SyncOptions{}, &logger)
+
+	assert.Len(t, plan.Users, 1)
+	assert.Equal(t, "src-1", plan.Users[0].SourceUserID)
+	assert.Equal(t, "dst-1", plan.Users[0].DestinationUserID)
+	assert.Empty(t, plan.SourceGroupID)
+	assert.Len(t, plan.Users[0].Operations, 1)
+	assert.Equal(t, ActionCreateGroupMembership, plan.Users[0].Operations[0].Action)
+}
@@ -0,0 +1,134 @@
+package membership
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	// TransformRegex replaces every match of Pattern by Replacement, which may refer to the submatches as $1.
+	TransformRegex = "regex"
+	// TransformLowercase folds the local part to lower case.
+	TransformLowercase = "lowercase"
+	// TransformUppercase folds the local part to upper case.
+	TransformUppercase = "uppercase"
+	// TransformReplace substitutes every From separator by To.
+	TransformReplace = "replace"
+	// TransformTrimPrefix strips the Value prefix.
+	TransformTrimPrefix = "trimPrefix"
+	// TransformTrimSuffix strips the Value suffix.
+	TransformTrimSuffix = "trimSuffix"
+)
+
+// TransformRule is a rule rewriting the local part of a pre-migrated User into the local part of its provisioned User.
+type TransformRule struct {
+	Type        string `json:"type"`
+	Pattern     string `json:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	From        string `json:"from,omitempty"`
+	To          string `json:"to,omitempty"`
+	Value       string `json:"value,omitempty"`
+}
+
+// TransformConfig is the content of a transform rules file.
+type TransformConfig struct {
+	Rules []TransformRule `json:"rules"`
+}
+
+// TransformStep is the outcome of a single rule of a Transforms chain.
+type TransformStep struct {
+	Rule   string `json:"rule"`
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// Transforms is a validated chain of rules applied in order to the local part of a pre-migrated User.
+type Transforms struct {
+	rules   []TransformRule
+	regexps []*regexp.Regexp
+}
+
+// String describes the rule.
+func (r TransformRule) String() string {
+	switch r.Type {
+	case TransformRegex:
+		return fmt.Sprintf("%s %q -> %q", r.Type, r.Pattern, r.Replacement)
+	case TransformReplace:
+		return fmt.Sprintf("%s %q -> %q", r.Type, r.From, r.To)
+	case TransformTrimPrefix, TransformTrimSuffix:
+		return fmt.Sprintf("%s %q", r.Type, r.Value)
+	}
+	return r.Type
+}
+
+// NewTransforms validates the rules and compiles their regular expressions.
+func NewTransforms(rules []TransformRule) (*Transforms, error) {
+	t := &Transforms{rules: rules, regexps: make([]*regexp.Regexp, len(rules))}
+	for i, rule := range rules {
+		switch rule.Type {
+		case TransformRegex:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("transform rule %d has an invalid pattern: %w", i+1, err)
+			}
+			t.regexps[i] = re
+		case TransformReplace:
+			if rule.From == "" {
+				return nil, fmt.Errorf("transform rule %d must have a from separator", i+1)
+			}
+		case TransformTrimPrefix, TransformTrimSuffix:
+			if rule.Value == "" {
+				return nil, fmt.Errorf("transform rule %d must have a value", i+1)
+			}
+		case TransformLowercase, TransformUppercase:
+		default:
+			return nil, fmt.Errorf("transform rule %d has an invalid type: %s", i+1, rule.Type)
+		}
+	}
+	return t, nil
+}
+
+// ReadTransforms reads and validates the transform rules of a JSON rules file.
+func ReadTransforms(r io.Reader) (*Transforms, error) {
+	var config TransformConfig
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, err
+	}
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("transform rules file has no rules")
+	}
+	return NewTransforms(config.Rules)
+}
+
+// Apply rewrites the local part through every rule in order, returning the rewritten local part and the steps taken.
+// A nil Transforms leaves the local part unchanged.
+func (t *Transforms) Apply(localPart string) (string, []TransformStep) {
+	if t == nil {
+		return localPart, nil
+	}
+	steps := make([]TransformStep, 0, len(t.rules))
+	for i, rule := range t.rules {
+		input := localPart
+		switch rule.Type {
+		case TransformRegex:
+			localPart = t.regexps[i].ReplaceAllString(localPart, rule.Replacement)
+		case TransformLowercase:
+			localPart = strings.ToLower(localPart)
+		case TransformUppercase:
+			localPart = strings.ToUpper(localPart)
+		case TransformReplace:
+			localPart = strings.ReplaceAll(localPart, rule.From, rule.To)
+		case TransformTrimPrefix:
+			localPart = strings.TrimPrefix(localPart, rule.Value)
+		case TransformTrimSuffix:
+			localPart = strings.TrimSuffix(localPart, rule.Value)
+		}
+		steps = append(steps, TransformStep{Rule: rule.String(), Input: input, Output: localPart})
+	}
+	return localPart, steps
+}
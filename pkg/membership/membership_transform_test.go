@@ -0,0 +1,111 @@
+package membership
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/snyk-labs/snyk-sso-membership/pkg/sso"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransforms(t *testing.T) {
+	t.Run("applies every rule in order", func(t *testing.T) {
+		transforms, err := NewTransforms([]TransformRule{
+			{Type: TransformRegex, Pattern: `\+.*$`},
+			{Type: TransformRegex, Pattern: `[0-9]+$`},
+			{Type: TransformTrimPrefix, Value: "ext-"},
+			{Type: TransformReplace, From: "_", To: "."},
+			{Type: TransformLowercase},
+		})
+		assert.NoError(t, err)
+
+		localPart, steps := transforms.Apply("ext-John_Doe42+build")
+		assert.Equal(t, "john.doe", localPart)
+		assert.Equal(t, []TransformStep{
+			{Rule: `regex "\\+.*$" -> ""`, Input: "ext-John_Doe42+build", Output: "ext-John_Doe42"},
+			{Rule: `regex "[0-9]+$" -> ""`, Input: "ext-John_Doe42", Output: "ext-John_Doe"},
+			{Rule: `trimPrefix "ext-"`, Input: "ext-John_Doe", Output: "John_Doe"},
+			{Rule: `replace "_" -> "."`, Input: "John_Doe", Output: "John.Doe"},
+			{Rule: "lowercase", Input: "John.Doe", Output: "john.doe"},
+		}, steps)
+	})
+
+	t.Run("nil transforms leave the local part unchanged", func(t *testing.T) {
+		var transforms *Transforms
+		localPart, steps := transforms.Apply("John_Doe")
+		assert.Equal(t, "John_Doe", localPart)
+		assert.Empty(t, steps)
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		_, err := NewTransforms([]TransformRule{{Type: TransformLowercase}, {Type: TransformRegex, Pattern: "("}})
+		assert.ErrorContains(t, err, "transform rule 2 has an invalid pattern")
+		_, err = NewTransforms([]TransformRule{{Type: TransformReplace}})
+		assert.EqualError(t, err, "transform rule 1 must have a from separator")
+		_, err = NewTransforms([]TransformRule{{Type: TransformTrimSuffix}})
+		assert.EqualError(t, err, "transform rule 1 must have a value")
+		_, err = NewTransforms([]TransformRule{{Type: "titlecase"}})
+		assert.EqualError(t, err, "transform rule 1 has an invalid type: titlecase")
+	})
+}
+
+func TestReadTransforms(t *testing.T) {
+	transforms, err := ReadTransforms(strings.NewReader(`{"rules": [{"type": "replace", "from": "_", "to": "."}, {"type": "uppercase"}]}`))
+	assert.NoError(t, err)
+	localPart, _ := transforms.Apply("john_doe")
+	assert.Equal(t, "JOHN.DOE", localPart)
+
+	_, err = ReadTransforms(strings.NewReader(`{"rules": []}`))
+	assert.EqualError(t, err, "transform rules file has no rules")
+
+	_, err = ReadTransforms(strings.NewReader(`{"rules": [{"type": "lowercase", "patern": "x"}]}`))
+	assert.Error(t, err)
+}
+
+func TestExplainMatches(t *testing.T) {
+	users := sso.Users{Data: []sso.User{
+		makeUser("src-1", "John_Doe+ci@old.com", "John_Doe+ci@old.com"),
+		makeUser("src-2", "jane@old.com", "jane@old.com"),
+		makeUser("dst-1", "john.doe@new.com", "john.doe"),
+	}}
+	transforms, err := NewTransforms([]TransformRule{
+		{Type: TransformRegex, Pattern: `\+.*$`},
+		{Type: TransformReplace, From: "_", To: "."},
+		{Type: TransformLowercase},
+	})
+	assert.NoError(t, err)
+
+	explanations := ExplainMatches(users, MatchOptions{Domains: []string{"old.com"}, SSODomain: "new.com", Transforms: transforms}, nil)
+	assert.Len(t, explanations, 2)
+	assert.Equal(t, "John_Doe+ci@old.com", explanations[0].SourceIdentifier)
+	assert.Equal(t, "John_Doe+ci", explanations[0].LocalPart)
+	assert.Equal(t, []TransformStep{
+		{Rule: `regex "\\+.*$" -> ""`, Input: "John_Doe+ci", Output: "John_Doe"},
+		{Rule: `replace "_" -> "."`, Input: "John_Doe", Output: "John.Doe"},
+		{Rule: "lowercase", Input: "John.Doe", Output: "john.doe"},
+	}, explanations[0].Transforms)
+	assert.Equal(t, "john.doe@new.com", explanations[0].DestinationIdentifier)
+	assert.Equal(t, "dst-1", explanations[0].DestinationUserID)
+	assert.True(t, explanations[0].Matched)
+	assert.Equal(t, "jane", explanations[1].LocalPart)
+	assert.Len(t, explanations[1].Transforms, 3)
+	assert.Equal(t, "jane@new.com", explanations[1].DestinationIdentifier)
+	assert.False(t, explanations[1].Matched)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteExplanationsTable(&buf, explanations, true))
+	for _, line := range []string{
+		`transform: regex "\\+.*$" -> ""`,
+		"John_Doe+ci -> John_Doe",
+		`transform: replace "_" -> "."`,
+		"John_Doe -> John.Doe",
+		"transform: lowercase",
+		"John.Doe -> john.doe",
+		"matched john.doe@new.com (dst-1)",
+		"jane -> jane",
+	} {
+		assert.Contains(t, buf.String(), line)
+	}
+	assert.True(t, strings.HasSuffix(buf.String(), "Match: 2 Users, 1 matched, 1 not matched\n"))
+}
@@ -0,0 +1,205 @@
+package membership
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// DiscrepancyMissing is a membership of the pre-migrated User the provisioned User lacks.
+	DiscrepancyMissing = "missing"
+	// DiscrepancyWrongRole is a membership of the provisioned User whose role differs from the pre-migrated User.
+	DiscrepancyWrongRole = "wrong_role"
+	// DiscrepancyExtra is a membership of the provisioned User the pre-migrated User lacks, only reported in mirror mode.
+	DiscrepancyExtra = "extra"
+)
+
+// Discrepancy is a difference between the memberships of a provisioned User and those expected from its pre-migrated User.
+type Discrepancy struct {
+	Kind      string `json:"kind"`
+	Type      string `json:"type"`
+	GroupID   string `json:"groupId,omitempty"`
+	GroupName string `json:"groupName,omitempty"`
+	OrgID     string `json:"orgId,omitempty"`
+	OrgName   string `json:"orgName,omitempty"`
+	// ExpectedRoleID and ExpectedRoleName are the role the provisioned User should hold, empty for an extra membership
+	ExpectedRoleID   string `json:"expectedRoleId,omitempty"`
+	ExpectedRoleName string `json:"expectedRoleName,omitempty"`
+	// ActualRoleID and ActualRoleName are the role the provisioned User holds, empty for a missing membership
+	ActualRoleID   string `json:"actualRoleId,omitempty"`
+	ActualRoleName string `json:"actualRoleName,omitempty"`
+}
+
+// UserVerification is the outcome of the verification of a User pair.
+type UserVerification struct {
+	SourceIdentifier      string        `json:"sourceIdentifier"`
+	DestinationIdentifier string        `json:"destinationIdentifier"`
+	Passed                bool          `json:"passed"`
+	Discrepancies         []Discrepancy `json:"discrepancies,omitempty"`
+	// Unread lists the memberships of the User pair that failed to be fetched, the User pair cannot pass without them
+	Unread []string `json:"unread,omitempty"`
+	// SkippedMemberships lists the memberships of the pre-migrated User that are intentionally not synchronized
+	SkippedMemberships []SkippedMembership `json:"skippedMemberships,omitempty"`
+}
+
+// Verification is the audit of the memberships of the provisioned Users against their pre-migrated Users.
+type Verification struct {
+	GroupID       string             `json:"groupId"`
+	SourceGroupID string             `json:"sourceGroupId,omitempty"`
+	Passed        bool               `json:"passed"`
+	Users         []UserVerification `json:"users"`
+	// Unmatched lists the source identifiers of the pre-migrated Users without a matching provisioned User
+	Unmatched []string `json:"unmatched,omitempty"`
+	// Conflicts lists the ambiguous pairings of pre-migrated Users, none of their Users can be verified
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+}
+
+// Description returns a human readable summary of the Discrepancy.
+func (d Discrepancy) Description() string {
+	target := fmt.Sprintf("GroupMembership, Group: %s", nameOrID(d.GroupName, d.GroupID))
+	if d.Type == OrgMembershipType {
+		target = fmt.Sprintf("OrgMembership, Org: %s", nameOrID(d.OrgName, d.OrgID))
+	}
+	switch d.Kind {
+	case DiscrepancyMissing:
+		return fmt.Sprintf("missing %s, expected Role: %s", target, nameOrID(d.ExpectedRoleName, d.ExpectedRoleID))
+	case DiscrepancyWrongRole:
+		return fmt.Sprintf("wrong role %s, expected Role: %s, actual Role: %s", target,
+			nameOrID(d.ExpectedRoleName, d.ExpectedRoleID), nameOrID(d.ActualRoleName, d.ActualRoleID))
+	case DiscrepancyExtra:
+		return fmt.Sprintf("extra %s, actual Role: %s", target, nameOrID(d.ActualRoleName, d.ActualRoleID))
+	}
+	return d.Kind
+}
+
+// Verify turns the Operations still required by the Plan into the Discrepancies of every User pair:
+// a User pair passes once its memberships need no further Operation under the synchronization mode of the Plan.
+func (p *Plan) Verify() *Verification {
+	v := &Verification{
+		GroupID:       p.GroupID,
+		SourceGroupID: p.SourceGroupID,
+		Unmatched:     p.Unmatched,
+		Conflicts:     p.Conflicts,
+	}
+	for _, up := range p.Users {
+		uv := UserVerification{
+			SourceIdentifier:      up.SourceIdentifier,
+			DestinationIdentifier: up.DestinationIdentifier,
+			Unread:                up.Unread,
+			SkippedMemberships:    up.SkippedMemberships,
+		}
+		for _, op := range up.Operations {
+			uv.Discrepancies = append(uv.Discrepancies, toDiscrepancy(op, up.DestinationMemberships))
+		}
+		uv.Passed = len(uv.Discrepancies) == 0 && len(uv.Unread) == 0
+		v.Users = append(v.Users, uv)
+	}
+	v.Passed = len(v.FailedUsers()) == 0 && len(v.Unmatched) == 0 && len(v.Conflicts) == 0
+	return v
+}
+
+// toDiscrepancy describes the difference an Operation would resolve, looking up the actual role of an updated membership
+// in the memberships of the provisioned User.
+func toDiscrepancy(op Operation, destinationMemberships []MembershipState) Discrepancy {
+	d := Discrepancy{
+		Type:      GroupMembershipType,
+		GroupID:   op.GroupID,
+		GroupName: op.GroupName,
+		OrgID:     op.OrgID,
+		OrgName:   op.OrgName,
+	}
+	if !op.isGroupMembership() {
+		d.Type = OrgMembershipType
+	}
+
+	switch op.Action {
+	case ActionCreateGroupMembership, ActionCreateOrgMembership:
+		d.Kind = DiscrepancyMissing
+		d.ExpectedRoleID, d.ExpectedRoleName = op.RoleID, op.RoleName
+	case ActionUpdateGroupMembershipRole, ActionUpdateOrgMembershipRole:
+		d.Kind = DiscrepancyWrongRole
+		d.ExpectedRoleID, d.ExpectedRoleName = op.RoleID, op.RoleName
+		for _, state := range destinationMemberships {
+			if state.ID == op.MembershipID {
+				d.ActualRoleID, d.ActualRoleName = state.RoleID, state.RoleName
+				break
+			}
+		}
+	case ActionDeleteGroupMembership, ActionDeleteOrgMembership:
+		d.Kind = DiscrepancyExtra
+		d.ActualRoleID, d.ActualRoleName = op.RoleID, op.RoleName
+	}
+	return d
+}
+
+// FailedUsers returns the source identifiers of the User pairs that did not pass the verification.
+func (v *Verification) FailedUsers() []string {
+	var failed []string
+	for _, uv := range v.Users {
+		if !uv.Passed {
+			failed = append(failed, uv.SourceIdentifier)
+		}
+	}
+	return failed
+}
+
+// Err returns an error describing the User pairs that did not pass the verification, nil if all of them passed.
+func (v *Verification) Err() error {
+	if failed := v.FailedUsers(); len(failed) > 0 {
+		return fmt.Errorf("memberships of %d Users failed verification: %s", len(failed), strings.Join(failed, ", "))
+	}
+	if unverified := len(v.Unmatched) + len(v.Conflicts); unverified > 0 {
+		return fmt.Errorf("%d unmatched or conflicting Users could not be verified", unverified)
+	}
+	return nil
+}
+
+// Write prints the pass or fail outcome of every User pair with its Discrepancies, followed by a summary.
+func (v *Verification) Write(w io.Writer) error {
+	var b strings.Builder
+	if v.SourceGroupID != "" {
+		fmt.Fprintf(&b, "Migration: Group %s -> Group %s\n", v.SourceGroupID, v.GroupID)
+	}
+	passed := 0
+	for _, uv := range v.Users {
+		outcome := "FAIL"
+		if uv.Passed {
+			outcome = "PASS"
+			passed++
+		}
+		fmt.Fprintf(&b, "%s %s -> %s\n", outcome, uv.SourceIdentifier, uv.DestinationIdentifier)
+		for _, d := range uv.Discrepancies {
+			fmt.Fprintf(&b, "  %s\n", d.Description())
+		}
+		for _, unread := range uv.Unread {
+			fmt.Fprintf(&b, "  unread %s\n", unread)
+		}
+		for _, sm := range uv.SkippedMemberships {
+			fmt.Fprintf(&b, "  %-6s %s\n", "SKIP", sm.Description())
+		}
+	}
+	for _, sourceIdentifier := range v.Unmatched {
+		fmt.Fprintf(&b, "Unverified %s: no matching provisioned User\n", sourceIdentifier)
+	}
+	for _, c := range v.Conflicts {
+		fmt.Fprintf(&b, "Unverified %s\n", c.String())
+	}
+	outcome := "FAIL"
+	if v.Passed {
+		outcome = "PASS"
+	}
+	fmt.Fprintf(&b, "Verification: %s, %d Users, %d passed, %d failed, %d unmatched, %d conflicts\n",
+		outcome, len(v.Users), passed, len(v.Users)-passed, len(v.Unmatched), len(v.Conflicts))
+
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
+
+// WriteJSON writes the machine-readable Verification.
+func (v *Verification) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
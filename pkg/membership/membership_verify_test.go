@@ -0,0 +1,117 @@
+package membership
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanVerify(t *testing.T) {
+	groupID := "group-id"
+	provisionedUserAttributesMap := map[string]provisionedUserAttributes{
+		"jane.doe@example.com": {
+			id:                          stringPtr("src-user-1"),
+			provisionedID:               stringPtr("dst-user-1"),
+			provisionedUserName:         stringPtr("jane.doe@sso.example.com"),
+			groupMemberships:            &UserGroupMemberships{},
+			provisionedGroupMemberships: &UserGroupMemberships{},
+			orgMemberships: &UserOrgMemberships{Data: []Membership{
+				makeOrgMembership("om-src-1", "org-1", "Org One", "role-admin", "Org Admin"),
+			}},
+			provisionedOrgMemberships: &UserOrgMemberships{Data: []Membership{
+				makeOrgMembership("om-dst-1", "org-1", "Org One", "role-admin", "Org Admin"),
+			}},
+		},
+		"john.doe@example.com": {
+			id:                  stringPtr("src-user-2"),
+			provisionedID:       stringPtr("dst-user-2"),
+			provisionedUserName: stringPtr("john.doe@sso.example.com"),
+			groupMemberships:    &UserGroupMemberships{},
+			provisionedGroupMemberships: &UserGroupMemberships{Data: []Membership{
+				makeGroupMembership("gm-dst-1", groupID, "Group", "role-group-admin", "Group Admin"),
+			}},
+			orgMemberships: &UserOrgMemberships{Data: []Membership{
+				makeOrgMembership("om-src-2", "org-1", "Org One", "role-admin", "Org Admin"),
+				makeOrgMembership("om-src-3", "org-2", "Org Two", "role-admin", "Org Admin"),
+			}},
+			provisionedOrgMemberships: &UserOrgMemberships{Data: []Membership{
+				makeOrgMembership("om-dst-2", "org-1", "Org One", "role-collaborator", "Org Collaborator"),
+			}},
+		},
+		"jim.doe@example.com": {
+			id:                  stringPtr("src-user-3"),
+			provisionedID:       stringPtr("dst-user-3"),
+			provisionedUserName: stringPtr("jim.doe@sso.example.com"),
+			groupMemberships:    &UserGroupMemberships{},
+			orgMemberships:      &UserOrgMemberships{},
+		},
+		"joe.doe@example.com": {id: stringPtr("src-user-4")},
+	}
+
+	t.Run("mirror mode reports missing, wrong role and extra memberships", func(t *testing.T) {
+		v := buildPlan(groupID, provisionedUserAttributesMap, SyncOptions{Mode: ModeMirror}).Verify()
+
+		assert.False(t, v.Passed)
+		assert.Len(t, v.Users, 3)
+		assert.Equal(t, []string{"joe.doe@example.com"}, v.Unmatched)
+
+		jane, jim, john := v.Users[0], v.Users[1], v.Users[2]
+		assert.True(t, jane.Passed)
+		assert.Empty(t, jane.Discrepancies)
+		assert.False(t, jim.Passed)
+		assert.Equal(t, []string{"destination GroupMemberships", "destination OrgMemberships"}, jim.Unread)
+		assert.False(t, john.Passed)
+		assert.Equal(t, []Discrepancy{
+			{Kind: DiscrepancyExtra, Type: GroupMembershipType, GroupID: groupID, GroupName: "Group",
+				ActualRoleID: "role-group-admin", ActualRoleName: "Group Admin"},
+			{Kind: DiscrepancyWrongRole, Type: OrgMembershipType, OrgID: "org-1", OrgName: "Org One",
+				ExpectedRoleID: "role-admin", ExpectedRoleName: "Org Admin", ActualRoleID: "role-collaborator", ActualRoleName: "Org Collaborator"},
+			{Kind: DiscrepancyMissing, Type: OrgMembershipType, OrgID: "org-2", OrgName: "Org Two",
+				ExpectedRoleID: "role-admin", ExpectedRoleName: "Org Admin"},
+		}, john.Discrepancies)
+		assert.Equal(t, []string{"jim.doe@example.com", "john.doe@example.com"}, v.FailedUsers())
+		assert.EqualError(t, v.Err(), "memberships of 2 Users failed verification: jim.doe@example.com, john.doe@example.com")
+
+		var b bytes.Buffer
+		assert.NoError(t, v.Write(&b))
+		assert.Equal(t, "PASS jane.doe@example.com -> jane.doe@sso.example.com\n"+
+			"FAIL jim.doe@example.com -> jim.doe@sso.example.com\n"+
+			"  unread destination GroupMemberships\n"+
+			"  unread destination OrgMemberships\n"+
+			"FAIL john.doe@example.com -> john.doe@sso.example.com\n"+
+			"  extra GroupMembership, Group: Group, actual Role: Group Admin\n"+
+			"  wrong role OrgMembership, Org: Org One, expected Role: Org Admin, actual Role: Org Collaborator\n"+
+			"  missing OrgMembership, Org: Org Two, expected Role: Org Admin\n"+
+			"Unverified joe.doe@example.com: no matching provisioned User\n"+
+			"Verification: FAIL, 3 Users, 1 passed, 2 failed, 1 unmatched, 0 conflicts\n", b.String())
+
+		b.Reset()
+		assert.NoError(t, v.WriteJSON(&b))
+		var decoded Verification
+		assert.NoError(t, json.Unmarshal(b.Bytes(), &decoded))
+		assert.Equal(t, *v, decoded)
+	})
+
+	t.Run("merge mode tolerates extra memberships and roles it would not upgrade", func(t *testing.T) {
+		v := buildPlan(groupID, map[string]provisionedUserAttributes{
+			"john.doe@example.com": provisionedUserAttributesMap["john.doe@example.com"],
+		}, SyncOptions{Mode: ModeMerge}).Verify()
+
+		kinds := make([]string, 0, len(v.Users[0].Discrepancies))
+		for _, d := range v.Users[0].Discrepancies {
+			kinds = append(kinds, d.Kind)
+		}
+		assert.Equal(t, []string{DiscrepancyMissing}, kinds)
+	})
+
+	t.Run("passes without any discrepancy", func(t *testing.T) {
+		v := buildPlan(groupID, map[string]provisionedUserAttributes{
+			"jane.doe@example.com": provisionedUserAttributesMap["jane.doe@example.com"],
+		}, SyncOptions{Mode: ModeMirror}).Verify()
+
+		assert.True(t, v.Passed)
+		assert.NoError(t, v.Err())
+	})
+}
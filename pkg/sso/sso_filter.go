@@ -0,0 +1,85 @@
+package sso
+
+import (
+	"regexp"
+)
+
+// UserFilter is a condition on an SSO user. Filters compose into a filter expression with AllOf, AnyOf and Not.
+type UserFilter func(user *User) bool
+
+// AllOf matches the users matching every filter, any user without filters.
+func AllOf(filters ...UserFilter) UserFilter {
+	return func(user *User) bool {
+		for _, f := range filters {
+			if !f(user) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf matches the users matching at least one of the filters, no user without filters.
+func AnyOf(filters ...UserFilter) UserFilter {
+	return func(user *User) bool {
+		for _, f := range filters {
+			if f(user) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches the users not matching the filter.
+func Not(filter UserFilter) UserFilter {
+	return func(user *User) bool {
+		return !filter(user)
+	}
+}
+
+// OfDomain matches the users whose email, or username if matchByUserName is true, is on the domain.
+func OfDomain(domain string, matchByUserName bool) UserFilter {
+	return func(user *User) bool {
+		return isUserProfileOfDomain(user, domain, matchByUserName)
+	}
+}
+
+// IsActive matches the users whose active state is the given one, never the users without an active state.
+func IsActive(active bool) UserFilter {
+	return func(user *User) bool {
+		return user.Attributes != nil && user.Attributes.Active != nil && *user.Attributes.Active == active
+	}
+}
+
+// NameMatches matches the users whose name matches the regular expression.
+func NameMatches(re *regexp.Regexp) UserFilter {
+	return func(user *User) bool {
+		return user.Attributes != nil && user.Attributes.Name != nil && re.MatchString(*user.Attributes.Name)
+	}
+}
+
+// EmailMatches matches the users whose email matches the regular expression.
+func EmailMatches(re *regexp.Regexp) UserFilter {
+	return func(user *User) bool {
+		return user.Attributes != nil && user.Attributes.Email != nil && re.MatchString(*user.Attributes.Email)
+	}
+}
+
+// UserNameMatches matches the users whose username matches the regular expression.
+func UserNameMatches(re *regexp.Regexp) UserFilter {
+	return func(user *User) bool {
+		return user.Attributes != nil && user.Attributes.UserName != nil && re.MatchString(*user.Attributes.UserName)
+	}
+}
+
+// FilterUsers returns the users matching the filter, in their order.
+func FilterUsers(users Users, filter UserFilter) []User {
+	var filteredUsers []User
+	for i := range users.Data {
+		if filter(&users.Data[i]) {
+			filteredUsers = append(filteredUsers, users.Data[i])
+		}
+	}
+	return filteredUsers
+}
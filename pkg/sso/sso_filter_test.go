@@ -0,0 +1,68 @@
+package sso
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeFilterUser(id, email, username, name string, active *bool) User {
+	return User{ID: stringPtr(id), Attributes: &struct {
+		Name     *string `json:"name"`
+		Email    *string `json:"email"`
+		UserName *string `json:"username"`
+		Active   *bool   `json:"active"`
+	}{Name: stringPtr(name), Email: stringPtr(email), UserName: stringPtr(username), Active: active}}
+}
+
+func filteredIDs(users []User) []string {
+	var ids []string
+	for _, u := range users {
+		ids = append(ids, *u.ID)
+	}
+	return ids
+}
+
+func TestFilterUsers(t *testing.T) {
+	users := Users{Data: []User{
+		makeFilterUser("1", "jane.doe@old.com", "jane.doe@old.com", "Jane Doe", boolPtr(false)),
+		makeFilterUser("2", "john.doe@old.com", "jdoe", "John Doe", boolPtr(false)),
+		makeFilterUser("3", "bob@old.com", "bob", "Bob", boolPtr(true)),
+		makeFilterUser("4", "alice@new.com", "alice", "Alice", boolPtr(false)),
+		makeFilterUser("5", "eve@old.com", "eve", "Eve", nil),
+		{ID: stringPtr("6")},
+	}}
+
+	tests := []struct {
+		name     string
+		filter   UserFilter
+		expected []string
+	}{
+		{name: "no filters", filter: AllOf(), expected: []string{"1", "2", "3", "4", "5", "6"}},
+		{name: "any of no filters", filter: AnyOf(), expected: nil},
+		{name: "domain", filter: OfDomain("old.com", false), expected: []string{"1", "2", "3", "5"}},
+		{name: "username domain", filter: OfDomain("old.com", true), expected: []string{"1"}},
+		{name: "any domain", filter: AnyOf(OfDomain("old.com", false), OfDomain("new.com", false)), expected: []string{"1", "2", "3", "4", "5"}},
+		{name: "not domain", filter: Not(OfDomain("old.com", false)), expected: []string{"4", "6"}},
+		{name: "inactive", filter: IsActive(false), expected: []string{"1", "2", "4"}},
+		{name: "active", filter: IsActive(true), expected: []string{"3"}},
+		{name: "name regex", filter: NameMatches(regexp.MustCompile(`Doe$`)), expected: []string{"1", "2"}},
+		{name: "email regex", filter: EmailMatches(regexp.MustCompile(`^[a-e]`)), expected: []string{"3", "4", "5"}},
+		{
+			name: "inactive users on old.com whose username is not an email",
+			filter: AllOf(
+				IsActive(false),
+				AnyOf(OfDomain("old.com", false)),
+				UserNameMatches(regexp.MustCompile(`^[^@]+$`)),
+			),
+			expected: []string{"2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, filteredIDs(FilterUsers(users, tt.filter)))
+		})
+	}
+}
@@ -11,6 +11,8 @@ import (
 
 type Client struct {
 	client client.SnykClient
+	// connection selects the SSO connection of a Group by its ID or name, empty to use the only connection of the Group
+	connection string
 }
 
 func New(c client.SnykClient) *Client {
@@ -19,14 +21,37 @@ func New(c client.SnykClient) *Client {
 	}
 }
 
+// WithConnection returns a Client of the SSO connection selected by its ID or its case-insensitive name,
+// for the Groups with several SSO connections. An empty connection uses the only connection of a Group.
+func (sso *Client) WithConnection(connection string) *Client {
+	return &Client{
+		client:     sso.client,
+		connection: connection,
+	}
+}
+
+// ConnectionData is an SSO connection of a Group.
+type ConnectionData struct {
+	ID         *string `json:"id"`
+	Type       *string `json:"type"`
+	Attributes *struct {
+		Name *string `json:"name"`
+	} `json:"attributes"`
+}
+
 type Connection struct {
-	Data []struct {
-		ID         *string `json:"id"`
-		Type       *string `json:"type"`
-		Attributes *struct {
-			Name *string `json:"name"`
-		} `json:"attributes"`
-	} `json:"data"`
+	Data []ConnectionData `json:"data"`
+}
+
+// connectionName returns the name of the SSO connection, or its ID if it has no name.
+func connectionName(c ConnectionData) string {
+	if c.Attributes != nil && c.Attributes.Name != nil && *c.Attributes.Name != "" {
+		return *c.Attributes.Name
+	}
+	if c.ID != nil {
+		return *c.ID
+	}
+	return ""
 }
 
 type User struct {
@@ -69,6 +94,76 @@ func (sso *Client) getSSOConnection(groupID string) (*Connection, error) {
 	return &ssoConnection, nil
 }
 
+// GetConnections retrieves every SSO connection of a Group.
+func (sso *Client) GetConnections(groupID string) ([]ConnectionData, error) {
+	ssoConnection, err := sso.getSSOConnection(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return ssoConnection.Data, nil
+}
+
+// ConnectionDetails describes an SSO connection of a Group with the number of its Users.
+type ConnectionDetails struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Users int    `json:"users"`
+}
+
+// GetConnectionDetails retrieves every SSO connection of a Group, counting the Users of every connection.
+func (sso *Client) GetConnectionDetails(groupID string, logger *zerolog.Logger) ([]ConnectionDetails, error) {
+	connections, err := sso.GetConnections(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]ConnectionDetails, 0, len(connections))
+	for _, c := range connections {
+		if c.ID == nil {
+			continue
+		}
+		d := ConnectionDetails{ID: *c.ID, Name: connectionName(c)}
+		if c.Type != nil {
+			d.Type = *c.Type
+		}
+		ssoUsers, err := sso.getSSOUsers(groupID, *c.ID, logger)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get SSO users on connection: %s: %w", d.Name, err)
+		}
+		d.Users = len(ssoUsers.Data)
+		logger.Debug().Msg(fmt.Sprintf("SSO Connection %s Users: %d", d.Name, d.Users))
+		details = append(details, d)
+	}
+	return details, nil
+}
+
+// SelectConnection selects the SSO connection of the Group matching the connection of the Client by its ID or name.
+// Without a connection, the Group must have a single SSO connection.
+func (sso *Client) SelectConnection(groupID string) (*ConnectionData, error) {
+	ssoConnection, err := sso.getSSOConnection(groupID)
+	if err != nil || ssoConnection == nil || len(ssoConnection.Data) == 0 {
+		return nil, fmt.Errorf("unable to get SSO connection on group: %s", groupID)
+	}
+
+	if sso.connection == "" {
+		if len(ssoConnection.Data) > 1 {
+			names := make([]string, 0, len(ssoConnection.Data))
+			for _, c := range ssoConnection.Data {
+				names = append(names, connectionName(c))
+			}
+			return nil, fmt.Errorf("group: %s has %d SSO connections, select one of: %s", groupID, len(ssoConnection.Data), strings.Join(names, ", "))
+		}
+		return &ssoConnection.Data[0], nil
+	}
+	for i, c := range ssoConnection.Data {
+		if (c.ID != nil && *c.ID == sso.connection) || strings.EqualFold(connectionName(c), sso.connection) {
+			return &ssoConnection.Data[i], nil
+		}
+	}
+	return nil, fmt.Errorf("SSO connection %s not found on group: %s", sso.connection, groupID)
+}
+
 func (sso *Client) getSSOUsers(groupID, ssoConnectionID string, logger *zerolog.Logger) (*Users, error) {
 	requestPath := fmt.Sprintf("/rest/groups/%s/sso_connections/%s/users?limit=100", groupID, ssoConnectionID)
 	respBody, err := sso.client.Get(requestPath)
@@ -178,18 +273,17 @@ func (sso *Client) deleteSSOUser(groupID, ssoConnectionID, userID string) error
 }
 
 // GetUsers retrieves SSO users for a given groupID.
-// It fetches the SSO connection first and then retrieves users associated with that connection.
+// It selects the SSO connection first and then retrieves users associated with that connection.
 func (sso *Client) GetUsers(groupID string, logger *zerolog.Logger) (*Users, error) {
-	ssoConnection, err := sso.getSSOConnection(groupID)
-	if err != nil || ssoConnection == nil || len(ssoConnection.Data) == 0 {
-		return nil, fmt.Errorf("unable to get SSO connection on group: %s", groupID)
+	ssoConnection, err := sso.SelectConnection(groupID)
+	if err != nil {
+		return nil, err
 	}
-	logger.Info().Msg(fmt.Sprintf("SSO Connection Name: %s", *(ssoConnection.Data)[0].Attributes.Name))
+	logger.Info().Msg(fmt.Sprintf("SSO Connection Name: %s", connectionName(*ssoConnection)))
 
-	// customer self-service can only create a SSO setting for a single connection
-	ssoUsers, err := sso.getSSOUsers(groupID, *(ssoConnection.Data)[0].ID, logger)
+	ssoUsers, err := sso.getSSOUsers(groupID, *ssoConnection.ID, logger)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get SSO users on connection: %s", *(ssoConnection.Data)[0].Attributes.Name)
+		return nil, fmt.Errorf("unable to get SSO users on connection: %s", connectionName(*ssoConnection))
 	}
 	logger.Info().Msg(fmt.Sprintf("SSO Connection Users: %d", len(ssoUsers.Data)))
 	return ssoUsers, nil
@@ -197,14 +291,14 @@ func (sso *Client) GetUsers(groupID string, logger *zerolog.Logger) (*Users, err
 
 // Delete SSO users based on the provided groupID and Users.
 func (sso *Client) DeleteUsers(groupID string, users Users, logger *zerolog.Logger) error {
-	ssoConnection, err := sso.getSSOConnection(groupID)
-	if err != nil || ssoConnection == nil || len(ssoConnection.Data) == 0 {
-		logger.Error().Err(err).Msg(fmt.Sprintf("unable to get SSO connection on group: %s", groupID))
-		return fmt.Errorf("unable to get SSO connection on group: %s", groupID)
+	ssoConnection, err := sso.SelectConnection(groupID)
+	if err != nil {
+		logger.Error().Msg(err.Error())
+		return err
 	}
-	logger.Info().Msg(fmt.Sprintf("SSO Connection Name: %s", *(ssoConnection.Data)[0].Attributes.Name))
+	logger.Info().Msg(fmt.Sprintf("SSO Connection Name: %s", connectionName(*ssoConnection)))
 
-	ssoConnectionID := *(ssoConnection.Data)[0].ID
+	ssoConnectionID := *ssoConnection.ID
 
 	for _, user := range users.Data {
 		err := sso.deleteSSOUser(groupID, ssoConnectionID, *user.ID)
@@ -217,15 +311,20 @@ func (sso *Client) DeleteUsers(groupID string, users Users, logger *zerolog.Logg
 	return nil
 }
 
-// FilterUsersByDomain filters the SSO users based on the provided domain.
-func (sso *Client) FilterUsersByDomain(domain string, users Users, matchByUserName bool, logger *zerolog.Logger) ([]User, error) {
-	var filteredUsers []User
-	for _, user := range users.Data {
-		if isUserProfileOfDomain(&user, domain, matchByUserName) {
-			filteredUsers = append(filteredUsers, user)
-		}
+// DeleteUser deletes a single SSO user of the SSO connection ssoConnectionID of the provided groupID, returning the error of its deletion.
+// The SSO connection is selected once by SelectConnection to delete several users.
+func (sso *Client) DeleteUser(groupID, ssoConnectionID string, user User, logger *zerolog.Logger) error {
+	if err := sso.deleteSSOUser(groupID, ssoConnectionID, *user.ID); err != nil {
+		logger.Error().Err(err).Msg(fmt.Sprintf("Failed to delete User: username: %s, email: %s", *user.Attributes.UserName, *user.Attributes.Email))
+		return err
 	}
+	logger.Info().Msg(fmt.Sprintf("Deleted User: username: %s, email: %s", *user.Attributes.UserName, *user.Attributes.Email))
+	return nil
+}
 
+// FilterUsersByDomain filters the SSO users based on the provided domain.
+func (sso *Client) FilterUsersByDomain(domain string, users Users, matchByUserName bool, logger *zerolog.Logger) ([]User, error) {
+	filteredUsers := FilterUsers(users, OfDomain(domain, matchByUserName))
 	if len(filteredUsers) == 0 {
 		logger.Warn().Msg(fmt.Sprintf("No users found matching domain: %s", domain))
 		return nil, fmt.Errorf("no users found matching domain: %s", domain)
@@ -298,6 +298,28 @@ func TestDeleteUsers_GetConnectionError(t *testing.T) {
 	assert.EqualError(t, err, "unable to get SSO connection on group: test-group-id")
 }
 
+func TestDeleteUser(t *testing.T) {
+	mockClient := new(mocks.MockSnykClient)
+	sso := New(mockClient)
+	groupID := "test-group-id"
+	newUser := func(id, email string) User {
+		return User{ID: stringPtr(id), Attributes: &struct {
+			Name     *string `json:"name"`
+			Email    *string `json:"email"`
+			UserName *string `json:"username"`
+			Active   *bool   `json:"active"`
+		}{Email: stringPtr(email), UserName: stringPtr(email)}}
+	}
+	logger := zerolog.Nop()
+
+	mockClient.On("Delete", fmt.Sprintf("/rest/groups/%s/sso_connections/connection-id/users/user-id-1", groupID)).Return([]byte{}, errors.New("status code 500"))
+	mockClient.On("Delete", fmt.Sprintf("/rest/groups/%s/sso_connections/connection-id/users/user-id-2", groupID)).Return([]byte{}, nil)
+
+	assert.EqualError(t, sso.DeleteUser(groupID, "connection-id", newUser("user-id-1", "test1@example.com"), &logger), "status code 500")
+	assert.NoError(t, sso.DeleteUser(groupID, "connection-id", newUser("user-id-2", "test2@example.com"), &logger))
+	mockClient.AssertNumberOfCalls(t, "Get", 0)
+}
+
 func TestGetUsers_EmptyConnection(t *testing.T) {
 	mockClient := new(mocks.MockSnykClient)
 	sso := New(mockClient)
@@ -305,13 +327,7 @@ func TestGetUsers_EmptyConnection(t *testing.T) {
 	groupID := "test-group-id"
 	expectedPath := fmt.Sprintf("/rest/groups/%s/sso_connections", groupID)
 	expectedResponse := Connection{
-		Data: []struct {
-			ID         *string `json:"id"`
-			Type       *string `json:"type"`
-			Attributes *struct {
-				Name *string `json:"name"`
-			} `json:"attributes"`
-		}{},
+		Data: []ConnectionData{},
 	}
 	expectedResponseBody, _ := json.Marshal(expectedResponse)
 	mockClient.On("Get", expectedPath).Return(expectedResponseBody, nil)
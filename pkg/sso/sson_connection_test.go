@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/rs/zerolog"
 	"github.com/snyk-labs/snyk-sso-membership/test/mocks"
 	"github.com/stretchr/testify/assert"
 )
@@ -17,13 +18,7 @@ func TestGetSSOConnection(t *testing.T) {
 	groupID := "test-group-id"
 	expectedPath := fmt.Sprintf("/rest/groups/%s/sso_connections", groupID)
 	expectedResponse := Connection{
-		Data: []struct {
-			ID         *string `json:"id"`
-			Type       *string `json:"type"`
-			Attributes *struct {
-				Name *string `json:"name"`
-			} `json:"attributes"`
-		}{
+		Data: []ConnectionData{
 			{
 				ID:   stringPtr("test-connection-id"),
 				Type: stringPtr("sso_connection"),
@@ -82,3 +77,97 @@ func TestGetSSOConnection_UnmarshalError(t *testing.T) {
 
 	mockClient.AssertExpectations(t)
 }
+
+func TestGetUsers_SelectConnection(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "test-group-id"
+	connectionsBody := []byte(`{"data": [
+		{"id": "connection-okta", "type": "sso_connection", "attributes": {"name": "Okta"}},
+		{"id": "connection-entra", "type": "sso_connection", "attributes": {"name": "Entra ID"}}]}`)
+	usersBody := []byte(`{"data": [{"id": "user-id-1", "type": "user", "attributes": {"email": "john.doe@example.com"}}]}`)
+
+	t.Run("by name", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections", groupID)).Return(connectionsBody, nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections/connection-entra/users?limit=100", groupID)).Return(usersBody, nil)
+
+		users, err := New(mockClient).WithConnection("entra id").GetUsers(groupID, &logger)
+		assert.NoError(t, err)
+		assert.Len(t, users.Data, 1)
+	})
+
+	t.Run("by ID", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections", groupID)).Return(connectionsBody, nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections/connection-okta/users?limit=100", groupID)).Return(usersBody, nil)
+
+		_, err := New(mockClient).WithConnection("connection-okta").GetUsers(groupID, &logger)
+		assert.NoError(t, err)
+	})
+
+	t.Run("several connections require a selection", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections", groupID)).Return(connectionsBody, nil)
+
+		_, err := New(mockClient).GetUsers(groupID, &logger)
+		assert.EqualError(t, err, "group: test-group-id has 2 SSO connections, select one of: Okta, Entra ID")
+	})
+
+	t.Run("unknown connection", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections", groupID)).Return(connectionsBody, nil)
+
+		err := New(mockClient).WithConnection("Google").DeleteUsers(groupID, Users{}, &logger)
+		assert.EqualError(t, err, "SSO connection Google not found on group: test-group-id")
+	})
+}
+
+func TestGetConnections(t *testing.T) {
+	mockClient := new(mocks.MockSnykClient)
+	groupID := "test-group-id"
+	mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections", groupID)).Return([]byte(`{"data": [
+		{"id": "connection-okta", "type": "sso_connection", "attributes": {"name": "Okta"}},
+		{"id": "connection-entra", "type": "sso_connection", "attributes": {"name": "Entra ID"}}]}`), nil)
+
+	connections, err := New(mockClient).GetConnections(groupID)
+	assert.NoError(t, err)
+	assert.Len(t, connections, 2)
+	assert.Equal(t, "connection-entra", *connections[1].ID)
+	assert.Equal(t, "Entra ID", connectionName(connections[1]))
+}
+
+func TestGetConnectionDetails(t *testing.T) {
+	logger := zerolog.Nop()
+	groupID := "test-group-id"
+
+	t.Run("counts the users of every connection", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections", groupID)).Return([]byte(`{"data": [
+			{"id": "connection-okta", "type": "sso_connection", "attributes": {"name": "Okta"}},
+			{"id": "connection-entra", "type": "sso_connection"}]}`), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections/connection-okta/users?limit=100", groupID)).
+			Return([]byte(`{"data": [{"id": "user-1"}], "links": {"next": "/groups/test-group-id/sso_connections/connection-okta/users?limit=100&starting_after=user-1"}}`), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections/connection-okta/users?limit=100&starting_after=user-1", groupID)).
+			Return([]byte(`{"data": [{"id": "user-2"}]}`), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections/connection-entra/users?limit=100", groupID)).
+			Return([]byte(`{"data": []}`), nil)
+
+		details, err := New(mockClient).GetConnectionDetails(groupID, &logger)
+		assert.NoError(t, err)
+		assert.Equal(t, []ConnectionDetails{
+			{ID: "connection-okta", Name: "Okta", Type: "sso_connection", Users: 2},
+			{ID: "connection-entra", Name: "connection-entra", Type: "sso_connection", Users: 0},
+		}, details)
+	})
+
+	t.Run("users error", func(t *testing.T) {
+		mockClient := new(mocks.MockSnykClient)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections", groupID)).Return([]byte(`{"data": [
+			{"id": "connection-okta", "type": "sso_connection", "attributes": {"name": "Okta"}}]}`), nil)
+		mockClient.On("Get", fmt.Sprintf("/rest/groups/%s/sso_connections/connection-okta/users?limit=100", groupID)).
+			Return([]byte{}, errors.New("get error"))
+
+		_, err := New(mockClient).GetConnectionDetails(groupID, &logger)
+		assert.EqualError(t, err, "unable to get SSO users on connection: Okta: get error")
+	})
+}